@@ -0,0 +1,158 @@
+package arena
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"math/rand/v2"
+	"os"
+	"path/filepath"
+	"sort"
+	"time"
+
+	"github.com/falbanese9484/terminal-chat/config"
+)
+
+const (
+	// InitialRating is where every model starts.
+	InitialRating = 1000.0
+	// K is how far a single vote can move a rating.
+	K = 32.0
+)
+
+type Outcome int
+
+const (
+	AWins Outcome = iota
+	BWins
+	Tie
+)
+
+type Rating struct {
+	Model  string  `json:"model"`
+	Rating float64 `json:"rating"`
+	Wins   int     `json:"wins"`
+	Losses int     `json:"losses"`
+	Ties   int     `json:"ties"`
+}
+
+// Games is how many votes the model has been in.
+func (r *Rating) Games() int {
+	return r.Wins + r.Losses + r.Ties
+}
+
+type Vote struct {
+	A       string    `json:"a"`
+	B       string    `json:"b"`
+	Outcome Outcome   `json:"outcome"`
+	At      time.Time `json:"at"`
+}
+
+type Leaderboard struct {
+	// Ratings are Elo ratings by model. Votes keeps every result so the ratings can be
+	// recomputed if K ever changes.
+	Ratings map[string]*Rating `json:"ratings"`
+	Votes   []Vote             `json:"votes"`
+	path    string
+}
+
+// Load reads the leaderboard at path. A missing file is an empty leaderboard.
+func Load(path string) (*Leaderboard, error) {
+	l := &Leaderboard{Ratings: map[string]*Rating{}, Votes: []Vote{}, path: path}
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return l, nil
+	}
+	if err != nil {
+		return l, fmt.Errorf("failed to read leaderboard: %w", err)
+	}
+	if err := json.Unmarshal(data, l); err != nil {
+		return l, fmt.Errorf("failed to read leaderboard: %w", err)
+	}
+	if l.Ratings == nil {
+		l.Ratings = map[string]*Rating{}
+	}
+	return l, nil
+}
+
+// LoadDefault loads the leaderboard kept in the bash-butler config directory.
+func LoadDefault() (*Leaderboard, error) {
+	path, err := config.Path("arena.json")
+	if err != nil {
+		return nil, err
+	}
+	return Load(path)
+}
+
+func (l *Leaderboard) Save() error {
+	data, err := json.MarshalIndent(l, "", "  ")
+	if err != nil {
+		return err
+	}
+	tmp := filepath.Join(filepath.Dir(l.path), ".arena.json.tmp")
+	if err := os.WriteFile(tmp, data, 0o644); err != nil {
+		return fmt.Errorf("failed to save leaderboard: %w", err)
+	}
+	return os.Rename(tmp, l.path)
+}
+
+func (l *Leaderboard) rating(model string) *Rating {
+	r, ok := l.Ratings[model]
+	if !ok {
+		r = &Rating{Model: model, Rating: InitialRating}
+		l.Ratings[model] = r
+	}
+	return r
+}
+
+// Record applies a vote between a and b to their ratings.
+func (l *Leaderboard) Record(a, b string, outcome Outcome) {
+	ra, rb := l.rating(a), l.rating(b)
+	expected := 1 / (1 + math.Pow(10, (rb.Rating-ra.Rating)/400))
+	score := 0.5
+	switch outcome {
+	case AWins:
+		score = 1
+		ra.Wins++
+		rb.Losses++
+	case BWins:
+		score = 0
+		ra.Losses++
+		rb.Wins++
+	default:
+		ra.Ties++
+		rb.Ties++
+	}
+	ra.Rating += K * (score - expected)
+	rb.Rating -= K * (score - expected)
+	l.Votes = append(l.Votes, Vote{A: a, B: b, Outcome: outcome, At: time.Now()})
+}
+
+// Ranked lists the ratings, best first.
+func (l *Leaderboard) Ranked() []*Rating {
+	ranked := make([]*Rating, 0, len(l.Ratings))
+	for _, r := range l.Ratings {
+		ranked = append(ranked, r)
+	}
+	sort.Slice(ranked, func(i, j int) bool {
+		if ranked[i].Rating != ranked[j].Rating {
+			return ranked[i].Rating > ranked[j].Rating
+		}
+		return ranked[i].Model < ranked[j].Model
+	})
+	return ranked
+}
+
+// Pair picks two different models at random, in random order.
+func Pair(models []string) (string, string, error) {
+	if len(models) < 2 {
+		return "", "", errors.New("the arena needs at least two models")
+	}
+	i := rand.IntN(len(models))
+	j := rand.IntN(len(models) - 1)
+	if j >= i {
+		j++
+	}
+	return models[i], models[j], nil
+}
@@ -0,0 +1,107 @@
+package arena
+
+import (
+	"math"
+	"path/filepath"
+	"testing"
+)
+
+func TestRecord(t *testing.T) {
+	tests := []struct {
+		name    string
+		a, b    float64
+		outcome Outcome
+		wantA   float64
+		wantB   float64
+	}{
+		{"even, A wins", InitialRating, InitialRating, AWins, 1016, 984},
+		{"even, B wins", InitialRating, InitialRating, BWins, 984, 1016},
+		{"even, tie", InitialRating, InitialRating, Tie, 1000, 1000},
+		// The favourite gains little for an expected win and loses more on an upset.
+		{"favourite wins", 1400, 1000, AWins, 1400 + K*(1-1/1.1), 1000 - K*(1-1/1.1)},
+		{"upset", 1400, 1000, BWins, 1400 - K/1.1, 1000 + K/1.1},
+		{"tie pulls together", 1400, 1000, Tie, 1400 + K*(0.5-1/1.1), 1000 - K*(0.5-1/1.1)},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			l := &Leaderboard{Ratings: map[string]*Rating{
+				"a": {Model: "a", Rating: tt.a},
+				"b": {Model: "b", Rating: tt.b},
+			}}
+			l.Record("a", "b", tt.outcome)
+			gotA, gotB := l.Ratings["a"].Rating, l.Ratings["b"].Rating
+			if math.Abs(gotA-tt.wantA) > 1e-9 || math.Abs(gotB-tt.wantB) > 1e-9 {
+				t.Errorf("ratings = %.4f, %.4f, want %.4f, %.4f", gotA, gotB, tt.wantA, tt.wantB)
+			}
+			if math.Abs(gotA+gotB-tt.a-tt.b) > 1e-9 {
+				t.Errorf("a vote changed the total rating from %.4f to %.4f", tt.a+tt.b, gotA+gotB)
+			}
+			if len(l.Votes) != 1 || l.Votes[0].Outcome != tt.outcome {
+				t.Errorf("votes = %+v, want the one recorded", l.Votes)
+			}
+		})
+	}
+}
+
+func TestRecordCounts(t *testing.T) {
+	l := &Leaderboard{Ratings: map[string]*Rating{}}
+	l.Record("a", "b", AWins)
+	l.Record("b", "a", AWins)
+	l.Record("a", "c", Tie)
+	a, b, c := l.Ratings["a"], l.Ratings["b"], l.Ratings["c"]
+	if a.Wins != 1 || a.Losses != 1 || a.Ties != 1 || a.Games() != 3 {
+		t.Errorf("a = %+v, want 1 win, 1 loss and 1 tie", a)
+	}
+	if b.Wins != 1 || b.Losses != 1 || b.Ties != 0 {
+		t.Errorf("b = %+v, want 1 win and 1 loss", b)
+	}
+	if c.Ties != 1 || c.Games() != 1 {
+		t.Errorf("c = %+v, want a single tie", c)
+	}
+}
+
+func TestRanked(t *testing.T) {
+	l := &Leaderboard{Ratings: map[string]*Rating{
+		"b": {Model: "b", Rating: 1000},
+		"a": {Model: "a", Rating: 1000},
+		"c": {Model: "c", Rating: 1100},
+	}}
+	got := []string{}
+	for _, r := range l.Ranked() {
+		got = append(got, r.Model)
+	}
+	if want := []string{"c", "a", "b"}; len(got) != 3 || got[0] != want[0] || got[1] != want[1] || got[2] != want[2] {
+		t.Errorf("Ranked() = %v, want %v", got, want)
+	}
+}
+
+func TestPair(t *testing.T) {
+	if _, _, err := Pair([]string{"only"}); err == nil {
+		t.Error("Pair of one model should fail")
+	}
+	for i := 0; i < 100; i++ {
+		a, b, err := Pair([]string{"x", "y", "z"})
+		if err != nil || a == b {
+			t.Fatalf("Pair() = %q, %q, %v, want two different models", a, b, err)
+		}
+	}
+}
+
+func TestSaveLoad(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "arena.json")
+	l, err := Load(path)
+	if err != nil || len(l.Ratings) != 0 {
+		t.Fatalf("Load of a missing file = %+v, %v, want an empty leaderboard", l, err)
+	}
+	l.Record("a", "b", BWins)
+	if err := l.Save(); err != nil {
+		t.Fatal(err)
+	}
+	loaded, err := Load(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if loaded.Ratings["b"].Rating != l.Ratings["b"].Rating || len(loaded.Votes) != 1 {
+		t.Errorf("loaded %+v, want what was saved", loaded)
+	}
+}
@@ -0,0 +1,112 @@
+package attachments
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"os"
+	"os/exec"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/falbanese9484/terminal-chat/types"
+)
+
+const (
+	// CommandTimeout bounds how long a !command may run before it is killed.
+	CommandTimeout = 30 * time.Second
+	// MaxOutputSize is the number of bytes of command output kept for the prompt.
+	MaxOutputSize = 32 * 1024
+)
+
+// IsCommand reports whether the input is a !command rather than a prompt.
+func IsCommand(input string) bool {
+	trimmed := strings.TrimSpace(input)
+	return len(trimmed) > 1 && trimmed[0] == '!'
+}
+
+// RunCommand runs a !command line through the user's shell and captures stdout and stderr
+// together, along with the exit code. A command that fails to run still produces an
+// attachment, since the failure output is usually what the user wants to ask about.
+func RunCommand(ctx context.Context, input string, timeout time.Duration) *types.Attachment {
+	line := strings.TrimSpace(strings.TrimPrefix(strings.TrimSpace(input), "!"))
+	return run(ctx, line, nil, timeout)
+}
+
+// PipeCommand runs a shell command line with stdin fed to it, capturing the output the
+// same way RunCommand does.
+func PipeCommand(ctx context.Context, line, stdin string, timeout time.Duration) *types.Attachment {
+	return run(ctx, strings.TrimSpace(line), strings.NewReader(stdin), timeout)
+}
+
+func run(ctx context.Context, line string, stdin io.Reader, timeout time.Duration) *types.Attachment {
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	shell := os.Getenv("SHELL")
+	if shell == "" {
+		shell = "sh"
+	}
+	cmd := exec.CommandContext(ctx, shell, "-c", line)
+	cmd.Stdin = stdin
+	output := &limitedBuffer{max: MaxOutputSize}
+	cmd.Stdout = output
+	cmd.Stderr = output
+	// Background children can hold the output pipes open after the shell is killed.
+	cmd.WaitDelay = time.Second
+	err := cmd.Run()
+
+	att := &types.Attachment{
+		Kind:     types.AttachmentCommand,
+		Name:     "!" + line,
+		Command:  line,
+		Language: "console",
+		Size:     output.size,
+	}
+	var exitErr *exec.ExitError
+	switch {
+	case errors.Is(ctx.Err(), context.DeadlineExceeded):
+		att.TimedOut = true
+		att.ExitCode = -1
+	case errors.As(err, &exitErr):
+		att.ExitCode = exitErr.ExitCode()
+	case err != nil:
+		att.ExitCode = -1
+		output.Write([]byte(err.Error()))
+	}
+
+	att.Truncated = output.size > int64(output.buf.Len())
+	att.Content = string(cutRune(output.buf.Bytes()))
+	return att
+}
+
+type limitedBuffer struct {
+	// Keeps the first max bytes written and counts the rest, so a command that writes
+	// without end can't fill memory before its timeout.
+	buf  bytes.Buffer
+	max  int
+	size int64
+}
+
+func (b *limitedBuffer) Write(p []byte) (int, error) {
+	b.size += int64(len(p))
+	if room := b.max - b.buf.Len(); room > 0 {
+		b.buf.Write(p[:min(room, len(p))])
+	}
+	return len(p), nil
+}
+
+// cutRune drops a rune cut in two at the end of data.
+func cutRune(data []byte) []byte {
+	for i := len(data) - 1; i >= 0 && i >= len(data)-utf8.UTFMax; i-- {
+		if utf8.RuneStart(data[i]) {
+			if !utf8.FullRune(data[i:]) {
+				return data[:i]
+			}
+			break
+		}
+	}
+	return data
+}
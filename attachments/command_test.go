@@ -0,0 +1,69 @@
+package attachments
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+	"unicode/utf8"
+)
+
+func TestLimitedBuffer(t *testing.T) {
+	b := &limitedBuffer{max: 5}
+	for _, p := range []string{"abc", "defg", "hij"} {
+		if n, err := b.Write([]byte(p)); n != len(p) || err != nil {
+			t.Fatalf("Write(%q) = %d, %v", p, n, err)
+		}
+	}
+	if got := b.buf.String(); got != "abcde" {
+		t.Errorf("kept %q, want %q", got, "abcde")
+	}
+	if b.size != 10 {
+		t.Errorf("counted %d bytes, want 10", b.size)
+	}
+}
+
+func TestCutRune(t *testing.T) {
+	tests := []struct {
+		data string
+		want string
+	}{
+		{"", ""},
+		{"ascii", "ascii"},
+		{"héllo", "héllo"},
+		{"h\xc3", "h"},
+		{"日本"[:4], "日"},
+		{"日本"[:5], "日"},
+		{"😀"[:3], ""},
+		{"ok😀", "ok😀"},
+	}
+	for _, tt := range tests {
+		if got := string(cutRune([]byte(tt.data))); got != tt.want {
+			t.Errorf("cutRune(%q) = %q, want %q", tt.data, got, tt.want)
+		}
+	}
+}
+
+func TestRunCommandTruncates(t *testing.T) {
+	t.Setenv("SHELL", "sh")
+	// After the leading x, lines of "é\n" take three bytes each and the limit falls
+	// inside an é.
+	att := RunCommand(context.Background(), "!printf x; yes é | head -c 100000", 10*time.Second)
+	if att.ExitCode != 0 {
+		t.Fatalf("exit code %d: %s", att.ExitCode, att.Content)
+	}
+	if !att.Truncated || att.Size != 100001 {
+		t.Errorf("Truncated = %v, Size = %d", att.Truncated, att.Size)
+	}
+	if len(att.Content) > MaxOutputSize || !utf8.ValidString(att.Content) {
+		t.Errorf("kept %d bytes, valid UTF-8 %v", len(att.Content), utf8.ValidString(att.Content))
+	}
+	if !strings.HasPrefix(att.Content, "xé\n") {
+		t.Errorf("output starts %q", att.Content[:10])
+	}
+
+	att = RunCommand(context.Background(), "!echo hi; exit 3", 10*time.Second)
+	if att.Truncated || att.Content != "hi\n" || att.ExitCode != 3 {
+		t.Errorf("got %+v", att)
+	}
+}
@@ -0,0 +1,57 @@
+package attachments
+
+import (
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"unicode/utf8"
+)
+
+// CompletePath completes a partially typed path. It returns the longest unambiguous
+// completion along with every matching candidate. Directories carry a trailing slash so
+// completion can keep descending.
+func CompletePath(partial string) (string, []string) {
+	dir, prefix := filepath.Split(partial)
+	readDir := dir
+	if readDir == "" {
+		readDir = "."
+	}
+	entries, err := os.ReadDir(ExpandHome(readDir))
+	if err != nil {
+		return partial, nil
+	}
+
+	candidates := []string{}
+	for _, entry := range entries {
+		name := entry.Name()
+		if !strings.HasPrefix(name, prefix) {
+			continue
+		}
+		if strings.HasPrefix(name, ".") && !strings.HasPrefix(prefix, ".") {
+			continue
+		}
+		if entry.IsDir() {
+			name += "/"
+		}
+		candidates = append(candidates, dir+name)
+	}
+	sort.Strings(candidates)
+	if len(candidates) == 0 {
+		return partial, nil
+	}
+	return commonPrefix(candidates), candidates
+}
+
+// commonPrefix trims whole runes, so names that only share the first byte of a
+// character don't leave half of it behind.
+func commonPrefix(values []string) string {
+	prefix := values[0]
+	for _, v := range values[1:] {
+		for !strings.HasPrefix(v, prefix) {
+			_, size := utf8.DecodeLastRuneInString(prefix)
+			prefix = prefix[:len(prefix)-size]
+		}
+	}
+	return prefix
+}
@@ -0,0 +1,31 @@
+package attachments
+
+import (
+	"testing"
+	"unicode/utf8"
+)
+
+func TestCommonPrefix(t *testing.T) {
+	tests := []struct {
+		name   string
+		values []string
+		want   string
+	}{
+		{"single", []string{"notes.md"}, "notes.md"},
+		{"shared", []string{"main.go", "main_test.go"}, "main"},
+		{"nothing shared", []string{"a.go", "b.go"}, ""},
+		{"shared lead byte", []string{"café", "cafè"}, "caf"},
+		{"multibyte shared", []string{"日本語.txt", "日本.txt"}, "日本"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := commonPrefix(tt.values)
+			if got != tt.want {
+				t.Errorf("commonPrefix(%q) = %q, want %q", tt.values, got, tt.want)
+			}
+			if !utf8.ValidString(got) {
+				t.Errorf("commonPrefix(%q) = %q is not valid UTF-8", tt.values, got)
+			}
+		})
+	}
+}
@@ -0,0 +1,131 @@
+package attachments
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/falbanese9484/terminal-chat/types"
+)
+
+// MaxFileSize is the number of bytes read from an attached file. Anything past it is
+// dropped and the attachment is marked as truncated.
+const MaxFileSize = 64 * 1024
+
+var (
+	ErrBinaryFile = errors.New("file looks like binary data")
+	ErrDirectory  = errors.New("path is a directory")
+)
+
+var languages = map[string]string{
+	".go":         "go",
+	".rs":         "rust",
+	".py":         "python",
+	".js":         "javascript",
+	".jsx":        "jsx",
+	".ts":         "typescript",
+	".tsx":        "tsx",
+	".rb":         "ruby",
+	".java":       "java",
+	".kt":         "kotlin",
+	".c":          "c",
+	".h":          "c",
+	".cpp":        "cpp",
+	".hpp":        "cpp",
+	".cs":         "csharp",
+	".swift":      "swift",
+	".php":        "php",
+	".lua":        "lua",
+	".sh":         "bash",
+	".bash":       "bash",
+	".zsh":        "zsh",
+	".fish":       "fish",
+	".ps1":        "powershell",
+	".sql":        "sql",
+	".html":       "html",
+	".css":        "css",
+	".scss":       "scss",
+	".json":       "json",
+	".yaml":       "yaml",
+	".yml":        "yaml",
+	".toml":       "toml",
+	".xml":        "xml",
+	".md":         "markdown",
+	".proto":      "protobuf",
+	".tf":         "hcl",
+	".mod":        "go",
+	".dockerfile": "dockerfile",
+}
+
+var namedLanguages = map[string]string{
+	"Dockerfile": "dockerfile",
+	"Makefile":   "makefile",
+}
+
+// Language returns the markdown fence tag for the file at path, or an empty string
+// when the type is unknown.
+func Language(path string) string {
+	if lang, ok := namedLanguages[filepath.Base(path)]; ok {
+		return lang
+	}
+	return languages[strings.ToLower(filepath.Ext(path))]
+}
+
+// LoadFile reads up to MaxFileSize bytes of the file at path and returns it as an
+// attachment. Directories and binary files are rejected.
+func LoadFile(path string) (*types.Attachment, error) {
+	info, err := os.Stat(path)
+	if err != nil {
+		return nil, err
+	}
+	if info.IsDir() {
+		return nil, ErrDirectory
+	}
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	data, err := io.ReadAll(io.LimitReader(f, MaxFileSize))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read %s: %w", path, err)
+	}
+	if isBinary(data) {
+		return nil, ErrBinaryFile
+	}
+	return &types.Attachment{
+		Kind:      types.AttachmentFile,
+		Name:      filepath.Base(path),
+		Path:      path,
+		Language:  Language(path),
+		Content:   string(data),
+		Size:      info.Size(),
+		Truncated: info.Size() > MaxFileSize,
+	}, nil
+}
+
+// isBinary sniffs the start of the data the same way git does: a NUL byte means binary.
+// Invalid UTF-8 is also treated as binary, allowing for a rune cut off by the size cap.
+func isBinary(data []byte) bool {
+	sniff := data
+	if len(sniff) > 8000 {
+		sniff = sniff[:8000]
+	}
+	if bytes.IndexByte(sniff, 0) >= 0 {
+		return true
+	}
+	for len(data) > 0 {
+		r, size := utf8.DecodeRune(data)
+		if r == utf8.RuneError && size == 1 {
+			return len(data) >= utf8.UTFMax
+		}
+		data = data[size:]
+	}
+	return false
+}
@@ -0,0 +1,81 @@
+package attachments
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/falbanese9484/terminal-chat/types"
+)
+
+// Compose appends each attachment to the prompt as a language tagged code fence, which is
+// the text actually sent to the provider. Images travel separately and are skipped.
+func Compose(prompt string, atts []*types.Attachment) string {
+	var sb strings.Builder
+	sb.WriteString(prompt)
+	for _, att := range atts {
+		if att.Kind == types.AttachmentImage {
+			continue
+		}
+		sb.WriteString("\n\n")
+		sb.WriteString(Fence(att))
+	}
+	return sb.String()
+}
+
+// Fence renders a single attachment as a markdown code block with a short header.
+func Fence(att *types.Attachment) string {
+	content := strings.TrimRight(att.Content, "\n")
+	fence := "```"
+	for strings.Contains(content, fence) {
+		fence += "`"
+	}
+	var header string
+	switch att.Kind {
+	case types.AttachmentCommand:
+		header = fmt.Sprintf("Output of `%s` (%s)", att.Command, exitStatus(att))
+		if att.Truncated {
+			header += fmt.Sprintf(", truncated to the first %s of %s", FormatSize(MaxOutputSize), FormatSize(att.Size))
+		}
+	case types.AttachmentExcerpt:
+		header = fmt.Sprintf("Excerpt: %s lines %d-%d", att.Path, att.StartLine, att.EndLine)
+	default:
+		header = fmt.Sprintf("File: %s", att.Path)
+		if att.Truncated {
+			header += fmt.Sprintf(" (truncated to the first %s of %s)", FormatSize(MaxFileSize), FormatSize(att.Size))
+		}
+	}
+	return fmt.Sprintf("%s\n%s%s\n%s\n%s", header, fence, att.Language, content, fence)
+}
+
+// Label is the short description shown on an attachment chip.
+func Label(att *types.Attachment) string {
+	label := fmt.Sprintf("%s · %s", att.Name, FormatSize(att.Size))
+	switch {
+	case att.Kind == types.AttachmentCommand:
+		label = fmt.Sprintf("%s · %s", att.Name, exitStatus(att))
+	case att.Kind == types.AttachmentImage && att.Width > 0:
+		label = fmt.Sprintf("%s · %dx%d · %s", att.Name, att.Width, att.Height, FormatSize(att.Size))
+	}
+	if att.Truncated {
+		label += " · truncated"
+	}
+	return label
+}
+
+func exitStatus(att *types.Attachment) string {
+	if att.TimedOut {
+		return "timed out"
+	}
+	return fmt.Sprintf("exit %d", att.ExitCode)
+}
+
+func FormatSize(size int64) string {
+	switch {
+	case size >= 1024*1024:
+		return fmt.Sprintf("%.1f MB", float64(size)/(1024*1024))
+	case size >= 1024:
+		return fmt.Sprintf("%.1f KB", float64(size)/1024)
+	default:
+		return fmt.Sprintf("%d B", size)
+	}
+}
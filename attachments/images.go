@@ -0,0 +1,83 @@
+package attachments
+
+import (
+	"bytes"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"image"
+	_ "image/gif"
+	_ "image/jpeg"
+	_ "image/png"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/falbanese9484/terminal-chat/types"
+)
+
+// MaxImageSize is the largest image accepted. Providers reject anything much bigger.
+const MaxImageSize = 20 * 1024 * 1024
+
+var ErrNotImage = errors.New("file is not a supported image")
+
+var imageExtensions = map[string]bool{
+	".png":  true,
+	".jpg":  true,
+	".jpeg": true,
+	".gif":  true,
+	".webp": true,
+}
+
+// IsImage reports whether the path looks like an image by its extension.
+func IsImage(path string) bool {
+	return imageExtensions[strings.ToLower(filepath.Ext(path))]
+}
+
+// LoadImage reads the image at path and base64 encodes it for the provider.
+func LoadImage(path string) (*types.Attachment, error) {
+	info, err := os.Stat(path)
+	if err != nil {
+		return nil, err
+	}
+	if info.IsDir() {
+		return nil, ErrDirectory
+	}
+	if info.Size() > MaxImageSize {
+		return nil, fmt.Errorf("image is %s, the limit is %s", FormatSize(info.Size()), FormatSize(MaxImageSize))
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read %s: %w", path, err)
+	}
+	mimeType := http.DetectContentType(data)
+	if !strings.HasPrefix(mimeType, "image/") {
+		return nil, ErrNotImage
+	}
+
+	att := &types.Attachment{
+		Kind:     types.AttachmentImage,
+		Name:     filepath.Base(path),
+		Path:     path,
+		Size:     info.Size(),
+		MimeType: mimeType,
+		Data:     base64.StdEncoding.EncodeToString(data),
+	}
+	// webp has no decoder in the standard library, so it goes without dimensions.
+	if cfg, _, err := image.DecodeConfig(bytes.NewReader(data)); err == nil {
+		att.Width, att.Height = cfg.Width, cfg.Height
+	}
+	return att, nil
+}
+
+// Images returns the base64 data of every image attachment.
+func Images(atts []*types.Attachment) []string {
+	images := []string{}
+	for _, att := range atts {
+		if att.Kind == types.AttachmentImage {
+			images = append(images, att.Data)
+		}
+	}
+	return images
+}
@@ -0,0 +1,70 @@
+package attachments
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/falbanese9484/terminal-chat/types"
+)
+
+// Mentions returns the paths referenced with @path in the prompt. A mention has to start
+// a word, so email addresses and the like are left alone.
+func Mentions(prompt string) []string {
+	mentions := []string{}
+	seen := map[string]bool{}
+	for _, word := range strings.Fields(prompt) {
+		if len(word) < 2 || word[0] != '@' {
+			continue
+		}
+		path := strings.TrimRight(word[1:], ",;:!?)\"'")
+		if path == "" || seen[path] {
+			continue
+		}
+		seen[path] = true
+		mentions = append(mentions, path)
+	}
+	return mentions
+}
+
+// Load attaches the file at path, as an image when it has an image extension and as text
+// otherwise.
+func Load(path string) (*types.Attachment, error) {
+	if IsImage(path) {
+		return LoadImage(ExpandHome(path))
+	}
+	return LoadFile(ExpandHome(path))
+}
+
+// Resolve loads every @-mentioned file in the prompt. Mentions that don't point at an
+// existing file are ignored; files that exist but can't be attached are reported in errs.
+func Resolve(prompt string) (atts []*types.Attachment, errs []error) {
+	for _, mention := range Mentions(prompt) {
+		att, err := Load(mention)
+		if errors.Is(err, fs.ErrNotExist) {
+			continue
+		}
+		if err != nil {
+			errs = append(errs, fmt.Errorf("@%s: %w", mention, err))
+			continue
+		}
+		att.Path = mention
+		atts = append(atts, att)
+	}
+	return atts, errs
+}
+
+// ExpandHome replaces a leading ~/ with the user's home directory.
+func ExpandHome(path string) string {
+	if !strings.HasPrefix(path, "~/") {
+		return path
+	}
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return path
+	}
+	return filepath.Join(home, path[2:])
+}
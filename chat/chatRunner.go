@@ -2,57 +2,165 @@ package chat
 
 import (
 	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"sync"
 
 	"github.com/falbanese9484/terminal-chat/logger"
 	"github.com/falbanese9484/terminal-chat/types"
 )
 
 type ChatBus struct {
-	// This Bus is going to be used to feed messages to the TUI event loop
-	Done          chan bool
-	Content       chan *types.ChatResponse
-	Error         chan error
+	// This Bus is going to be used to feed messages to the TUI event loop.
+	// Every run gets its own channels to the provider; what comes back is tagged with
+	// the request and session it belongs to and funnelled into events, so several
+	// conversations can stream at once.
+	events        chan *types.ChatResponse
 	modelProvider *types.ProviderService
 	logger        *logger.Logger
+	mu            sync.Mutex
+	streams       map[string]*Stream
 }
 
-// NewChatBus creates and returns a ChatBus with initialized channels for Done, Content, and Error,
-// and assigns the provided logger and model provider.
-// The channels are unbuffered.
+type Stream struct {
+	// A handle on one running request. Every response it produces carries ID, and the
+	// last one is always a done, error or cancelled event.
+	ID        string
+	SessionID string
+	cancel    context.CancelFunc
+}
+
+// Cancel stops the request. The stream ends with a cancelled event and nothing the
+// provider sends afterwards is delivered.
+func (s *Stream) Cancel() {
+	s.cancel()
+}
 
+// NewChatBus creates and returns a ChatBus, assigning the provided logger and model provider.
 func NewChatBus(logger *logger.Logger, mp *types.ProviderService) *ChatBus {
 	return &ChatBus{
-		Done:          make(chan bool),
-		Content:       make(chan *types.ChatResponse),
-		Error:         make(chan error),
+		events:        make(chan *types.ChatResponse),
 		modelProvider: mp,
 		logger:        logger,
+		streams:       map[string]*Stream{},
 	}
 }
 
+// Start forwards the responses of every run to byteReader.
 func (cb *ChatBus) Start(byteReader chan *types.ChatResponse) {
+	for response := range cb.events {
+		cb.logger.Debug("streaming", "request", response.RequestID, "event", response.Event)
+		byteReader <- response
+	}
+}
+
+// Active reports whether the request is still running.
+func (cb *ChatBus) Active(requestID string) bool {
+	cb.mu.Lock()
+	defer cb.mu.Unlock()
+	_, ok := cb.streams[requestID]
+	return ok
+}
+
+// RunChat starts streaming a response for the session and returns its handle straight
+// away. The responses arrive through Start.
+func (cb *ChatBus) RunChat(sessionID string, request *types.ChatRequest) *Stream {
+	return cb.RunChatOn(cb.modelProvider, sessionID, request)
+}
+
+// RunChatOn is RunChat with another provider than the bus was created with.
+func (cb *ChatBus) RunChatOn(provider *types.ProviderService, sessionID string, request *types.ChatRequest) *Stream {
+	ctx, cancel := context.WithCancel(context.Background())
+	stream := &Stream{ID: newRequestID(), SessionID: sessionID, cancel: cancel}
+	cb.mu.Lock()
+	cb.streams[stream.ID] = stream
+	cb.mu.Unlock()
+	go cb.run(ctx, provider, stream, request)
+	return stream
+}
+
+func (cb *ChatBus) run(ctx context.Context, provider *types.ProviderService, stream *Stream, request *types.ChatRequest) {
+	conn := &types.BusConnector{
+		Ctx:          ctx,
+		Request:      request,
+		ResponseChan: make(chan *types.ChatResponse),
+		ErrorChan:    make(chan error),
+		DoneChannel:  make(chan bool),
+	}
+	finished := make(chan struct{})
+	go func() {
+		provider.Chat(conn)
+		close(finished)
+	}()
+	defer func() {
+		cb.mu.Lock()
+		delete(cb.streams, stream.ID)
+		cb.mu.Unlock()
+		stream.cancel()
+	}()
+
+	cb.emit(stream, &types.ChatResponse{Event: types.EventStarted})
 	for {
 		select {
-		case response := <-cb.Content:
-			cb.logger.Debug("streaming", "bytes", len(cb.Content))
-			byteReader <- response
-		case err := <-cb.Error:
-			cb.logger.Error("failed to read incoming chat response", "error", err)
+		case response := <-conn.ResponseChan:
+			if ctx.Err() != nil {
+				continue
+			}
+			response.Event = types.EventDelta
+			cb.emit(stream, response)
+		case err := <-conn.ErrorChan:
+			if ctx.Err() != nil {
+				continue
+			}
+			cb.logger.Error("failed to read incoming chat response", "request", stream.ID, "error", err)
+			cb.emit(stream, &types.ChatResponse{Event: types.EventError, Done: true, Error: err.Error()})
+			return
+		case <-conn.DoneChannel:
+			if ctx.Err() != nil {
+				continue
+			}
+			cb.logger.Info("message complete - signalling done", "request", stream.ID)
+			cb.emit(stream, &types.ChatResponse{Event: types.EventDone, Done: true})
+			return
+		case <-finished:
+			// The provider gave up without saying so.
+			event := types.EventDone
+			if ctx.Err() != nil {
+				event = types.EventCancelled
+			}
+			cb.emit(stream, &types.ChatResponse{Event: event, Done: true})
+			return
+		case <-ctx.Done():
+			cb.logger.Info("request cancelled", "request", stream.ID)
+			cb.emit(stream, &types.ChatResponse{Event: types.EventCancelled, Done: true})
+			// Keep draining so the provider isn't left blocked on a send while it
+			// notices the cancelled request.
+			go drain(conn, finished)
 			return
-		case <-cb.Done:
-			cb.logger.Info("message complete - signalling done")
-			byteReader <- &types.ChatResponse{Done: true}
 		}
 	}
 }
 
-func (cb *ChatBus) RunChat(request *types.ChatRequest) {
-	ctx := context.Background()
-	cb.modelProvider.Chat(&types.BusConnector{
-		Ctx:          ctx,
-		Request:      request,
-		ResponseChan: cb.Content,
-		ErrorChan:    cb.Error,
-		DoneChannel:  cb.Done,
-	})
+func (cb *ChatBus) emit(stream *Stream, response *types.ChatResponse) {
+	response.RequestID = stream.ID
+	response.SessionID = stream.SessionID
+	cb.events <- response
+}
+
+func drain(conn *types.BusConnector, finished chan struct{}) {
+	for {
+		select {
+		case <-conn.ResponseChan:
+		case <-conn.ErrorChan:
+		case <-conn.DoneChannel:
+		case <-finished:
+			return
+		}
+	}
+}
+
+func newRequestID() string {
+	id := make([]byte, 6)
+	rand.Read(id)
+	return hex.EncodeToString(id)
 }
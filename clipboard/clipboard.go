@@ -0,0 +1,34 @@
+package clipboard
+
+import (
+	"os"
+
+	"github.com/atotto/clipboard"
+	"github.com/aymanbagabas/go-osc52/v2"
+	"github.com/charmbracelet/x/term"
+)
+
+// Copy puts text on the system clipboard using an OSC 52 escape sequence, which the
+// terminal handles. That works over SSH too, where there is no local clipboard to reach.
+// The sequence goes to stderr so it doesn't race the UI's writes to stdout.
+func Copy(text string) error {
+	seq := osc52.New(text)
+	switch {
+	case os.Getenv("TMUX") != "":
+		seq = seq.Tmux()
+	case os.Getenv("STY") != "":
+		seq = seq.Screen()
+	}
+	out := os.Stderr
+	if !term.IsTerminal(out.Fd()) {
+		out = os.Stdout
+	}
+	_, err := seq.WriteTo(out)
+	return err
+}
+
+// Paste reads the system clipboard through the platform's tools (pbpaste, xclip, xsel
+// or wl-paste). Unlike Copy it needs a local clipboard.
+func Paste() (string, error) {
+	return clipboard.ReadAll()
+}
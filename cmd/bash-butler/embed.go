@@ -0,0 +1,129 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/falbanese9484/terminal-chat/logger"
+	"github.com/falbanese9484/terminal-chat/rag"
+)
+
+type embedInput struct {
+	// Source is file:line for a line, the file name for a whole file, and "-" for stdin.
+	Source string `json:"source"`
+	Text   string `json:"text,omitempty"`
+}
+
+type embedRecord struct {
+	embedInput
+	Model     string    `json:"model"`
+	Embedding []float32 `json:"embedding"`
+}
+
+// embedCommand implements `bash-butler embed [-model spec] [-files] [-o file] [file...]`.
+func embedCommand(args []string) error {
+	flags := flag.NewFlagSet("embed", flag.ContinueOnError)
+	model := flags.String("model", envOr("BUTLER_EMBED_MODEL", rag.DefaultEmbedModel), "provider:model to embed with")
+	whole := flags.Bool("files", false, "embed each file whole instead of line by line")
+	output := flags.String("o", "", "file to write (default stdout)")
+	omitText := flags.Bool("no-text", false, "leave the input text out of the output")
+	flags.Usage = func() {
+		fmt.Fprintln(flags.Output(), "usage: bash-butler embed [-model provider:model] [-files] [-o file] [file...]")
+		fmt.Fprintln(flags.Output(), "Reads stdin without files. Writes one JSON object per input: source, text, model, embedding.")
+		flags.PrintDefaults()
+	}
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+	inputs, err := readEmbedInputs(flags.Args(), *whole)
+	if err != nil {
+		return err
+	}
+	if len(inputs) == 0 {
+		return errors.New("nothing to embed")
+	}
+
+	logger, err := logger.NewSafeLogger(true)
+	if err != nil {
+		return err
+	}
+	embedder, err := rag.NewEmbedder(newProviders(logger, defaultModel), *model)
+	if err != nil {
+		return err
+	}
+	texts := make([]string, len(inputs))
+	for i, in := range inputs {
+		texts[i] = in.Text
+	}
+	vectors, err := embedder.Embed(context.Background(), texts)
+	if err != nil {
+		return err
+	}
+
+	out := io.Writer(os.Stdout)
+	if *output != "" {
+		f, err := os.Create(*output)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		out = f
+	}
+	w := bufio.NewWriter(out)
+	enc := json.NewEncoder(w)
+	for i, in := range inputs {
+		if *omitText {
+			in.Text = ""
+		}
+		if err := enc.Encode(embedRecord{embedInput: in, Model: embedder.Model(), Embedding: vectors[i]}); err != nil {
+			return err
+		}
+	}
+	return w.Flush()
+}
+
+// readEmbedInputs reads the non-blank lines of each file, or stdin, or each file whole.
+func readEmbedInputs(paths []string, whole bool) ([]embedInput, error) {
+	if len(paths) == 0 {
+		paths = []string{"-"}
+	}
+	inputs := []embedInput{}
+	for _, path := range paths {
+		var data []byte
+		var err error
+		if path == "-" {
+			data, err = io.ReadAll(os.Stdin)
+		} else {
+			data, err = os.ReadFile(path)
+		}
+		if err != nil {
+			return nil, err
+		}
+		if whole {
+			if strings.TrimSpace(string(data)) != "" {
+				inputs = append(inputs, embedInput{Source: path, Text: string(data)})
+			}
+			continue
+		}
+		for i, line := range strings.Split(string(data), "\n") {
+			if strings.TrimSpace(line) != "" {
+				inputs = append(inputs, embedInput{Source: fmt.Sprintf("%s:%d", path, i+1), Text: strings.TrimRight(line, "\r")})
+			}
+		}
+	}
+	return inputs, nil
+}
+
+func envOr(key, fallback string) string {
+	if value := os.Getenv(key); value != "" {
+		return value
+	}
+	return fallback
+}
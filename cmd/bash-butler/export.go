@@ -0,0 +1,64 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+
+	"github.com/falbanese9484/terminal-chat/export"
+	"github.com/falbanese9484/terminal-chat/sessions"
+)
+
+// exportCommand implements `bash-butler export [-f format] [-o file] <session>`.
+func exportCommand(args []string) error {
+	flags := flag.NewFlagSet("export", flag.ContinueOnError)
+	format := flags.String("f", "", "format: md, json or html (default from -o, else md)")
+	output := flags.String("o", "", "file to write (default stdout)")
+	flags.Usage = func() {
+		fmt.Fprintln(flags.Output(), "usage: bash-butler export [-f md|json|html] [-o file] <session|latest>")
+		flags.PrintDefaults()
+	}
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+	if flags.NArg() != 1 {
+		flags.Usage()
+		return errors.New("expected a session ID")
+	}
+	session, err := loadSession(flags.Arg(0))
+	if err != nil {
+		return err
+	}
+	if *format == "" {
+		*format = export.FormatFor(*output)
+	}
+	data, err := export.Render(session, *format)
+	if err != nil {
+		return err
+	}
+	if *output == "" {
+		_, err = os.Stdout.Write(data)
+		return err
+	}
+	return os.WriteFile(*output, data, 0o644)
+}
+
+// loadSession opens a stored session by ID or ID prefix. "latest" is the newest one.
+func loadSession(id string) (*sessions.Session, error) {
+	store, err := sessions.NewDefaultStore()
+	if err != nil {
+		return nil, err
+	}
+	if id == "latest" {
+		ids, err := store.IDs()
+		if err != nil {
+			return nil, err
+		}
+		if len(ids) == 0 {
+			return nil, sessions.ErrNotFound
+		}
+		id = ids[0]
+	}
+	return store.Load(id)
+}
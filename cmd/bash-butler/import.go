@@ -0,0 +1,56 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/falbanese9484/terminal-chat/importer"
+	"github.com/falbanese9484/terminal-chat/sessions"
+)
+
+// importCommand implements `bash-butler import [-f chatgpt|openai] <file>...`.
+func importCommand(args []string) error {
+	flags := flag.NewFlagSet("import", flag.ContinueOnError)
+	format := flags.String("f", "", "format: chatgpt or openai (default: detect)")
+	flags.Usage = func() {
+		fmt.Fprintln(flags.Output(), "usage: bash-butler import [-f chatgpt|openai] <file|->...")
+		flags.PrintDefaults()
+	}
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+	if flags.NArg() == 0 {
+		flags.Usage()
+		return errors.New("expected a file to import")
+	}
+	store, err := sessions.NewDefaultStore()
+	if err != nil {
+		return err
+	}
+	added, updated := 0, 0
+	for _, path := range flags.Args() {
+		var data []byte
+		if path == "-" {
+			data, err = io.ReadAll(os.Stdin)
+		} else {
+			data, err = os.ReadFile(path)
+		}
+		if err != nil {
+			return err
+		}
+		imported, err := importer.Import(data, *format)
+		if err != nil {
+			return fmt.Errorf("%s: %w", path, err)
+		}
+		a, u, err := importer.Save(store, imported)
+		added, updated = added+a, updated+u
+		if err != nil {
+			return err
+		}
+	}
+	fmt.Printf("Imported %d new and %d updated sessions into %s\n", added, updated, store.Dir)
+	return nil
+}
@@ -1,6 +1,9 @@
 package main
 
 import (
+	"errors"
+	"flag"
+	"fmt"
 	"log"
 	"os"
 
@@ -10,8 +13,13 @@ import (
 	"github.com/charmbracelet/glamour"
 	"github.com/charmbracelet/x/term"
 	"github.com/falbanese9484/terminal-chat/chat"
+	"github.com/falbanese9484/terminal-chat/compaction"
+	"github.com/falbanese9484/terminal-chat/history"
 	"github.com/falbanese9484/terminal-chat/logger"
+	"github.com/falbanese9484/terminal-chat/memory"
+	"github.com/falbanese9484/terminal-chat/personas"
 	"github.com/falbanese9484/terminal-chat/providers/models"
+	"github.com/falbanese9484/terminal-chat/sessions"
 	"github.com/falbanese9484/terminal-chat/types"
 	"github.com/falbanese9484/terminal-chat/ui"
 	"github.com/falbanese9484/terminal-chat/ui/components"
@@ -20,22 +28,66 @@ import (
 	"github.com/falbanese9484/terminal-chat/ui/styles"
 )
 
+const defaultModel = "llama3.2:latest"
+
+// subcommands run instead of the chat UI when named as the first argument.
+var subcommands = map[string]func(args []string) error{
+	"export":    exportCommand,
+	"import":    importCommand,
+	"search":    searchCommand,
+	"embed":     embedCommand,
+	"tokenizer": tokenizerCommand,
+	"open":      openCommand,
+}
+
 func main() {
 	args := os.Args
-	var modelName string
 	if len(args) > 1 {
-		modelName = args[1]
-	} else {
-		modelName = "llama3.2:latest"
+		if run, ok := subcommands[args[1]]; ok {
+			if err := run(args[2:]); err != nil && !errors.Is(err, flag.ErrHelp) {
+				fmt.Fprintln(os.Stderr, "bash-butler "+args[1]+":", err)
+				os.Exit(1)
+			}
+			return
+		}
 	}
-	p := tea.NewProgram(initialModel(modelName), tea.WithAltScreen())
-
-	if _, err := p.Run(); err != nil {
+	persona := flag.String("persona", os.Getenv("BUTLER_PERSONA"), "persona to start with, see /persona")
+	flag.Usage = func() {
+		fmt.Fprintln(flag.CommandLine.Output(), "usage: bash-butler [-persona name] [model]")
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+	modelName := defaultModel
+	if flag.NArg() > 0 {
+		modelName = flag.Arg(0)
+	}
+	if err := runUI(initialModel(modelName, *persona)); err != nil {
 		log.Fatal(err)
 	}
 }
 
-func initialModel(modelName string) tea.Model {
+// newProviders registers every configured provider, so compare mode can mix them and
+// embeddings can come from any that support them. Ollama is the default.
+func newProviders(logger *logger.Logger, modelName string) *types.ProviderRegistry {
+	ollama := models.NewOllamaProvider(logger, modelName, types.NewModelRefresher(3600))
+	providers := types.NewProviderRegistry("ollama")
+	providers.Register("ollama", types.NewProviderService(ollama))
+	if openRouter, err := models.NewOpenRouter(logger, modelName, types.NewModelRefresher(3600)); err == nil {
+		providers.Register("openrouter", types.NewProviderService(openRouter))
+	}
+	if openAI, err := models.NewOpenAICompatible(logger, modelName, types.NewModelRefresher(3600)); err == nil {
+		providers.Register("openai", types.NewProviderService(openAI))
+	}
+	return providers
+}
+
+func runUI(model *uiModels.ChatModel) error {
+	p := tea.NewProgram(model, tea.WithAltScreen())
+	_, err := p.Run()
+	return err
+}
+
+func initialModel(modelName, personaName string) *uiModels.ChatModel {
 	// Get screen dimensions
 	screenWidth, _, _ := term.GetSize(0)
 	mainWidth := screenWidth * 2 / 3
@@ -52,35 +104,69 @@ func initialModel(modelName string) tea.Model {
 		glamour.WithWordWrap(80),
 	)
 
-	// initialize the ModelRefresher
-	modelRefresher := types.NewModelRefresher(3600)
-
-	// Initialize provider
-	// openRouter, err := models.NewOpenRouter(logger, modelName, modelRefresher)
-	ollama := models.NewOllamaProvider(logger, modelName, modelRefresher)
-	//if err != nil {
-	//	logger.Fatal("failed to initialize openRouter", "error", err)
-	//}
-	modelProvider := types.NewProviderService(ollama)
+	// Initialize providers
+	providers := newProviders(logger, modelName)
+	modelProvider, _ := providers.Get(providers.Default)
 
 	// Initialize chat bus and response channel
 	bus := chat.NewChatBus(logger, modelProvider)
 	byteReader := make(chan *types.ChatResponse, 100)
 
+	// Open the session store
+	store, err := sessions.NewDefaultStore()
+	if err != nil {
+		logger.Fatal("failed to open session store", "error", err)
+	}
+
 	// Create chat service
 	chatService := &services.ChatService{
 		Bus:               bus,
 		ByteReader:        byteReader,
 		CurrentAIResponse: "",
 		ModelProvider:     modelProvider,
+		Providers:         providers,
 		ModelName:         modelName,
 		Logger:            logger,
+		Session:           sessions.NewSession(modelName),
+		Store:             store,
+		Context:           compaction.DefaultOptions(),
+	}
+
+	// Set up the persona, which may switch the model
+	if personaName != "" {
+		library, err := personas.NewDefaultLibrary()
+		if err != nil {
+			logger.Fatal("failed to open personas", "error", err)
+		}
+		persona, err := library.Get(personaName)
+		if err != nil {
+			log.Fatal(err)
+		}
+		if err := chatService.SetPersona(persona); err != nil {
+			log.Fatal(err)
+		}
+		modelName = chatService.ModelName
+	}
+
+	// Load the long-term memories every conversation shares. A file that can't be read
+	// is left alone rather than saved over.
+	if memories, err := memory.LoadDefault(); err != nil {
+		logger.Warn("failed to load memories", "error", err)
+	} else {
+		chatService.Memory = memories
 	}
+	chatService.ProposeMemories = os.Getenv("BUTLER_PROPOSE_MEMORIES") == "1"
 
 	// Create UI components
 	inputArea := components.NewInputArea(renderer)
-	chatView := components.NewChatView(mainWidth, screenWidth/2, renderer)
+	promptHistory, err := history.LoadDefault()
+	if err != nil {
+		logger.Warn("failed to load prompt history", "error", err)
+	}
+	inputArea.History = promptHistory
+	chatView := components.NewChatView(mainWidth, screenWidth/2, styles.MarkdownStyle())
 	modelSelector := components.NewModelSelector(mainWidth, screenWidth/8, renderer, logger)
+	commandPopup := components.NewCommandPopup(mainWidth)
 
 	// Initialize chatView with logo and connection message
 	logoContent := styles.LogoStyle.Render(ui.LOGO) + styles.TitleStyle.Render(ui.PHRASE) + "\n" +
@@ -95,9 +181,12 @@ func initialModel(modelName string) tea.Model {
 		InputArea:     inputArea,
 		ChatView:      chatView,
 		ChatService:   chatService,
+		Tabs:          []*uiModels.Tab{{ChatService: chatService, ChatView: chatView}},
+		Sidebar:       components.NewSidebar(),
 		Logger:        logger,
 		Renderer:      renderer,
 		Err:           nil,
 		ModelSelector: modelSelector,
+		CommandPopup:  commandPopup,
 	}
 }
@@ -0,0 +1,86 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/charmbracelet/x/term"
+	"github.com/falbanese9484/terminal-chat/search"
+	"github.com/falbanese9484/terminal-chat/sessions"
+)
+
+// searchCommand implements `bash-butler search [-n count] [-open] <query>`.
+func searchCommand(args []string) error {
+	flags := flag.NewFlagSet("search", flag.ContinueOnError)
+	limit := flags.Int("n", 10, "number of results")
+	open := flags.Bool("open", false, "open the best match in the chat UI")
+	model := flags.String("model", defaultModel, "model to continue with when opening")
+	flags.Usage = func() {
+		fmt.Fprintln(flags.Output(), "usage: bash-butler search [-n count] [-open] <query>")
+		flags.PrintDefaults()
+	}
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+	query := strings.Join(flags.Args(), " ")
+	if strings.TrimSpace(query) == "" {
+		flags.Usage()
+		return errors.New("expected a query")
+	}
+	store, err := sessions.NewDefaultStore()
+	if err != nil {
+		return err
+	}
+	idx, err := search.Open(store)
+	if err != nil {
+		return err
+	}
+	highlight := func(s string) string { return s }
+	if term.IsTerminal(os.Stdout.Fd()) {
+		highlight = func(s string) string { return "\x1b[1m" + s + "\x1b[0m" }
+	}
+	results := idx.Search(query, *limit, highlight)
+	if len(results) == 0 {
+		return fmt.Errorf("no matches for %q", query)
+	}
+	if *open {
+		m := initialModel(*model, os.Getenv("BUTLER_PERSONA"))
+		if err := m.Open(results[0].SessionID, results[0].MessageID); err != nil {
+			return err
+		}
+		return runUI(m)
+	}
+	for i, r := range results {
+		fmt.Printf("%d. %s · %s · %.2f\n   %s\n   bash-butler open %s %s\n", i+1, r.Title, r.Role, r.Score, r.Snippet, r.SessionID, r.MessageID)
+	}
+	return nil
+}
+
+// openCommand implements `bash-butler open [-model name] <session> [message]`.
+func openCommand(args []string) error {
+	flags := flag.NewFlagSet("open", flag.ContinueOnError)
+	model := flags.String("model", defaultModel, "model to continue with")
+	flags.Usage = func() {
+		fmt.Fprintln(flags.Output(), "usage: bash-butler open [-model name] <session|latest> [message]")
+		flags.PrintDefaults()
+	}
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+	if flags.NArg() < 1 || flags.NArg() > 2 {
+		flags.Usage()
+		return errors.New("expected a session ID")
+	}
+	session, err := loadSession(flags.Arg(0))
+	if err != nil {
+		return err
+	}
+	m := initialModel(*model, os.Getenv("BUTLER_PERSONA"))
+	if err := m.Open(session.ID, flags.Arg(1)); err != nil {
+		return err
+	}
+	return runUI(m)
+}
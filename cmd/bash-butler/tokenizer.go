@@ -0,0 +1,52 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/falbanese9484/terminal-chat/tokenizer"
+)
+
+// tokenizerCommand implements `bash-butler tokenizer fetch [-from file] [encoding...]`,
+// which puts the vocabularies used for exact token counts in place.
+func tokenizerCommand(args []string) error {
+	flags := flag.NewFlagSet("tokenizer", flag.ContinueOnError)
+	from := flags.String("from", "", "install a .tiktoken file downloaded elsewhere instead of fetching it")
+	flags.Usage = func() {
+		fmt.Fprintln(flags.Output(), "usage: bash-butler tokenizer fetch [-from file] [encoding...]")
+		fmt.Fprintln(flags.Output(), "Encodings: "+strings.Join(tokenizer.Encodings(), ", ")+" (default all).")
+		flags.PrintDefaults()
+	}
+	if len(args) == 0 || args[0] != "fetch" {
+		flags.Usage()
+		return errors.New("expected the fetch subcommand")
+	}
+	if err := flags.Parse(args[1:]); err != nil {
+		return err
+	}
+	names := flags.Args()
+	if *from != "" && len(names) != 1 {
+		return errors.New("-from installs one encoding, name it")
+	}
+	if len(names) == 0 {
+		names = tokenizer.Encodings()
+	}
+	for _, name := range names {
+		var path string
+		var err error
+		if *from != "" {
+			path, err = tokenizer.Install(name, *from)
+		} else {
+			path, err = tokenizer.Fetch(context.Background(), name)
+		}
+		if err != nil {
+			return err
+		}
+		fmt.Fprintln(os.Stderr, "saved", name, "to", path)
+	}
+	return nil
+}
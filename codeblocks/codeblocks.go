@@ -0,0 +1,105 @@
+package codeblocks
+
+import (
+	"fmt"
+	"strings"
+)
+
+type Block struct {
+	// A fenced code block. Start and End are the line numbers of the opening and
+	// closing fences, counted from zero.
+	Language string
+	Code     string
+	Start    int
+	End      int
+}
+
+// Extract returns the fenced code blocks in markdown, in order. An unclosed fence runs
+// to the end of the text, which is what a renderer would show as well.
+func Extract(markdown string) []Block {
+	blocks := []Block{}
+	lines := strings.Split(markdown, "\n")
+	var (
+		current *Block
+		fence   string
+		code    []string
+	)
+	for i, line := range lines {
+		trimmed := strings.TrimSpace(line)
+		if current == nil {
+			if f := openingFence(trimmed); f != "" {
+				fence = f
+				current = &Block{Language: infoLanguage(trimmed[len(f):]), Start: i}
+				code = []string{}
+			}
+			continue
+		}
+		if strings.HasPrefix(trimmed, fence) && strings.Trim(trimmed, fence[:1]) == "" {
+			current.Code = strings.Join(code, "\n")
+			current.End = i
+			blocks = append(blocks, *current)
+			current = nil
+			continue
+		}
+		code = append(code, line)
+	}
+	if current != nil {
+		current.Code = strings.Join(code, "\n")
+		current.End = len(lines)
+		blocks = append(blocks, *current)
+	}
+	return blocks
+}
+
+// openingFence returns the run of backticks or tildes opening a code block, or "".
+func openingFence(line string) string {
+	if !strings.HasPrefix(line, "```") && !strings.HasPrefix(line, "~~~") {
+		return ""
+	}
+	n := 0
+	for n < len(line) && line[n] == line[0] {
+		n++
+	}
+	// Backtick fences can't have backticks in their info string.
+	if line[0] == '`' && strings.Contains(line[n:], "`") {
+		return ""
+	}
+	return line[:n]
+}
+
+func infoLanguage(info string) string {
+	fields := strings.Fields(info)
+	if len(fields) == 0 {
+		return ""
+	}
+	return fields[0]
+}
+
+// Number puts a "[N] language" label above each code block so blocks can be referred to
+// by number. The label is indented like its fence and set off by a blank line, so it
+// can't run into a preceding paragraph.
+func Number(markdown string) string {
+	blocks := Extract(markdown)
+	if len(blocks) == 0 {
+		return markdown
+	}
+	lines := strings.Split(markdown, "\n")
+	out := make([]string, 0, len(lines)+2*len(blocks))
+	next := 0
+	for i, line := range lines {
+		if next < len(blocks) && blocks[next].Start == i {
+			indent := line[:len(line)-len(strings.TrimLeft(line, " \t"))]
+			label := fmt.Sprintf("%s**[%d]**", indent, next+1)
+			if blocks[next].Language != "" {
+				label += " _" + blocks[next].Language + "_"
+			}
+			if len(out) > 0 && strings.TrimSpace(out[len(out)-1]) != "" {
+				out = append(out, "")
+			}
+			out = append(out, label)
+			next++
+		}
+		out = append(out, line)
+	}
+	return strings.Join(out, "\n")
+}
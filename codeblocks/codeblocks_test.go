@@ -0,0 +1,61 @@
+package codeblocks
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestExtract(t *testing.T) {
+	tests := []struct {
+		name     string
+		markdown string
+		want     []Block
+	}{
+		{"none", "just *text*", []Block{}},
+		{
+			"one",
+			"Run this:\n```sh\nls -la\n```\ndone",
+			[]Block{{Language: "sh", Code: "ls -la", Start: 1, End: 3}},
+		},
+		{
+			"info string after the language",
+			"```go title=main.go\npackage main\n```",
+			[]Block{{Language: "go", Code: "package main", Start: 0, End: 2}},
+		},
+		{
+			"tildes and a longer fence",
+			"~~~~\na\n~~~\nb\n~~~~",
+			[]Block{{Code: "a\n~~~\nb", Start: 0, End: 4}},
+		},
+		{
+			"indented fence keeps the code as written",
+			"  ```py\n  print(1)\n  ```",
+			[]Block{{Language: "py", Code: "  print(1)", Start: 0, End: 2}},
+		},
+		{
+			"backticks in the info string aren't a fence",
+			"```not `code`\n",
+			[]Block{},
+		},
+		{
+			"unclosed runs to the end",
+			"```\nx\ny",
+			[]Block{{Code: "x\ny", Start: 0, End: 3}},
+		},
+		{
+			"several",
+			"```a\n1\n```\ntext\n```b\n2\n```",
+			[]Block{
+				{Language: "a", Code: "1", Start: 0, End: 2},
+				{Language: "b", Code: "2", Start: 4, End: 6},
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := Extract(tt.markdown); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Extract(%q) = %+v, want %+v", tt.markdown, got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,212 @@
+package codeblocks
+
+import (
+	"fmt"
+	"strings"
+)
+
+const (
+	// diffContext is the number of unchanged lines shown around each change.
+	diffContext = 3
+	// maxDiffLines bounds the inputs to Diff. Its time grows with their length times
+	// the number of changed lines.
+	maxDiffLines = 4000
+)
+
+type op struct {
+	kind byte // ' ', '-' or '+'
+	line string
+}
+
+// Diff returns a unified diff turning old into new, or "" when they are the same.
+// Inputs too long to compare line by line are reported as a single replacement.
+func Diff(old, new string) string {
+	if old == new {
+		return ""
+	}
+	a, b := splitLines(old), splitLines(new)
+	if len(a) > maxDiffLines || len(b) > maxDiffLines {
+		return fmt.Sprintf("@@ -1,%d +1,%d @@\n(files too large to compare line by line)\n", len(a), len(b))
+	}
+	return unified(lineOps(a, b))
+}
+
+func splitLines(s string) []string {
+	if s == "" {
+		return nil
+	}
+	return strings.Split(strings.TrimSuffix(s, "\n"), "\n")
+}
+
+// lineOps lists the edits from a to b with Myers' algorithm. Splitting on the middle
+// snake keeps memory linear in the input instead of building a full LCS table.
+func lineOps(a, b []string) []op {
+	ops := make([]op, 0, len(a)+len(b))
+	var diff func(a, b []string)
+	diff = func(a, b []string) {
+		for len(a) > 0 && len(b) > 0 && a[0] == b[0] {
+			ops = append(ops, op{' ', a[0]})
+			a, b = a[1:], b[1:]
+		}
+		n := 0
+		for n < len(a) && n < len(b) && a[len(a)-1-n] == b[len(b)-1-n] {
+			n++
+		}
+		suffix := a[len(a)-n:]
+		a, b = a[:len(a)-n], b[:len(b)-n]
+		x, y := -1, -1
+		if len(a) > 0 && len(b) > 0 {
+			x, y = bisect(a, b)
+		}
+		if x < 0 {
+			for _, line := range a {
+				ops = append(ops, op{'-', line})
+			}
+			for _, line := range b {
+				ops = append(ops, op{'+', line})
+			}
+		} else {
+			diff(a[:x], b[:y])
+			diff(a[x:], b[y:])
+		}
+		for _, line := range suffix {
+			ops = append(ops, op{' ', line})
+		}
+	}
+	diff(a, b)
+	return ops
+}
+
+// bisect finds where the shortest edit script from a to b crosses its middle, walking
+// from both ends at once. It returns -1, -1 when a and b have nothing in common.
+func bisect(a, b []string) (int, int) {
+	n, m := len(a), len(b)
+	maxD := (n + m + 1) / 2
+	offset := maxD
+	forward := make([]int, 2*maxD+2)
+	reverse := make([]int, 2*maxD+2)
+	for i := range forward {
+		forward[i], reverse[i] = -1, -1
+	}
+	forward[offset+1], reverse[offset+1] = 0, 0
+	delta := n - m
+	// With an odd delta the paths meet on a forward step, otherwise on a reverse one.
+	odd := delta%2 != 0
+	// Diagonals that ran off the edge of the grid are skipped from then on.
+	kStart, kEnd, rStart, rEnd := 0, 0, 0, 0
+	for d := 0; d < maxD; d++ {
+		for k := -d + kStart; k <= d-kEnd; k += 2 {
+			i := offset + k
+			x := 0
+			if k == -d || (k != d && forward[i-1] < forward[i+1]) {
+				x = forward[i+1]
+			} else {
+				x = forward[i-1] + 1
+			}
+			y := x - k
+			for x < n && y < m && a[x] == b[y] {
+				x++
+				y++
+			}
+			forward[i] = x
+			switch {
+			case x > n:
+				kEnd += 2
+			case y > m:
+				kStart += 2
+			case odd:
+				if j := offset + delta - k; j >= 0 && j < len(reverse) && reverse[j] != -1 && x >= n-reverse[j] {
+					return x, y
+				}
+			}
+		}
+		for k := -d + rStart; k <= d-rEnd; k += 2 {
+			i := offset + k
+			x := 0
+			if k == -d || (k != d && reverse[i-1] < reverse[i+1]) {
+				x = reverse[i+1]
+			} else {
+				x = reverse[i-1] + 1
+			}
+			y := x - k
+			for x < n && y < m && a[n-x-1] == b[m-y-1] {
+				x++
+				y++
+			}
+			reverse[i] = x
+			switch {
+			case x > n:
+				rEnd += 2
+			case y > m:
+				rStart += 2
+			case !odd:
+				if j := offset + delta - k; j >= 0 && j < len(forward) && forward[j] != -1 {
+					fx := forward[j]
+					if fx >= n-x {
+						return fx, offset + fx - j
+					}
+				}
+			}
+		}
+	}
+	return -1, -1
+}
+
+// unified groups the edits into hunks with a few lines of context on either side.
+func unified(ops []op) string {
+	var sb strings.Builder
+	for start := 0; start < len(ops); {
+		// Find the next change and the extent of its hunk.
+		first := start
+		for first < len(ops) && ops[first].kind == ' ' {
+			first++
+		}
+		if first == len(ops) {
+			break
+		}
+		from := max(first-diffContext, start)
+		to := first
+		for to < len(ops) {
+			if ops[to].kind != ' ' {
+				to++
+				continue
+			}
+			run := to
+			for run < len(ops) && ops[run].kind == ' ' {
+				run++
+			}
+			if run == len(ops) || run-to > 2*diffContext {
+				to = min(to+diffContext, len(ops))
+				break
+			}
+			to = run
+		}
+
+		oldLine, newLine := 1, 1
+		for _, o := range ops[:from] {
+			if o.kind != '+' {
+				oldLine++
+			}
+			if o.kind != '-' {
+				newLine++
+			}
+		}
+		oldCount, newCount := 0, 0
+		for _, o := range ops[from:to] {
+			if o.kind != '+' {
+				oldCount++
+			}
+			if o.kind != '-' {
+				newCount++
+			}
+		}
+		fmt.Fprintf(&sb, "@@ -%d,%d +%d,%d @@\n", oldLine, oldCount, newLine, newCount)
+		for _, o := range ops[from:to] {
+			sb.WriteByte(o.kind)
+			sb.WriteString(o.line)
+			sb.WriteByte('\n')
+		}
+		start = to
+	}
+	return sb.String()
+}
@@ -0,0 +1,115 @@
+package codeblocks
+
+import (
+	"math/rand"
+	"strings"
+	"testing"
+)
+
+func TestDiff(t *testing.T) {
+	tests := []struct {
+		name     string
+		old, new string
+		want     string
+	}{
+		{"same", "a\nb\n", "a\nb\n", ""},
+		{"new file", "", "a\nb\n", "@@ -1,0 +1,2 @@\n+a\n+b\n"},
+		{"emptied", "a\n", "", "@@ -1,1 +1,0 @@\n-a\n"},
+		{"changed line", "a\nb\nc\n", "a\nB\nc\n", "@@ -1,3 +1,3 @@\n a\n-b\n+B\n c\n"},
+		{
+			"context is trimmed",
+			"1\n2\n3\n4\n5\n6\n7\n8\n9\n",
+			"1\n2\n3\n4\nfive\n6\n7\n8\n9\n",
+			"@@ -2,7 +2,7 @@\n 2\n 3\n 4\n-5\n+five\n 6\n 7\n 8\n",
+		},
+		{
+			"distant changes get their own hunks",
+			"a\n1\n2\n3\n4\n5\n6\n7\n8\nb\n",
+			"A\n1\n2\n3\n4\n5\n6\n7\n8\nB\n",
+			"@@ -1,4 +1,4 @@\n-a\n+A\n 1\n 2\n 3\n@@ -7,4 +7,4 @@\n 6\n 7\n 8\n-b\n+B\n",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := Diff(tt.old, tt.new); got != tt.want {
+				t.Errorf("Diff(%q, %q) =\n%s\nwant\n%s", tt.old, tt.new, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestDiffTooLarge(t *testing.T) {
+	big := strings.Repeat("x\n", maxDiffLines+1)
+	if got := Diff(big, "y\n"); !strings.Contains(got, "too large") {
+		t.Errorf("Diff of %d lines = %q, want it reported as too large", maxDiffLines+1, got)
+	}
+}
+
+// TestLineOpsMinimal checks the edits against a brute force LCS on random inputs: they
+// must turn a into b, and be as few as the longest common subsequence allows.
+func TestLineOpsMinimal(t *testing.T) {
+	rng := rand.New(rand.NewSource(1))
+	random := func() []string {
+		lines := make([]string, rng.Intn(12))
+		for i := range lines {
+			lines[i] = string(rune('a' + rng.Intn(4)))
+		}
+		return lines
+	}
+	for i := 0; i < 2000; i++ {
+		a, b := random(), random()
+		ops := lineOps(a, b)
+		var gotA, gotB []string
+		edits := 0
+		for _, o := range ops {
+			if o.kind != '+' {
+				gotA = append(gotA, o.line)
+			}
+			if o.kind != '-' {
+				gotB = append(gotB, o.line)
+			}
+			if o.kind != ' ' {
+				edits++
+			}
+		}
+		if strings.Join(gotA, ",") != strings.Join(a, ",") || strings.Join(gotB, ",") != strings.Join(b, ",") {
+			t.Fatalf("lineOps(%q, %q) = %v doesn't turn one into the other", a, b, ops)
+		}
+		if want := len(a) + len(b) - 2*lcsLength(a, b); edits != want {
+			t.Fatalf("lineOps(%q, %q) made %d edits, want %d", a, b, edits, want)
+		}
+	}
+}
+
+func lcsLength(a, b []string) int {
+	prev := make([]int, len(b)+1)
+	for i := range a {
+		cur := make([]int, len(b)+1)
+		for j := range b {
+			if a[i] == b[j] {
+				cur[j+1] = prev[j] + 1
+			} else {
+				cur[j+1] = max(prev[j+1], cur[j])
+			}
+		}
+		prev = cur
+	}
+	return prev[len(b)]
+}
+
+func BenchmarkDiffLarge(b *testing.B) {
+	var old, new strings.Builder
+	for i := 0; i < maxDiffLines; i++ {
+		line := strings.Repeat("x", i%40)
+		old.WriteString(line + "\n")
+		if i%50 == 0 {
+			new.WriteString("changed\n")
+			continue
+		}
+		new.WriteString(line + "\n")
+	}
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		Diff(old.String(), new.String())
+	}
+}
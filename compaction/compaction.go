@@ -0,0 +1,151 @@
+package compaction
+
+import (
+	"fmt"
+	"os"
+	"strconv"
+
+	"github.com/falbanese9484/terminal-chat/tokenizer"
+	"github.com/falbanese9484/terminal-chat/types"
+)
+
+type Strategy string
+
+const (
+	// Off sends the whole conversation and leaves it to the provider to complain.
+	Off Strategy = "off"
+	// DropOldest leaves out the oldest messages until the rest fits.
+	DropOldest Strategy = "drop-oldest"
+	// KeepLast sends only the last KeepLast messages, and drops more if even those
+	// don't fit.
+	KeepLast Strategy = "keep-last"
+	// Summarize replaces the oldest messages with a summary written by a model.
+	Summarize Strategy = "summarize"
+)
+
+var Strategies = []Strategy{Off, DropOldest, KeepLast, Summarize}
+
+const (
+	// DefaultContextLength is assumed for models whose provider doesn't report one.
+	DefaultContextLength = 8192
+	// DefaultKeepLast is how many messages keep-last keeps unless told otherwise.
+	DefaultKeepLast = 10
+	// imageTokens is a rough price for an attached image; providers vary a lot.
+	imageTokens = 800
+	// messageOverhead covers the role and separators each message costs.
+	messageOverhead = 4
+)
+
+type Options struct {
+	// How the conversation is cut down to fit the model. SummaryModel is the model
+	// summaries are written with, with an optional "provider:" prefix; empty means
+	// the conversation's own model. ContextLength overrides what the provider reports;
+	// Ollama is asked to serve that window, so it can also raise Ollama's cap.
+	Strategy      Strategy
+	KeepLast      int
+	SummaryModel  string
+	ContextLength int
+}
+
+// DefaultOptions reads BUTLER_CONTEXT_STRATEGY, BUTLER_CONTEXT_KEEP,
+// BUTLER_SUMMARY_MODEL and BUTLER_CONTEXT_LENGTH, defaulting to drop-oldest.
+func DefaultOptions() Options {
+	opts := Options{Strategy: DropOldest, KeepLast: DefaultKeepLast, SummaryModel: os.Getenv("BUTLER_SUMMARY_MODEL")}
+	if s, err := ParseStrategy(os.Getenv("BUTLER_CONTEXT_STRATEGY")); err == nil && s != "" {
+		opts.Strategy = s
+	}
+	if n, err := strconv.Atoi(os.Getenv("BUTLER_CONTEXT_KEEP")); err == nil && n > 0 {
+		opts.KeepLast = n
+	}
+	if n, err := strconv.Atoi(os.Getenv("BUTLER_CONTEXT_LENGTH")); err == nil && n > 0 {
+		opts.ContextLength = n
+	}
+	return opts
+}
+
+// ParseStrategy checks a strategy name. An empty name is returned as is.
+func ParseStrategy(name string) (Strategy, error) {
+	for _, s := range Strategies {
+		if string(s) == name {
+			return s, nil
+		}
+	}
+	if name == "" {
+		return "", nil
+	}
+	return "", fmt.Errorf("unknown context strategy %q, expected one of %v", name, Strategies)
+}
+
+// MessageTokens counts what a message costs in the prompt.
+func MessageTokens(tok tokenizer.Tokenizer, m types.Message) int {
+	return tok.Count(m.Content) + len(m.Images)*imageTokens + messageOverhead
+}
+
+// Window is the context window requests are sized for, given what is known of it.
+func Window(contextLength int) int {
+	if contextLength <= 0 {
+		return DefaultContextLength
+	}
+	return contextLength
+}
+
+// Budget is what a request may spend on the prompt, leaving room for the response.
+func Budget(contextLength int) int {
+	contextLength = Window(contextLength)
+	return contextLength - min(1024, contextLength/4)
+}
+
+type Result struct {
+	// Messages is what gets sent, Dropped the leading messages left out to make it
+	// fit. Tokens is the count for Messages plus the system prompt, exact only when
+	// the tokenizer is.
+	Messages []types.Message
+	Dropped  []types.Message
+	Tokens   int
+	Exact    bool
+	Budget   int
+	// Summarized is how many messages a summary stands in for; the caller fills it in.
+	Summarized int
+}
+
+// Compacted reports whether anything was left out of the request.
+func (r *Result) Compacted() bool {
+	return len(r.Dropped) > 0 || r.Summarized > 0
+}
+
+// Fit cuts messages down to budget as the strategy says. The last message is always
+// kept, and the first one kept is a user message so the conversation doesn't open with
+// an orphaned answer. Summarize drops like DropOldest; writing the summary of what was
+// dropped is up to the caller.
+func Fit(tok tokenizer.Tokenizer, system string, messages []types.Message, budget int, opts Options) Result {
+	tokens := tok.Count(system)
+	for _, m := range messages {
+		tokens += MessageTokens(tok, m)
+	}
+	start := 0
+	if opts.Strategy == KeepLast {
+		keep := opts.KeepLast
+		if keep <= 0 {
+			keep = DefaultKeepLast
+		}
+		for ; start < len(messages)-keep; start++ {
+			tokens -= MessageTokens(tok, messages[start])
+		}
+	}
+	if opts.Strategy != Off {
+		for ; tokens > budget && start < len(messages)-1; start++ {
+			tokens -= MessageTokens(tok, messages[start])
+		}
+		for start > 0 && start < len(messages)-1 && messages[start].Role != types.RoleUser {
+			tokens -= MessageTokens(tok, messages[start])
+			start++
+		}
+	}
+	return Result{
+		Messages: messages[start:],
+		Dropped:  messages[:start],
+		Tokens:   tokens,
+		Exact:    tok.Exact(),
+		Budget:   budget,
+	}
+}
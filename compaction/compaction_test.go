@@ -0,0 +1,111 @@
+package compaction
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/falbanese9484/terminal-chat/tokenizer/tokenizertest"
+	"github.com/falbanese9484/terminal-chat/types"
+)
+
+// conversation alternates user and assistant messages of six tokens each, counting
+// messageOverhead.
+func conversation(n int) []types.Message {
+	messages := []types.Message{}
+	for i := 0; i < n; i++ {
+		role := types.RoleUser
+		if i%2 == 1 {
+			role = types.RoleAssistant
+		}
+		messages = append(messages, types.Message{ID: string(rune('a' + i)), Role: role, Content: "one two"})
+	}
+	return messages
+}
+
+func ids(messages []types.Message) string {
+	var sb strings.Builder
+	for _, m := range messages {
+		sb.WriteString(m.ID)
+	}
+	return sb.String()
+}
+
+func TestFit(t *testing.T) {
+	tests := []struct {
+		name     string
+		messages []types.Message
+		budget   int
+		opts     Options
+		want     string
+		tokens   int
+	}{
+		{"fits", conversation(4), 100, Options{Strategy: DropOldest}, "abcd", 24},
+		{"off sends everything", conversation(6), 10, Options{Strategy: Off}, "abcdef", 36},
+		{"drop oldest", conversation(6), 24, Options{Strategy: DropOldest}, "cdef", 24},
+		// Dropping a alone would fit, but b would then open the conversation.
+		{"drop oldest starts on a user message", conversation(6), 30, Options{Strategy: DropOldest}, "cdef", 24},
+		{"the last message is always kept", conversation(4), 1, Options{Strategy: DropOldest}, "d", 6},
+		{"keep last", conversation(8), 100, Options{Strategy: KeepLast, KeepLast: 4}, "efgh", 24},
+		{"keep last starts on a user message", conversation(8), 100, Options{Strategy: KeepLast, KeepLast: 3}, "gh", 12},
+		{"keep last still fits the budget", conversation(8), 12, Options{Strategy: KeepLast, KeepLast: 4}, "gh", 12},
+		{"keep last defaults", conversation(14), 1000, Options{Strategy: KeepLast}, "efghijklmn", 60},
+		{"summarize drops like drop oldest", conversation(6), 24, Options{Strategy: Summarize}, "cdef", 24},
+		{"empty", nil, 10, Options{Strategy: DropOldest}, "", 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := Fit(tokenizertest.Words{}, "", tt.messages, tt.budget, tt.opts)
+			if ids(got.Messages) != tt.want || got.Tokens != tt.tokens {
+				t.Errorf("Fit kept %q (%d tokens), want %q (%d tokens)", ids(got.Messages), got.Tokens, tt.want, tt.tokens)
+			}
+			if ids(got.Dropped)+ids(got.Messages) != ids(tt.messages) {
+				t.Errorf("Fit dropped %q and kept %q, want them to make up %q", ids(got.Dropped), ids(got.Messages), ids(tt.messages))
+			}
+			if got.Compacted() != (len(got.Dropped) > 0) {
+				t.Errorf("Compacted() = %v with %d dropped", got.Compacted(), len(got.Dropped))
+			}
+		})
+	}
+}
+
+func TestFitCountsSystemAndImages(t *testing.T) {
+	messages := []types.Message{{Role: types.RoleUser, Content: "look", Images: []string{"x"}}}
+	got := Fit(tokenizertest.Words{}, "be brief", messages, 10000, Options{Strategy: DropOldest})
+	if want := 2 + 1 + imageTokens + messageOverhead; got.Tokens != want {
+		t.Errorf("Tokens = %d, want %d", got.Tokens, want)
+	}
+}
+
+func TestBudget(t *testing.T) {
+	tests := []struct {
+		contextLength int
+		want          int
+	}{
+		{0, DefaultContextLength - 1024},
+		{2048, 2048 - 512},
+		{8192, 8192 - 1024},
+		{131072, 131072 - 1024},
+	}
+	for _, tt := range tests {
+		if got := Budget(tt.contextLength); got != tt.want {
+			t.Errorf("Budget(%d) = %d, want %d", tt.contextLength, got, tt.want)
+		}
+	}
+	if got := Window(0); got != DefaultContextLength {
+		t.Errorf("Window(0) = %d, want %d", got, DefaultContextLength)
+	}
+}
+
+func TestParseStrategy(t *testing.T) {
+	for _, s := range Strategies {
+		if got, err := ParseStrategy(string(s)); err != nil || got != s {
+			t.Errorf("ParseStrategy(%q) = %q, %v", s, got, err)
+		}
+	}
+	if got, err := ParseStrategy(""); err != nil || got != "" {
+		t.Errorf("ParseStrategy(\"\") = %q, %v, want it returned as is", got, err)
+	}
+	if _, err := ParseStrategy("truncate"); err == nil {
+		t.Error("ParseStrategy(\"truncate\") should fail")
+	}
+}
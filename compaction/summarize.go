@@ -0,0 +1,39 @@
+package compaction
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/falbanese9484/terminal-chat/tokenizer"
+	"github.com/falbanese9484/terminal-chat/types"
+)
+
+const summaryPrompt = `Summarize the conversation below so it can stand in for it in later turns.
+Keep facts, decisions, names, file paths, commands and open questions; drop pleasantries.
+Write plain prose or short bullet points, at most about 300 words.`
+
+// SummaryRequest asks model to condense messages, folding in an earlier summary if
+// there is one. It is streamed like any other request, so it can be cancelled. Only as
+// many messages as fit in budget go in, oldest first and at least one, so a long run of
+// dropped messages is summarized over several requests; it returns how many went in.
+func SummaryRequest(tok tokenizer.Tokenizer, provider *types.ProviderService, model, previous string, messages []types.Message, budget int) (*types.ChatRequest, int) {
+	var transcript strings.Builder
+	if previous != "" {
+		fmt.Fprintf(&transcript, "Summary of what came before:\n%s\n\n", previous)
+	}
+	tokens := tok.Count(summaryPrompt) + tok.Count(transcript.String()) + messageOverhead
+	n := 0
+	for _, m := range messages {
+		line := fmt.Sprintf("%s: %s\n\n", m.Role, m.Content)
+		tokens += tok.Count(line)
+		if n > 0 && tokens > budget {
+			break
+		}
+		transcript.WriteString(line)
+		n++
+	}
+	request := provider.GenerateRequest([]types.Message{{Role: types.RoleUser, Content: transcript.String()}})
+	request.Model = model
+	request.System = summaryPrompt
+	return request, n
+}
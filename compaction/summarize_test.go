@@ -0,0 +1,54 @@
+package compaction
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/falbanese9484/terminal-chat/tokenizer/tokenizertest"
+	"github.com/falbanese9484/terminal-chat/types"
+)
+
+type stubProvider struct{}
+
+func (stubProvider) Chat(c *types.BusConnector) {}
+func (stubProvider) GenerateRequest(messages []types.Message) *types.ChatRequest {
+	return &types.ChatRequest{Messages: messages}
+}
+func (stubProvider) RetrieveModels() ([]types.Model, error) { return nil, nil }
+func (stubProvider) SetModel(model string)                  {}
+
+func TestSummaryRequest(t *testing.T) {
+	provider := types.NewProviderService(stubProvider{})
+	// Each message is "user: one two" in the transcript, three words.
+	base := tokenizertest.Words{}.Count(summaryPrompt) + messageOverhead
+	previous := "we talked"
+	tests := []struct {
+		name     string
+		previous string
+		budget   int
+		want     int
+	}{
+		{"all fit", "", base + 3*4, 4},
+		{"some fit", "", base + 3*2 + 2, 2},
+		{"previous summary counts", previous, base + 3*2 + 2, 1},
+		{"always one", "", 0, 1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			request, n := SummaryRequest(tokenizertest.Words{}, provider, "small", tt.previous, conversation(4), tt.budget)
+			if n != tt.want {
+				t.Errorf("took %d messages, want %d", n, tt.want)
+			}
+			if request.Model != "small" || request.System != summaryPrompt || len(request.Messages) != 1 {
+				t.Fatalf("request = %+v", request)
+			}
+			transcript := request.Messages[0].Content
+			if got := strings.Count(transcript, "one two"); got != n {
+				t.Errorf("transcript has %d messages, want %d", got, n)
+			}
+			if tt.previous != "" && !strings.Contains(transcript, tt.previous) {
+				t.Errorf("transcript leaves out the previous summary: %q", transcript)
+			}
+		})
+	}
+}
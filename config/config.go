@@ -0,0 +1,44 @@
+package config
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+
+	_ "github.com/joho/godotenv/autoload"
+)
+
+// Dir returns the directory bash-butler keeps its data in. It defaults to ~/.bash-butler,
+// next to the installed binary, and can be moved with BUTLER_HOME.
+func Dir() (string, error) {
+	if dir := os.Getenv("BUTLER_HOME"); dir != "" {
+		return dir, nil
+	}
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return "", fmt.Errorf("failed to find home directory: %w", err)
+	}
+	return filepath.Join(home, ".bash-butler"), nil
+}
+
+// Path joins elem onto Dir and makes sure the parent directory exists.
+func Path(elem ...string) (string, error) {
+	dir, err := Dir()
+	if err != nil {
+		return "", err
+	}
+	path := filepath.Join(append([]string{dir}, elem...)...)
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return "", fmt.Errorf("failed to make directory: %w", err)
+	}
+	return path, nil
+}
+
+// Profile names the active profile, from BUTLER_PROFILE. Per-user state such as prompt
+// history is kept separately for each profile.
+func Profile() string {
+	if profile := os.Getenv("BUTLER_PROFILE"); profile != "" {
+		return filepath.Base(profile)
+	}
+	return "default"
+}
@@ -0,0 +1,52 @@
+package export
+
+import (
+	"fmt"
+	"path/filepath"
+	"strings"
+
+	"github.com/falbanese9484/terminal-chat/sessions"
+)
+
+// Formats lists the export formats by name, which is also their file extension.
+var Formats = []string{"md", "json", "html"}
+
+// IsFormat reports whether name is one of Formats.
+func IsFormat(name string) bool {
+	for _, f := range Formats {
+		if f == name {
+			return true
+		}
+	}
+	return false
+}
+
+// FormatFor picks the format from a file extension, defaulting to markdown.
+func FormatFor(path string) string {
+	switch ext := strings.TrimPrefix(strings.ToLower(filepath.Ext(path)), "."); ext {
+	case "json", "html":
+		return ext
+	case "htm":
+		return "html"
+	default:
+		return "md"
+	}
+}
+
+// Render writes the session out in the named format.
+func Render(s *sessions.Session, format string) ([]byte, error) {
+	if s.Title == "" {
+		s.Title = s.DefaultTitle()
+	}
+	switch format {
+	case "md":
+		return []byte(Markdown(s)), nil
+	case "json":
+		return JSON(s)
+	case "html":
+		page, err := HTML(s)
+		return []byte(page), err
+	default:
+		return nil, fmt.Errorf("unknown export format %q, expected one of %s", format, strings.Join(Formats, ", "))
+	}
+}
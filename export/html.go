@@ -0,0 +1,195 @@
+package export
+
+import (
+	"bytes"
+	"fmt"
+	"html"
+	"strings"
+
+	"github.com/alecthomas/chroma/v2"
+	chromahtml "github.com/alecthomas/chroma/v2/formatters/html"
+	"github.com/alecthomas/chroma/v2/lexers"
+	chromastyles "github.com/alecthomas/chroma/v2/styles"
+	"github.com/yuin/goldmark"
+	"github.com/yuin/goldmark/ast"
+	"github.com/yuin/goldmark/extension"
+	"github.com/yuin/goldmark/renderer"
+	"github.com/yuin/goldmark/util"
+
+	"github.com/falbanese9484/terminal-chat/attachments"
+	"github.com/falbanese9484/terminal-chat/sessions"
+	"github.com/falbanese9484/terminal-chat/types"
+)
+
+// highlightStyle is the chroma style code blocks are coloured with.
+const highlightStyle = "github"
+
+const page = `<!DOCTYPE html>
+<html lang="en">
+<head>
+<meta charset="utf-8">
+<meta name="viewport" content="width=device-width, initial-scale=1">
+<title>%s</title>
+<style>
+body { font-family: -apple-system, "Segoe UI", Helvetica, Arial, sans-serif; max-width: 52rem; margin: 2rem auto; padding: 0 1rem; line-height: 1.5; color: #1f2328; }
+header { border-bottom: 1px solid #d0d7de; margin-bottom: 1.5rem; }
+.meta { color: #59636e; font-size: 0.9rem; }
+.message { margin: 1.5rem 0; padding: 0.5rem 1rem; border-radius: 6px; }
+.user { background: #f6f8fa; }
+.system { border-left: 4px solid #d0d7de; }
+.speaker { font-weight: 600; margin: 0.5rem 0; }
+.attachment { color: #59636e; font-size: 0.9rem; }
+pre { padding: 0.75rem; overflow-x: auto; border-radius: 6px; background: #f6f8fa; }
+code { font-family: ui-monospace, SFMono-Regular, Menlo, monospace; font-size: 0.9em; }
+table { border-collapse: collapse; }
+td, th { border: 1px solid #d0d7de; padding: 0.25rem 0.5rem; }
+%s
+</style>
+</head>
+<body>
+%s
+</body>
+</html>
+`
+
+// HTML renders the session as a standalone page. Markdown is converted with goldmark and
+// code blocks are highlighted by chroma, with the stylesheet inlined so the file needs
+// nothing else. Raw HTML in messages is escaped rather than passed through.
+func HTML(s *sessions.Session) (string, error) {
+	formatter := chromahtml.New(chromahtml.WithClasses(true))
+	style := chromastyles.Get(highlightStyle)
+	var css bytes.Buffer
+	if err := formatter.WriteCSS(&css, style); err != nil {
+		return "", fmt.Errorf("failed to write highlight styles: %w", err)
+	}
+	md := goldmark.New(
+		goldmark.WithExtensions(extension.GFM),
+		goldmark.WithRendererOptions(
+			renderer.WithNodeRenderers(
+				util.Prioritized(&codeRenderer{formatter: formatter, style: style}, 100),
+				util.Prioritized(rawHTMLRenderer{}, 100),
+			),
+		),
+	)
+	render := func(markdown string) (string, error) {
+		var out bytes.Buffer
+		if err := md.Convert([]byte(markdown), &out); err != nil {
+			return "", err
+		}
+		return out.String(), nil
+	}
+
+	var body strings.Builder
+	fmt.Fprintf(&body, "<header>\n<h1>%s</h1>\n<p class=\"meta\">Session <code>%s</code> · %s · %s</p>\n</header>\n",
+		html.EscapeString(s.Title), html.EscapeString(s.ID), html.EscapeString(s.Model), s.CreatedAt.Format("2006-01-02 15:04"))
+	if s.SystemPrompt != "" {
+		rendered, err := render(s.SystemPrompt)
+		if err != nil {
+			return "", err
+		}
+		fmt.Fprintf(&body, "<section class=\"message system\">\n<p class=\"speaker\">System</p>\n%s</section>\n", rendered)
+	}
+	for _, m := range s.Messages {
+		rendered, err := render(m.Content)
+		if err != nil {
+			return "", err
+		}
+		fmt.Fprintf(&body, "<section class=\"message %s\">\n<p class=\"speaker\">%s</p>\n%s", html.EscapeString(m.Role), html.EscapeString(speaker(m)), rendered)
+		for _, att := range m.Attachments {
+			if att.Kind == types.AttachmentImage {
+				fmt.Fprintf(&body, "<p class=\"attachment\">Image: %s</p>\n", html.EscapeString(att.Name))
+				continue
+			}
+			rendered, err := render(attachments.Fence(att))
+			if err != nil {
+				return "", err
+			}
+			fmt.Fprintf(&body, "<div class=\"attachment\">%s</div>\n", rendered)
+		}
+		body.WriteString("</section>\n")
+	}
+	return fmt.Sprintf(page, html.EscapeString(s.Title), css.String(), body.String()), nil
+}
+
+type codeRenderer struct {
+	// Renders fenced and indented code blocks through chroma instead of goldmark's
+	// plain <pre><code>.
+	formatter *chromahtml.Formatter
+	style     *chroma.Style
+}
+
+func (r *codeRenderer) RegisterFuncs(reg renderer.NodeRendererFuncRegisterer) {
+	reg.Register(ast.KindFencedCodeBlock, r.renderCode)
+	reg.Register(ast.KindCodeBlock, r.renderCode)
+}
+
+func (r *codeRenderer) renderCode(w util.BufWriter, source []byte, node ast.Node, entering bool) (ast.WalkStatus, error) {
+	if !entering {
+		return ast.WalkContinue, nil
+	}
+	var code bytes.Buffer
+	lines := node.Lines()
+	for i := 0; i < lines.Len(); i++ {
+		line := lines.At(i)
+		code.Write(line.Value(source))
+	}
+	language := ""
+	if fenced, ok := node.(*ast.FencedCodeBlock); ok {
+		language = string(fenced.Language(source))
+	}
+	lexer := lexers.Get(language)
+	if lexer == nil {
+		lexer = lexers.Analyse(code.String())
+	}
+	if lexer == nil {
+		lexer = lexers.Fallback
+	}
+	iterator, err := lexer.Tokenise(nil, code.String())
+	if err == nil {
+		err = r.formatter.Format(w, r.style, iterator)
+	}
+	if err != nil {
+		// Fall back to plain escaped code rather than failing the export.
+		fmt.Fprintf(w, "<pre><code>%s</code></pre>\n", html.EscapeString(code.String()))
+	}
+	return ast.WalkSkipChildren, nil
+}
+
+type rawHTMLRenderer struct{}
+
+// Messages are text, so HTML written in them is shown escaped rather than passed
+// through or, as goldmark does by default, left out.
+func (rawHTMLRenderer) RegisterFuncs(reg renderer.NodeRendererFuncRegisterer) {
+	reg.Register(ast.KindHTMLBlock, renderHTMLBlock)
+	reg.Register(ast.KindRawHTML, renderRawHTML)
+}
+
+func renderHTMLBlock(w util.BufWriter, source []byte, node ast.Node, entering bool) (ast.WalkStatus, error) {
+	if !entering {
+		return ast.WalkContinue, nil
+	}
+	block := node.(*ast.HTMLBlock)
+	var text bytes.Buffer
+	lines := block.Lines()
+	for i := 0; i < lines.Len(); i++ {
+		line := lines.At(i)
+		text.Write(line.Value(source))
+	}
+	if block.HasClosure() {
+		text.Write(block.ClosureLine.Value(source))
+	}
+	fmt.Fprintf(w, "<p>%s</p>\n", html.EscapeString(strings.TrimRight(text.String(), "\n")))
+	return ast.WalkSkipChildren, nil
+}
+
+func renderRawHTML(w util.BufWriter, source []byte, node ast.Node, entering bool) (ast.WalkStatus, error) {
+	if !entering {
+		return ast.WalkContinue, nil
+	}
+	segments := node.(*ast.RawHTML).Segments
+	for i := 0; i < segments.Len(); i++ {
+		segment := segments.At(i)
+		w.WriteString(html.EscapeString(string(segment.Value(source))))
+	}
+	return ast.WalkSkipChildren, nil
+}
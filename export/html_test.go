@@ -0,0 +1,39 @@
+package export
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/falbanese9484/terminal-chat/sessions"
+	"github.com/falbanese9484/terminal-chat/types"
+)
+
+func TestHTMLEscapesRawHTML(t *testing.T) {
+	s := sessions.NewSession("llama3.2:latest")
+	s.Title = "<b>title</b>"
+	s.Append(types.Message{Role: types.RoleUser, Content: "Why does <div> collapse?\n\n<div class=\"x\">\nblock\n</div>\n\n<script>alert(1)</script>"})
+	s.Append(types.Message{Role: types.RoleAssistant, Content: "Use `<span>` or\n\n```html\n<p>hi</p>\n```"})
+	page, err := HTML(s)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, want := range []string{
+		"Why does &lt;div&gt; collapse?",
+		"&lt;div class=&#34;x&#34;&gt;\nblock\n&lt;/div&gt;",
+		"&lt;script&gt;alert(1)&lt;/script&gt;",
+		"<code>&lt;span&gt;</code>",
+		"<title>&lt;b&gt;title&lt;/b&gt;</title>",
+	} {
+		if !strings.Contains(page, want) {
+			t.Errorf("page is missing %q", want)
+		}
+	}
+	for _, unwanted := range []string{"raw HTML omitted", "<div class", "<script>", "<span>"} {
+		if strings.Contains(page, unwanted) {
+			t.Errorf("page contains %q", unwanted)
+		}
+	}
+	if !strings.Contains(page, `class="chroma"`) {
+		t.Error("code block isn't highlighted")
+	}
+}
@@ -0,0 +1,65 @@
+package export
+
+import (
+	"bytes"
+	"encoding/json"
+	"time"
+
+	"github.com/falbanese9484/terminal-chat/sessions"
+	"github.com/falbanese9484/terminal-chat/types"
+)
+
+// JSONVersion is bumped whenever the shape of the JSON export changes.
+const JSONVersion = 1
+
+type jsonExport struct {
+	// The structured export: session metadata, usage summed over the responses, and
+	// the messages of the active branch. Image data is left out, only its details kept.
+	Version      int             `json:"version"`
+	ID           string          `json:"id"`
+	Title        string          `json:"title"`
+	Model        string          `json:"model"`
+	SystemPrompt string          `json:"system_prompt,omitempty"`
+	CreatedAt    time.Time       `json:"created_at"`
+	UpdatedAt    time.Time       `json:"updated_at"`
+	ExportedAt   time.Time       `json:"exported_at"`
+	Usage        *types.Usage    `json:"usage,omitempty"`
+	Messages     []types.Message `json:"messages"`
+}
+
+// JSON renders the session as indented JSON.
+func JSON(s *sessions.Session) ([]byte, error) {
+	out := jsonExport{
+		Version:      JSONVersion,
+		ID:           s.ID,
+		Title:        s.Title,
+		Model:        s.Model,
+		SystemPrompt: s.SystemPrompt,
+		CreatedAt:    s.CreatedAt,
+		UpdatedAt:    s.UpdatedAt,
+		ExportedAt:   time.Now(),
+		Messages:     make([]types.Message, 0, len(s.Messages)),
+	}
+	for _, m := range s.Messages {
+		if m.Usage != nil {
+			out.Usage = out.Usage.Add(m.Usage)
+		}
+		m.Images = nil
+		atts := make([]*types.Attachment, 0, len(m.Attachments))
+		for _, att := range m.Attachments {
+			copied := *att
+			copied.Data = ""
+			atts = append(atts, &copied)
+		}
+		m.Attachments = atts
+		out.Messages = append(out.Messages, m)
+	}
+	var buf bytes.Buffer
+	enc := json.NewEncoder(&buf)
+	enc.SetEscapeHTML(false)
+	enc.SetIndent("", "  ")
+	if err := enc.Encode(out); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
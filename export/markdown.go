@@ -0,0 +1,55 @@
+package export
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/falbanese9484/terminal-chat/attachments"
+	"github.com/falbanese9484/terminal-chat/sessions"
+	"github.com/falbanese9484/terminal-chat/types"
+)
+
+// Markdown renders the session as plain markdown. Messages are written from their raw
+// content, so nothing terminal specific ends up in the file.
+func Markdown(s *sessions.Session) string {
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "# %s\n\n", s.Title)
+	fmt.Fprintf(&sb, "- Session: `%s`\n", s.ID)
+	fmt.Fprintf(&sb, "- Model: `%s`\n", s.Model)
+	fmt.Fprintf(&sb, "- Created: %s\n", s.CreatedAt.Format("2006-01-02 15:04"))
+	if s.SystemPrompt != "" {
+		fmt.Fprintf(&sb, "\n## System\n\n%s\n", s.SystemPrompt)
+	}
+	for _, m := range s.Messages {
+		fmt.Fprintf(&sb, "\n## %s\n\n", speaker(m))
+		sb.WriteString(strings.TrimSpace(m.Content))
+		sb.WriteString("\n")
+		for _, att := range m.Attachments {
+			if att.Kind == types.AttachmentImage {
+				fmt.Fprintf(&sb, "\n_Image: %s_\n", attachments.Label(att))
+				continue
+			}
+			sb.WriteString("\n")
+			sb.WriteString(attachments.Fence(att))
+			sb.WriteString("\n")
+		}
+	}
+	return sb.String()
+}
+
+func speaker(m types.Message) string {
+	switch m.Role {
+	case types.RoleUser:
+		return "You"
+	case types.RoleAssistant:
+		if m.Model != "" {
+			return "Assistant (" + m.Model + ")"
+		}
+		return "Assistant"
+	default:
+		if m.Role == "" {
+			return "Unknown"
+		}
+		return strings.ToUpper(m.Role[:1]) + m.Role[1:]
+	}
+}
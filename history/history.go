@@ -0,0 +1,154 @@
+package history
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/falbanese9484/terminal-chat/config"
+)
+
+// DefaultLimit is how many prompts are kept when BUTLER_HISTORY_SIZE isn't set.
+const DefaultLimit = 1000
+
+type History struct {
+	// Entries are oldest first. Each one is stored as a JSON string on its own line so
+	// multi-line prompts survive the round trip.
+	Entries []string
+	Limit   int
+	path    string
+}
+
+// Load reads the history file at path, keeping at most limit entries. A missing file
+// is an empty history.
+func Load(path string, limit int) (*History, error) {
+	h := &History{Entries: []string{}, Limit: limit, path: path}
+	f, err := os.Open(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return h, nil
+	}
+	if err != nil {
+		return h, fmt.Errorf("failed to open history: %w", err)
+	}
+	defer f.Close()
+
+	scanner := bufio.NewScanner(f)
+	scanner.Buffer(make([]byte, 0, 64*1024), 4*1024*1024)
+	for scanner.Scan() {
+		var entry string
+		if err := json.Unmarshal(scanner.Bytes(), &entry); err != nil {
+			continue
+		}
+		h.Entries = append(h.Entries, entry)
+	}
+	if len(h.Entries) > limit {
+		h.Entries = h.Entries[len(h.Entries)-limit:]
+	}
+	return h, scanner.Err()
+}
+
+// LoadDefault loads the prompt history of the active profile.
+func LoadDefault() (*History, error) {
+	path, err := config.Path("profiles", config.Profile(), "history.jsonl")
+	if err != nil {
+		return &History{Entries: []string{}, Limit: DefaultLimit}, err
+	}
+	return Load(path, Limit())
+}
+
+// Limit reads the history size from BUTLER_HISTORY_SIZE.
+func Limit() int {
+	if size, err := strconv.Atoi(os.Getenv("BUTLER_HISTORY_SIZE")); err == nil && size > 0 {
+		return size
+	}
+	return DefaultLimit
+}
+
+// Add records a prompt, skipping blanks and immediate repeats. The entry is appended to
+// the file; once the file holds twice the limit it is rewritten to trim it back down.
+func (h *History) Add(entry string) error {
+	if strings.TrimSpace(entry) == "" {
+		return nil
+	}
+	if n := len(h.Entries); n > 0 && h.Entries[n-1] == entry {
+		return nil
+	}
+	h.Entries = append(h.Entries, entry)
+	if len(h.Entries) > h.Limit {
+		h.Entries = h.Entries[len(h.Entries)-h.Limit:]
+	}
+	if h.path == "" {
+		return nil
+	}
+	return h.append(entry)
+}
+
+func (h *History) append(entry string) error {
+	data, err := json.Marshal(entry)
+	if err != nil {
+		return err
+	}
+	f, err := os.OpenFile(h.path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0o600)
+	if err != nil {
+		return fmt.Errorf("failed to write history: %w", err)
+	}
+	_, err = f.Write(append(data, '\n'))
+	if closeErr := f.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return fmt.Errorf("failed to write history: %w", err)
+	}
+	if info, err := os.Stat(h.path); err == nil && info.Size() > h.approxSize() {
+		return h.rewrite()
+	}
+	return nil
+}
+
+// approxSize is a rough upper bound for the file size before it gets compacted.
+func (h *History) approxSize() int64 {
+	var size int64
+	for _, e := range h.Entries {
+		size += int64(len(e)) + 3
+	}
+	return size * 2
+}
+
+func (h *History) rewrite() error {
+	tmp, err := os.CreateTemp(filepath.Dir(h.path), ".history-*")
+	if err != nil {
+		return fmt.Errorf("failed to compact history: %w", err)
+	}
+	w := bufio.NewWriter(tmp)
+	for _, e := range h.Entries {
+		data, _ := json.Marshal(e)
+		w.Write(append(data, '\n'))
+	}
+	if err := w.Flush(); err != nil {
+		tmp.Close()
+		os.Remove(tmp.Name())
+		return fmt.Errorf("failed to compact history: %w", err)
+	}
+	if err := tmp.Close(); err != nil {
+		os.Remove(tmp.Name())
+		return fmt.Errorf("failed to compact history: %w", err)
+	}
+	return os.Rename(tmp.Name(), h.path)
+}
+
+// Search looks backwards from index before for an entry containing query, returning its
+// index or -1.
+func (h *History) Search(query string, before int) int {
+	before = min(before, len(h.Entries))
+	for i := before - 1; i >= 0; i-- {
+		if strings.Contains(h.Entries[i], query) {
+			return i
+		}
+	}
+	return -1
+}
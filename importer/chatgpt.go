@@ -0,0 +1,195 @@
+package importer
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/falbanese9484/terminal-chat/sessions"
+	"github.com/falbanese9484/terminal-chat/types"
+)
+
+type chatGPTConversation struct {
+	// One conversation from ChatGPT's conversations.json. Messages are nodes of a tree
+	// keyed by ID; current_node is the leaf of the branch that was on screen.
+	ID          string                 `json:"id"`
+	ConvID      string                 `json:"conversation_id"`
+	Title       string                 `json:"title"`
+	CreateTime  float64                `json:"create_time"`
+	UpdateTime  float64                `json:"update_time"`
+	Mapping     map[string]chatGPTNode `json:"mapping"`
+	CurrentNode string                 `json:"current_node"`
+	DefaultSlug string                 `json:"default_model_slug"`
+}
+
+type chatGPTNode struct {
+	ID       string          `json:"id"`
+	Message  *chatGPTMessage `json:"message"`
+	Parent   string          `json:"parent"`
+	Children []string        `json:"children"`
+}
+
+type chatGPTMessage struct {
+	ID     string `json:"id"`
+	Author struct {
+		Role string `json:"role"`
+	} `json:"author"`
+	CreateTime *float64 `json:"create_time"`
+	Content    struct {
+		ContentType string            `json:"content_type"`
+		Parts       []json.RawMessage `json:"parts"`
+		Text        string            `json:"text"`
+		Language    string            `json:"language"`
+	} `json:"content"`
+	Metadata struct {
+		ModelSlug string `json:"model_slug"`
+		Hidden    bool   `json:"is_visually_hidden_from_conversation"`
+	} `json:"metadata"`
+}
+
+// ChatGPT converts a conversations.json export, keeping edits and regenerations as
+// branches. Tool calls and hidden messages are dropped and the replies to them are
+// attached to the nearest message that was kept.
+func ChatGPT(data []byte) ([]*sessions.Session, error) {
+	var conversations []chatGPTConversation
+	if err := json.Unmarshal(data, &conversations); err != nil {
+		var single chatGPTConversation
+		if singleErr := json.Unmarshal(data, &single); singleErr != nil {
+			return nil, fmt.Errorf("failed to read ChatGPT export: %w", err)
+		}
+		conversations = []chatGPTConversation{single}
+	}
+	list := make([]*sessions.Session, 0, len(conversations))
+	for _, c := range conversations {
+		if s := c.session(); len(s.Messages) > 0 {
+			list = append(list, s)
+		}
+	}
+	return list, nil
+}
+
+func (c *chatGPTConversation) session() *sessions.Session {
+	id := c.ConvID
+	if id == "" {
+		id = c.ID
+	}
+	created := unixTime(c.CreateTime)
+	source := FormatChatGPT + ":" + id
+	s := &sessions.Session{
+		ID:        sessionID(created, source),
+		Title:     c.Title,
+		Model:     c.DefaultSlug,
+		Source:    source,
+		CreatedAt: created,
+		UpdatedAt: unixTime(c.UpdateTime),
+	}
+
+	// kept maps every node to the nearest kept message at or above it.
+	kept := map[string]string{}
+	var keptFor func(nodeID string) string
+	keptFor = func(nodeID string) string {
+		if k, ok := kept[nodeID]; ok {
+			return k
+		}
+		node, ok := c.Mapping[nodeID]
+		if !ok {
+			return ""
+		}
+		kept[nodeID] = "" // guards against cycles in a damaged export
+		k := keptFor(node.Parent)
+		if c.keep(node) {
+			k = node.ID
+		}
+		kept[nodeID] = k
+		return k
+	}
+
+	nodes := []types.Message{}
+	for nodeID, node := range c.Mapping {
+		if node.Message != nil && node.Message.Author.Role == types.RoleSystem && s.SystemPrompt == "" {
+			s.SystemPrompt = strings.TrimSpace(node.Message.text())
+		}
+		if !c.keep(node) {
+			continue
+		}
+		msg := node.Message
+		created := s.CreatedAt
+		if msg.CreateTime != nil {
+			created = unixTime(*msg.CreateTime)
+		}
+		m := types.Message{
+			ID:        nodeID,
+			ParentID:  keptFor(node.Parent),
+			Role:      msg.Author.Role,
+			Content:   msg.text(),
+			CreatedAt: created,
+		}
+		if m.Role == types.RoleAssistant {
+			m.Model = msg.Metadata.ModelSlug
+			if s.Model == "" {
+				s.Model = m.Model
+			}
+		}
+		nodes = append(nodes, m)
+	}
+	sort.SliceStable(nodes, func(i, j int) bool {
+		if nodes[i].CreatedAt.Equal(nodes[j].CreatedAt) {
+			return nodes[i].ID < nodes[j].ID
+		}
+		return nodes[i].CreatedAt.Before(nodes[j].CreatedAt)
+	})
+	s.SetTree(nodes, keptFor(c.CurrentNode))
+	if s.Title == "" {
+		s.Title = s.DefaultTitle()
+	}
+	return s
+}
+
+func (c *chatGPTConversation) keep(node chatGPTNode) bool {
+	msg := node.Message
+	if msg == nil || msg.Metadata.Hidden {
+		return false
+	}
+	if msg.Author.Role != types.RoleUser && msg.Author.Role != types.RoleAssistant {
+		return false
+	}
+	return strings.TrimSpace(msg.text()) != ""
+}
+
+// text flattens the message content. Only text parts are kept; images and other
+// assets are noted by kind.
+func (m *chatGPTMessage) text() string {
+	switch m.Content.ContentType {
+	case "text", "multimodal_text":
+		parts := []string{}
+		for _, raw := range m.Content.Parts {
+			var text string
+			if err := json.Unmarshal(raw, &text); err == nil {
+				parts = append(parts, text)
+				continue
+			}
+			var asset struct {
+				ContentType string `json:"content_type"`
+			}
+			if err := json.Unmarshal(raw, &asset); err == nil && asset.ContentType != "" {
+				parts = append(parts, "["+strings.ReplaceAll(asset.ContentType, "_", " ")+"]")
+			}
+		}
+		return strings.Join(parts, "\n")
+	case "code":
+		return "```" + m.Content.Language + "\n" + m.Content.Text + "\n```"
+	default:
+		return ""
+	}
+}
+
+func unixTime(secs float64) time.Time {
+	if secs == 0 {
+		return time.Now()
+	}
+	whole, frac := math.Modf(secs)
+	return time.Unix(int64(whole), int64(frac*1e9))
+}
@@ -0,0 +1,100 @@
+package importer
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/falbanese9484/terminal-chat/sessions"
+)
+
+const (
+	FormatChatGPT = "chatgpt"
+	FormatOpenAI  = "openai"
+)
+
+var ErrUnknownFormat = errors.New("unrecognised export format")
+
+// Import converts an export from another chat tool into sessions. An empty format is
+// detected from the data.
+func Import(data []byte, format string) ([]*sessions.Session, error) {
+	if format == "" {
+		detected, err := Detect(data)
+		if err != nil {
+			return nil, err
+		}
+		format = detected
+	}
+	switch format {
+	case FormatChatGPT:
+		return ChatGPT(data)
+	case FormatOpenAI:
+		return OpenAI(data)
+	default:
+		return nil, fmt.Errorf("%w: %q", ErrUnknownFormat, format)
+	}
+}
+
+// Detect tells a ChatGPT export, which is built around a "mapping" of message nodes,
+// from an OpenAI style list of role/content messages.
+func Detect(data []byte) (string, error) {
+	data = bytes.TrimSpace(data)
+	var probe []map[string]json.RawMessage
+	if len(data) > 0 && data[0] == '[' {
+		if err := json.Unmarshal(data, &probe); err != nil {
+			return "", fmt.Errorf("failed to read export: %w", err)
+		}
+	} else {
+		var single map[string]json.RawMessage
+		if err := json.Unmarshal(data, &single); err != nil {
+			return "", fmt.Errorf("failed to read export: %w", err)
+		}
+		probe = []map[string]json.RawMessage{single}
+	}
+	if len(probe) == 0 {
+		return "", fmt.Errorf("%w: no conversations found", ErrUnknownFormat)
+	}
+	switch first := probe[0]; {
+	case first["mapping"] != nil:
+		return FormatChatGPT, nil
+	case first["role"] != nil, first["messages"] != nil:
+		return FormatOpenAI, nil
+	}
+	return "", ErrUnknownFormat
+}
+
+// Save writes imported sessions to the store. A session imported from the same source
+// before is replaced in place rather than duplicated.
+func Save(store *sessions.Store, imported []*sessions.Session) (added, updated int, err error) {
+	existing := map[string]string{}
+	list, _ := store.List()
+	for _, s := range list {
+		if s.Source != "" {
+			existing[s.Source] = s.ID
+		}
+	}
+	for _, s := range imported {
+		if id, ok := existing[s.Source]; ok {
+			s.ID = id
+			updated++
+		} else {
+			added++
+		}
+		if err := store.Save(s); err != nil {
+			return added, updated, fmt.Errorf("failed to save %q: %w", s.Title, err)
+		}
+		existing[s.Source] = s.ID
+	}
+	return added, updated, nil
+}
+
+// sessionID gives an imported conversation an ID derived from where it came from, so
+// it keeps the same one across imports.
+func sessionID(created time.Time, source string) string {
+	sum := sha256.Sum256([]byte(source))
+	return created.Format("20060102-150405") + "-" + hex.EncodeToString(sum[:3])
+}
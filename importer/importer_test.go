@@ -0,0 +1,256 @@
+package importer
+
+import (
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/falbanese9484/terminal-chat/sessions"
+	"github.com/falbanese9484/terminal-chat/types"
+)
+
+// chatGPTExport is one conversation: a hidden system message, a regenerated answer,
+// a tool call and an answer that follows it. The current node is filled in by the test.
+const chatGPTExport = `[{
+	"conversation_id": "conv-1",
+	"title": "Sums",
+	"create_time": 1700000000,
+	"update_time": 1700000100,
+	"current_node": "%s",
+	"mapping": {
+		"root": {"id": "root", "message": null, "parent": "", "children": ["sys"]},
+		"sys": {"id": "sys", "parent": "root", "children": ["u1"], "message": {
+			"author": {"role": "system"}, "create_time": 1700000000,
+			"content": {"content_type": "text", "parts": ["Be brief"]},
+			"metadata": {"is_visually_hidden_from_conversation": true}}},
+		"u1": {"id": "u1", "parent": "sys", "children": ["a1", "a1b"], "message": {
+			"author": {"role": "user"}, "create_time": 1700000001,
+			"content": {"content_type": "multimodal_text", "parts": [{"content_type": "image_asset_pointer"}, "What is 2+2?"]}}},
+		"a1": {"id": "a1", "parent": "u1", "children": [], "message": {
+			"author": {"role": "assistant"}, "create_time": 1700000002,
+			"content": {"content_type": "text", "parts": ["4"]},
+			"metadata": {"model_slug": "gpt-4o"}}},
+		"a1b": {"id": "a1b", "parent": "u1", "children": ["tool"], "message": {
+			"author": {"role": "assistant"}, "create_time": 1700000003,
+			"content": {"content_type": "code", "language": "python", "text": "print(2+2)"}}},
+		"tool": {"id": "tool", "parent": "a1b", "children": ["a2"], "message": {
+			"author": {"role": "tool"}, "create_time": 1700000004,
+			"content": {"content_type": "text", "parts": ["4"]}}},
+		"a2": {"id": "a2", "parent": "tool", "children": ["hidden"], "message": {
+			"author": {"role": "assistant"}, "create_time": 1700000005,
+			"content": {"content_type": "text", "parts": ["It prints 4."]}}},
+		"hidden": {"id": "hidden", "parent": "a2", "children": [], "message": {
+			"author": {"role": "user"}, "create_time": 1700000006,
+			"content": {"content_type": "text", "parts": ["context"]},
+			"metadata": {"is_visually_hidden_from_conversation": true}}}
+	}
+}]`
+
+func chatGPT(t *testing.T, currentNode string) *sessions.Session {
+	t.Helper()
+	list, err := Import([]byte(strings.Replace(chatGPTExport, "%s", currentNode, 1)), "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(list) != 1 {
+		t.Fatalf("imported %d sessions, want 1", len(list))
+	}
+	return list[0]
+}
+
+func ids(messages []types.Message) string {
+	out := []string{}
+	for _, m := range messages {
+		out = append(out, m.ID)
+	}
+	return strings.Join(out, " ")
+}
+
+func TestChatGPT(t *testing.T) {
+	s := chatGPT(t, "a2")
+	if s.Title != "Sums" || s.SystemPrompt != "Be brief" || s.Model != "gpt-4o" || s.Source != "chatgpt:conv-1" {
+		t.Errorf("session = %q, %q, %q, %q", s.Title, s.SystemPrompt, s.Model, s.Source)
+	}
+	// The system, tool and hidden messages are dropped and a2 follows a1b instead.
+	if got := ids(s.Nodes); got != "u1 a1 a1b a2" {
+		t.Errorf("nodes = %q", got)
+	}
+	if got := ids(s.Messages); got != "u1 a1b a2" {
+		t.Fatalf("active branch = %q", got)
+	}
+	if s.Messages[2].ParentID != "a1b" {
+		t.Errorf("a2 follows %q, want a1b", s.Messages[2].ParentID)
+	}
+	if got := s.Messages[0].Content; got != "[image asset pointer]\nWhat is 2+2?" {
+		t.Errorf("user message = %q", got)
+	}
+	if got := s.Messages[1].Content; got != "```python\nprint(2+2)\n```" {
+		t.Errorf("code message = %q", got)
+	}
+	if n, total := s.Branch("a1b"); n != 2 || total != 2 {
+		t.Errorf("Branch(a1b) = %d/%d, want 2/2", n, total)
+	}
+}
+
+func TestChatGPTCurrentNode(t *testing.T) {
+	tests := []struct {
+		currentNode string
+		want        string
+	}{
+		{"a2", "u1 a1b a2"},
+		{"a1", "u1 a1"},
+		// A dropped node stands for the nearest kept message above it.
+		{"tool", "u1 a1b a2"},
+		{"hidden", "u1 a1b a2"},
+		// Without one, the newest branch is shown.
+		{"", "u1 a1b a2"},
+		{"missing", "u1 a1b a2"},
+	}
+	for _, tt := range tests {
+		if got := ids(chatGPT(t, tt.currentNode).Messages); got != tt.want {
+			t.Errorf("current_node %q: active branch = %q, want %q", tt.currentNode, got, tt.want)
+		}
+	}
+}
+
+func TestOpenAI(t *testing.T) {
+	const image = "data:image/png;base64,iVBORw0K"
+	tests := []struct {
+		name string
+		data string
+		want []string
+	}{
+		{
+			name: "list of messages",
+			data: `[{"role": "system", "content": "Be brief"}, {"role": "user", "content": "hi"}, {"role": "assistant", "content": "hello"}]`,
+			want: []string{"hi|hello"},
+		},
+		{
+			name: "request object",
+			data: `{"model": "gpt-4o", "messages": [{"role": "developer", "content": "Be brief"}, {"role": "user", "content": "hi"}, {"role": "tool", "content": "x"}]}`,
+			want: []string{"hi"},
+		},
+		{
+			name: "list of request objects",
+			data: `[{"messages": [{"role": "user", "content": "one"}]}, {"messages": [{"role": "system", "content": "only"}]}, {"title": "Two", "messages": [{"role": "user", "content": "two"}]}]`,
+			want: []string{"one", "two"},
+		},
+		{
+			name: "content parts",
+			data: `[{"role": "user", "content": [{"type": "text", "text": "what is this"}, {"type": "image_url", "image_url": {"url": "` + image + `"}}, {"type": "image_url", "image_url": {"url": "https://example.com/a.png"}}]}]`,
+			want: []string{"what is this\n[image: https://example.com/a.png]"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if format, err := Detect([]byte(tt.data)); err != nil || format != FormatOpenAI {
+				t.Fatalf("Detect = %q, %v", format, err)
+			}
+			list, err := Import([]byte(tt.data), "")
+			if err != nil {
+				t.Fatal(err)
+			}
+			got := []string{}
+			for _, s := range list {
+				contents := []string{}
+				for _, m := range s.Messages {
+					contents = append(contents, m.Content)
+				}
+				got = append(got, strings.Join(contents, "|"))
+				if !strings.HasPrefix(s.Source, "openai:") {
+					t.Errorf("source = %q", s.Source)
+				}
+			}
+			if strings.Join(got, ",") != strings.Join(tt.want, ",") {
+				t.Errorf("imported %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestOpenAIDataURLImage(t *testing.T) {
+	data := `{"messages": [{"role": "user", "content": [{"type": "image_url", "image_url": {"url": "data:image/png;base64,aGVsbG8="}}, {"type": "image_url", "image_url": {"url": "data:image/png;base64,%%%"}}]}]}`
+	list, err := OpenAI([]byte(data))
+	if err != nil {
+		t.Fatal(err)
+	}
+	msg := list[0].Messages[0]
+	if len(msg.Attachments) != 1 {
+		t.Fatalf("attachments = %+v", msg.Attachments)
+	}
+	att := msg.Attachments[0]
+	if att.Kind != types.AttachmentImage || att.MimeType != "image/png" || att.Size != 5 || att.Data != "aGVsbG8=" || att.Name != "image-1" {
+		t.Errorf("image = %+v", att)
+	}
+	// Data that isn't base64 is kept as a reference in the text instead.
+	if !strings.Contains(msg.Content, "[image: data:image/png;base64,%%%]") {
+		t.Errorf("content = %q", msg.Content)
+	}
+}
+
+func TestDetect(t *testing.T) {
+	tests := []struct {
+		data string
+		want string
+		err  bool
+	}{
+		{chatGPTExport, FormatChatGPT, false},
+		{`{"mapping": {}}`, FormatChatGPT, false},
+		{`  {"messages": []}`, FormatOpenAI, false},
+		{`[{"role": "user", "content": "hi"}]`, FormatOpenAI, false},
+		{`[]`, "", true},
+		{`{"name": "x"}`, "", true},
+		{`not json`, "", true},
+	}
+	for _, tt := range tests {
+		got, err := Detect([]byte(tt.data))
+		if got != tt.want || (err != nil) != tt.err {
+			t.Errorf("Detect(%.30q) = %q, %v", tt.data, got, err)
+		}
+	}
+	if _, err := Import([]byte(`{"name": "x"}`), ""); !errors.Is(err, ErrUnknownFormat) {
+		t.Errorf("Import of an unknown format = %v", err)
+	}
+	if _, err := Import([]byte(`[]`), "claude"); !errors.Is(err, ErrUnknownFormat) {
+		t.Errorf("Import with an unknown format name = %v", err)
+	}
+}
+
+func TestReimport(t *testing.T) {
+	store, err := sessions.NewStore(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	openAI := `{"messages": [{"role": "user", "content": "hi"}]}`
+	for round, wantAdded := range []int{2, 0} {
+		list, err := Import([]byte(strings.Replace(chatGPTExport, "%s", "a2", 1)), "")
+		if err != nil {
+			t.Fatal(err)
+		}
+		more, err := Import([]byte(openAI), "")
+		if err != nil {
+			t.Fatal(err)
+		}
+		added, updated, err := Save(store, append(list, more...))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if added != wantAdded || updated != 2-wantAdded {
+			t.Errorf("round %d: added %d and updated %d", round+1, added, updated)
+		}
+	}
+	stored, err := store.IDs()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(stored) != 2 {
+		t.Errorf("the store holds %d sessions after importing twice, want 2", len(stored))
+	}
+	s, err := store.Load(chatGPT(t, "a2").ID)
+	if err != nil {
+		t.Fatalf("the ChatGPT session wasn't saved under its stable ID: %v", err)
+	}
+	if got := ids(s.Messages); got != "u1 a1b a2" {
+		t.Errorf("reloaded active branch = %q", got)
+	}
+}
@@ -0,0 +1,160 @@
+package importer
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/falbanese9484/terminal-chat/sessions"
+	"github.com/falbanese9484/terminal-chat/types"
+)
+
+type openAIChat struct {
+	// An OpenAI style request or transcript. Title is not part of the API but some
+	// tools add it.
+	Model    string          `json:"model"`
+	Title    string          `json:"title"`
+	Messages []openAIMessage `json:"messages"`
+}
+
+type openAIMessage struct {
+	Role string `json:"role"`
+	// Content is a string, or a list of text and image_url parts.
+	Content json.RawMessage `json:"content"`
+}
+
+type openAIPart struct {
+	Type     string `json:"type"`
+	Text     string `json:"text"`
+	ImageURL *struct {
+		URL string `json:"url"`
+	} `json:"image_url"`
+}
+
+// OpenAI converts OpenAI style messages JSON. That can be a bare list of messages, an
+// object with "messages" (like a chat completion request), or a list of such objects.
+func OpenAI(data []byte) ([]*sessions.Session, error) {
+	data = bytes.TrimSpace(data)
+	var chats []openAIChat
+	switch {
+	case len(data) > 0 && data[0] == '{':
+		var chat openAIChat
+		if err := json.Unmarshal(data, &chat); err != nil {
+			return nil, fmt.Errorf("failed to read OpenAI messages: %w", err)
+		}
+		chats = []openAIChat{chat}
+	default:
+		var items []json.RawMessage
+		if err := json.Unmarshal(data, &items); err != nil {
+			return nil, fmt.Errorf("failed to read OpenAI messages: %w", err)
+		}
+		if len(items) > 0 && bytes.Contains(items[0], []byte(`"messages"`)) {
+			if err := json.Unmarshal(data, &chats); err != nil {
+				return nil, fmt.Errorf("failed to read OpenAI messages: %w", err)
+			}
+		} else {
+			var chat openAIChat
+			if err := json.Unmarshal(data, &chat.Messages); err != nil {
+				return nil, fmt.Errorf("failed to read OpenAI messages: %w", err)
+			}
+			chats = []openAIChat{chat}
+		}
+	}
+
+	list := []*sessions.Session{}
+	now := time.Now()
+	for _, chat := range chats {
+		s := &sessions.Session{
+			Title:     chat.Title,
+			Model:     chat.Model,
+			CreatedAt: now,
+			UpdatedAt: now,
+			Active:    map[string]string{},
+		}
+		for j, m := range chat.Messages {
+			text, atts := m.content()
+			if m.Role == types.RoleSystem || m.Role == "developer" {
+				if s.SystemPrompt == "" {
+					s.SystemPrompt = text
+				}
+				continue
+			}
+			if m.Role != types.RoleUser && m.Role != types.RoleAssistant {
+				continue
+			}
+			s.Append(types.Message{
+				Role:        m.Role,
+				Content:     text,
+				Attachments: atts,
+				Model:       chat.Model,
+				// Keep the order when the messages are sorted by time.
+				CreatedAt: now.Add(time.Duration(j) * time.Millisecond),
+			})
+		}
+		if len(s.Messages) == 0 {
+			continue
+		}
+		// There are no IDs or timestamps in this format, so the content identifies it.
+		raw, _ := json.Marshal(chat)
+		sum := sha256.Sum256(raw)
+		s.Source = FormatOpenAI + ":" + hex.EncodeToString(sum[:6])
+		s.ID = sessions.NewID(now)
+		if s.Title == "" {
+			s.Title = s.DefaultTitle()
+		}
+		list = append(list, s)
+	}
+	return list, nil
+}
+
+// content splits message content into its text and any inline images.
+func (m openAIMessage) content() (string, []*types.Attachment) {
+	var text string
+	if err := json.Unmarshal(m.Content, &text); err == nil {
+		return text, nil
+	}
+	var parts []openAIPart
+	if err := json.Unmarshal(m.Content, &parts); err != nil {
+		return "", nil
+	}
+	texts := []string{}
+	atts := []*types.Attachment{}
+	for _, p := range parts {
+		switch {
+		case p.Type == "text":
+			texts = append(texts, p.Text)
+		case p.Type == "image_url" && p.ImageURL != nil:
+			if att := dataURLImage(p.ImageURL.URL, len(atts)+1); att != nil {
+				atts = append(atts, att)
+			} else {
+				texts = append(texts, "[image: "+p.ImageURL.URL+"]")
+			}
+		}
+	}
+	return strings.Join(texts, "\n"), atts
+}
+
+// dataURLImage turns an inline base64 data URL into an image attachment. Remote URLs
+// aren't fetched.
+func dataURLImage(url string, n int) *types.Attachment {
+	meta, data, ok := strings.Cut(strings.TrimPrefix(url, "data:"), ",")
+	if !ok || !strings.HasPrefix(url, "data:") || !strings.HasSuffix(meta, ";base64") {
+		return nil
+	}
+	decoded, err := base64.StdEncoding.DecodeString(data)
+	if err != nil {
+		return nil
+	}
+	return &types.Attachment{
+		Kind:     types.AttachmentImage,
+		Name:     fmt.Sprintf("image-%d", n),
+		MimeType: strings.TrimSuffix(meta, ";base64"),
+		Size:     int64(len(decoded)),
+		Data:     data,
+	}
+}
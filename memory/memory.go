@@ -0,0 +1,190 @@
+package memory
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/falbanese9484/terminal-chat/config"
+	"github.com/falbanese9484/terminal-chat/tokenizer"
+)
+
+// DefaultBudget is how many tokens of memories go into the system prompt unless
+// BUTLER_MEMORY_BUDGET says otherwise.
+const DefaultBudget = 400
+
+const (
+	SourceUser  = "user"
+	SourceModel = "model"
+)
+
+// ProposePrompt asks the model to suggest memories, which the user then approves.
+const ProposePrompt = `If the user states a lasting fact about themselves, their environment or their preferences
+that would help in future conversations, end your answer with it on its own line as
+<remember>the fact</remember>. Only do so for stable facts, not for the task at hand.`
+
+var proposal = regexp.MustCompile(`(?s)<remember>(.*?)</remember>\n?`)
+
+type Memory struct {
+	// A fact kept across conversations. Source says whether the user added it or
+	// approved a model's suggestion.
+	Text      string    `json:"text"`
+	Source    string    `json:"source"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+type Store struct {
+	// Memories are oldest first.
+	Memories []Memory
+	path     string
+}
+
+// Load reads the memories kept at path. A missing file is no memories.
+func Load(path string) (*Store, error) {
+	s := &Store{Memories: []Memory{}, path: path}
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return s, nil
+	}
+	if err != nil {
+		return s, fmt.Errorf("failed to read memories: %w", err)
+	}
+	if err := json.Unmarshal(data, &s.Memories); err != nil {
+		return s, fmt.Errorf("failed to read memories: %w", err)
+	}
+	return s, nil
+}
+
+// LoadDefault loads the memories of the active profile.
+func LoadDefault() (*Store, error) {
+	path, err := config.Path("profiles", config.Profile(), "memories.json")
+	if err != nil {
+		return &Store{Memories: []Memory{}}, err
+	}
+	return Load(path)
+}
+
+// Save writes the memories back, through a temporary file so a crash can't leave
+// them half written.
+func (s *Store) Save() error {
+	data, err := json.MarshalIndent(s.Memories, "", "  ")
+	if err != nil {
+		return err
+	}
+	tmp := filepath.Join(filepath.Dir(s.path), ".memories.json.tmp")
+	if err := os.WriteFile(tmp, data, 0o644); err != nil {
+		return fmt.Errorf("failed to save memories: %w", err)
+	}
+	if err := os.Rename(tmp, s.path); err != nil {
+		return fmt.Errorf("failed to save memories: %w", err)
+	}
+	return nil
+}
+
+// Add keeps a fact and saves. It reports false for a blank fact or one already kept.
+func (s *Store) Add(text, source string) (bool, error) {
+	text = strings.TrimSpace(text)
+	if text == "" {
+		return false, nil
+	}
+	for _, m := range s.Memories {
+		if strings.EqualFold(m.Text, text) {
+			return false, nil
+		}
+	}
+	s.Memories = append(s.Memories, Memory{Text: text, Source: source, CreatedAt: time.Now()})
+	return true, s.Save()
+}
+
+// Remove forgets the i-th memory and saves.
+func (s *Store) Remove(i int) error {
+	if i < 0 || i >= len(s.Memories) {
+		return fmt.Errorf("no memory %d", i+1)
+	}
+	s.Memories = append(s.Memories[:i], s.Memories[i+1:]...)
+	return s.Save()
+}
+
+// Replace sets the memories to the non-blank lines of text, as edited in an editor.
+// Lines that were there before keep their source and date.
+func (s *Store) Replace(text string) error {
+	old := map[string]Memory{}
+	for _, m := range s.Memories {
+		old[m.Text] = m
+	}
+	memories := []Memory{}
+	for _, line := range strings.Split(text, "\n") {
+		line = strings.TrimSpace(strings.TrimPrefix(strings.TrimSpace(line), "- "))
+		if line == "" {
+			continue
+		}
+		m, ok := old[line]
+		if !ok {
+			m = Memory{Text: line, Source: SourceUser, CreatedAt: time.Now()}
+		}
+		memories = append(memories, m)
+	}
+	s.Memories = memories
+	return s.Save()
+}
+
+// Text lists the memories one per line, for editing.
+func (s *Store) Text() string {
+	var sb strings.Builder
+	for _, m := range s.Memories {
+		sb.WriteString(m.Text + "\n")
+	}
+	return sb.String()
+}
+
+// Budget reads BUTLER_MEMORY_BUDGET.
+func Budget() int {
+	if n, err := strconv.Atoi(os.Getenv("BUTLER_MEMORY_BUDGET")); err == nil && n >= 0 {
+		return n
+	}
+	return DefaultBudget
+}
+
+// Prompt is the block of memories for the system prompt, within budget tokens. When
+// they don't all fit the newest win. It also returns how many made it in.
+func (s *Store) Prompt(tok tokenizer.Tokenizer, budget int) (string, int) {
+	const header = "Things to remember about the user, from earlier conversations:"
+	used := tok.Count(header)
+	start := len(s.Memories)
+	for start > 0 {
+		cost := tok.Count("- "+s.Memories[start-1].Text) + 1
+		if used+cost > budget {
+			break
+		}
+		used += cost
+		start--
+	}
+	if start == len(s.Memories) {
+		return "", 0
+	}
+	lines := []string{header}
+	for _, m := range s.Memories[start:] {
+		lines = append(lines, "- "+m.Text)
+	}
+	return strings.Join(lines, "\n"), len(s.Memories) - start
+}
+
+// Proposals takes the memories a model suggested out of its answer.
+func Proposals(answer string) (string, []string) {
+	proposed := []string{}
+	for _, match := range proposal.FindAllStringSubmatch(answer, -1) {
+		if text := strings.TrimSpace(match[1]); text != "" {
+			proposed = append(proposed, text)
+		}
+	}
+	if len(proposed) == 0 {
+		return answer, nil
+	}
+	return strings.TrimSpace(proposal.ReplaceAllString(answer, "")), proposed
+}
@@ -0,0 +1,112 @@
+package memory
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/falbanese9484/terminal-chat/tokenizer/tokenizertest"
+)
+
+func TestProposals(t *testing.T) {
+	tests := []struct {
+		answer   string
+		want     string
+		proposed []string
+	}{
+		{"No facts here.", "No facts here.", nil},
+		{"Sure.\n<remember>The user deploys with Helm</remember>\n", "Sure.", []string{"The user deploys with Helm"}},
+		{"A\n<remember> uses zsh </remember>\n<remember>on macOS</remember>", "A", []string{"uses zsh", "on macOS"}},
+		{"<remember>spans\ntwo lines</remember>", "", []string{"spans\ntwo lines"}},
+		{"Blank <remember>  </remember>", "Blank <remember>  </remember>", nil},
+		{"Unclosed <remember>fact", "Unclosed <remember>fact", nil},
+	}
+	for _, tt := range tests {
+		got, proposed := Proposals(tt.answer)
+		if got != tt.want || !reflect.DeepEqual(proposed, tt.proposed) {
+			t.Errorf("Proposals(%q) = %q, %q, want %q, %q", tt.answer, got, proposed, tt.want, tt.proposed)
+		}
+	}
+}
+
+func TestPrompt(t *testing.T) {
+	s := &Store{Memories: []Memory{{Text: "oldest fact"}, {Text: "middle fact"}, {Text: "newest fact"}}}
+	// The header is 9 words and each memory "- x fact" 3 plus 1 for its line.
+	tests := []struct {
+		budget int
+		want   []string
+	}{
+		{0, nil},
+		{9, nil},
+		{12, nil},
+		{13, []string{"newest fact"}},
+		{20, []string{"middle fact", "newest fact"}},
+		{21, []string{"oldest fact", "middle fact", "newest fact"}},
+		{1000, []string{"oldest fact", "middle fact", "newest fact"}},
+	}
+	for _, tt := range tests {
+		block, n := s.Prompt(tokenizertest.Words{}, tt.budget)
+		if n != len(tt.want) {
+			t.Errorf("Prompt(%d) kept %d memories, want %d", tt.budget, n, len(tt.want))
+		}
+		if tt.want == nil {
+			if block != "" {
+				t.Errorf("Prompt(%d) = %q, want nothing", tt.budget, block)
+			}
+			continue
+		}
+		lines := strings.Split(block, "\n")[1:]
+		for i, text := range tt.want {
+			if i >= len(lines) || lines[i] != "- "+text {
+				t.Errorf("Prompt(%d) = %q, want %v", tt.budget, block, tt.want)
+				break
+			}
+		}
+		if used := (tokenizertest.Words{}).Count(block) + n; used > tt.budget {
+			t.Errorf("Prompt(%d) used %d tokens", tt.budget, used)
+		}
+	}
+}
+
+func TestStore(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "memories.json")
+	s, err := Load(path)
+	if err != nil || len(s.Memories) != 0 {
+		t.Fatalf("Load of a missing file = %v, %v", s.Memories, err)
+	}
+	for _, text := range []string{"uses zsh", "  ", "Uses ZSH", "deploys with Helm"} {
+		if _, err := s.Add(text, SourceUser); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if got := s.Text(); got != "uses zsh\ndeploys with Helm\n" {
+		t.Fatalf("after Add, Text() = %q", got)
+	}
+
+	if err := s.Replace("- deploys with Helm\n\nprefers tabs\n"); err != nil {
+		t.Fatal(err)
+	}
+	if len(s.Memories) != 2 || s.Memories[1].Text != "prefers tabs" || s.Memories[1].Source != SourceUser {
+		t.Fatalf("after Replace, memories = %+v", s.Memories)
+	}
+	if err := s.Remove(0); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.Remove(5); err == nil {
+		t.Error("Remove of a missing memory should fail")
+	}
+
+	loaded, err := Load(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if loaded.Text() != "prefers tabs\n" {
+		t.Errorf("saved memories = %q", loaded.Text())
+	}
+	entries, _ := os.ReadDir(filepath.Dir(path))
+	if len(entries) != 1 {
+		t.Errorf("Save left %d files behind", len(entries))
+	}
+}
@@ -0,0 +1,152 @@
+package personas
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"sort"
+	"strings"
+
+	"github.com/falbanese9484/terminal-chat/config"
+)
+
+// The tools a persona can be allowed. Shell covers !commands, /code pipe and template
+// commands; files covers @mentions, /image and template files.
+const (
+	ToolShell = "shell"
+	ToolFiles = "files"
+)
+
+var Tools = []string{ToolShell, ToolFiles}
+
+type Persona struct {
+	// A reusable setup for a kind of conversation. Model may carry a "provider:"
+	// prefix, and an empty Model keeps whatever model is in use. Tools lists what the
+	// persona may use; nil allows everything and an empty list nothing.
+	Name        string   `json:"name"`
+	Description string   `json:"description,omitempty"`
+	System      string   `json:"system"`
+	Model       string   `json:"model,omitempty"`
+	Temperature *float64 `json:"temperature,omitempty"`
+	TopP        *float64 `json:"top_p,omitempty"`
+	MaxTokens   *int     `json:"max_tokens,omitempty"`
+	Tools       []string `json:"tools,omitempty"`
+}
+
+// Allows reports whether the persona may use tool. No persona allows everything.
+func (p *Persona) Allows(tool string) bool {
+	return p == nil || p.Tools == nil || slices.Contains(p.Tools, tool)
+}
+
+func (p *Persona) validate() error {
+	for _, tool := range p.Tools {
+		if !slices.Contains(Tools, tool) {
+			return fmt.Errorf("persona %s: unknown tool %q, expected one of %v", p.Name, tool, Tools)
+		}
+	}
+	if p.Temperature != nil && (*p.Temperature < 0 || *p.Temperature > 2) {
+		return fmt.Errorf("persona %s: temperature must be between 0 and 2", p.Name)
+	}
+	return nil
+}
+
+func floatPtr(f float64) *float64 { return &f }
+
+// builtin are available without any files. A file with the same name replaces one.
+var builtin = []*Persona{
+	{
+		Name:        "shell-expert",
+		Description: "Terse answers about the shell and the command line",
+		System: "You are an expert in Unix shells and command line tools. Answer with the " +
+			"command first, then a one or two line explanation. Prefer portable POSIX " +
+			"options and point out anything destructive.",
+		Temperature: floatPtr(0.2),
+	},
+	{
+		Name:        "go-reviewer",
+		Description: "Reviews Go code for correctness and idiom",
+		System: "You are a senior Go reviewer. Look for bugs, races, error handling gaps " +
+			"and unidiomatic code, most important first. Quote the lines you mean and " +
+			"suggest the fix. Don't restate what the code does.",
+		Temperature: floatPtr(0.3),
+	},
+}
+
+type Library struct {
+	// Personas are kept one per JSON file in Dir, named after the file.
+	Dir string
+}
+
+// NewDefaultLibrary opens the personas directory under the config directory.
+func NewDefaultLibrary() (*Library, error) {
+	dir, err := config.Dir()
+	if err != nil {
+		return nil, err
+	}
+	dir = filepath.Join(dir, "personas")
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, fmt.Errorf("failed to make personas directory: %w", err)
+	}
+	return &Library{Dir: dir}, nil
+}
+
+// List returns the built in personas and those in Dir, sorted by name.
+func (l *Library) List() ([]*Persona, error) {
+	byName := map[string]*Persona{}
+	for _, p := range builtin {
+		byName[p.Name] = p
+	}
+	entries, err := os.ReadDir(l.Dir)
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		return nil, err
+	}
+	for _, entry := range entries {
+		if entry.IsDir() || filepath.Ext(entry.Name()) != ".json" {
+			continue
+		}
+		p, err := l.load(filepath.Join(l.Dir, entry.Name()))
+		if err != nil {
+			return nil, err
+		}
+		byName[p.Name] = p
+	}
+	list := make([]*Persona, 0, len(byName))
+	for _, p := range byName {
+		list = append(list, p)
+	}
+	sort.Slice(list, func(i, j int) bool { return list[i].Name < list[j].Name })
+	return list, nil
+}
+
+// Get returns the persona with the given name.
+func (l *Library) Get(name string) (*Persona, error) {
+	p, err := l.load(filepath.Join(l.Dir, filepath.Base(name)+".json"))
+	if err == nil || !errors.Is(err, os.ErrNotExist) {
+		return p, err
+	}
+	for _, p := range builtin {
+		if p.Name == name {
+			return p, nil
+		}
+	}
+	return nil, fmt.Errorf("no persona named %q in %s", name, l.Dir)
+}
+
+func (l *Library) load(path string) (*Persona, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	p := &Persona{}
+	if err := json.Unmarshal(data, p); err != nil {
+		return nil, fmt.Errorf("failed to read persona %s: %w", path, err)
+	}
+	p.Name = strings.TrimSuffix(filepath.Base(path), ".json")
+	if err := p.validate(); err != nil {
+		return nil, err
+	}
+	return p, nil
+}
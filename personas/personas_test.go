@@ -0,0 +1,58 @@
+package personas
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestAllows(t *testing.T) {
+	var none *Persona
+	tests := []struct {
+		persona *Persona
+		tool    string
+		want    bool
+	}{
+		{none, ToolShell, true},
+		{&Persona{}, ToolShell, true},
+		{&Persona{Tools: []string{ToolFiles}}, ToolFiles, true},
+		{&Persona{Tools: []string{ToolFiles}}, ToolShell, false},
+		{&Persona{Tools: []string{}}, ToolFiles, false},
+	}
+	for _, tt := range tests {
+		if got := tt.persona.Allows(tt.tool); got != tt.want {
+			t.Errorf("%+v.Allows(%q) = %v, want %v", tt.persona, tt.tool, got, tt.want)
+		}
+	}
+}
+
+func TestLoadTools(t *testing.T) {
+	dir := t.TempDir()
+	files := map[string]string{
+		"files-only.json": `{"system": "s", "tools": ["files"]}`,
+		"no-tools.json":   `{"system": "s", "tools": []}`,
+		"typo.json":       `{"system": "s", "tools": ["shel"]}`,
+	}
+	for name, body := range files {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(body), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	library := &Library{Dir: dir}
+	p, err := library.Get("files-only")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if p.Allows(ToolShell) || !p.Allows(ToolFiles) {
+		t.Errorf("files-only allows %v", p.Tools)
+	}
+	if p, err := library.Get("no-tools"); err != nil || p.Allows(ToolFiles) {
+		t.Errorf("no-tools = %+v, %v", p, err)
+	}
+	if _, err := library.Get("typo"); err == nil {
+		t.Error("a persona with an unknown tool should fail to load")
+	}
+	if p, err := library.Get("go-reviewer"); err != nil || !p.Allows(ToolShell) {
+		t.Errorf("go-reviewer = %+v, %v", p, err)
+	}
+}
@@ -0,0 +1,102 @@
+package models
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+)
+
+const (
+	EmbedURL       = "http://localhost:11434/api/embed"
+	ORApiEmbedURL  = "https://openrouter.ai/api/v1/embeddings"
+	embedTimeout   = 120 * time.Second
+	maxErrorDetail = 512
+)
+
+type OllamaEmbedRequest struct {
+	// Request Structure for Ollama's /api/embed, which takes a batch of inputs.
+	Model string   `json:"model"`
+	Input []string `json:"input"`
+}
+
+type OllamaEmbedResponse struct {
+	Embeddings [][]float32 `json:"embeddings"`
+}
+
+type OpenAIEmbedRequest struct {
+	// Request Structure for OpenAI compatible /embeddings endpoints, OpenRouter's among them.
+	Model string   `json:"model"`
+	Input []string `json:"input"`
+}
+
+type OpenAIEmbedResponse struct {
+	Data []struct {
+		Index     int       `json:"index"`
+		Embedding []float32 `json:"embedding"`
+	} `json:"data"`
+}
+
+func (op *OllamaProvider) Embed(ctx context.Context, model string, inputs []string) ([][]float32, error) {
+	var response OllamaEmbedResponse
+	if err := postEmbed(ctx, op.EmbedURL, "", OllamaEmbedRequest{Model: model, Input: inputs}, &response); err != nil {
+		return nil, err
+	}
+	op.logger.Debug("embedded inputs", "model", model, "inputs", len(inputs))
+	return response.Embeddings, nil
+}
+
+func (or *OpenRouter) Embed(ctx context.Context, model string, inputs []string) ([][]float32, error) {
+	var response OpenAIEmbedResponse
+	if err := postEmbed(ctx, or.EmbedURL, or.ApiKey, OpenAIEmbedRequest{Model: model, Input: inputs}, &response); err != nil {
+		return nil, err
+	}
+	// The data can come back in any order; index says which input each vector is for.
+	vectors := make([][]float32, len(inputs))
+	for _, d := range response.Data {
+		if d.Index < 0 || d.Index >= len(vectors) {
+			return nil, fmt.Errorf("embedding index %d out of range", d.Index)
+		}
+		vectors[d.Index] = d.Embedding
+	}
+	for i, v := range vectors {
+		if v == nil {
+			return nil, fmt.Errorf("no embedding returned for input %d", i)
+		}
+	}
+	or.logger.Debug("embedded inputs", "model", model, "inputs", len(inputs))
+	return vectors, nil
+}
+
+func postEmbed(ctx context.Context, url, apiKey string, body, out any) error {
+	data, err := json.Marshal(body)
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequestWithContext(ctx, "POST", url, bytes.NewReader(data))
+	if err != nil {
+		return err
+	}
+	req.Header.Add("Content-Type", "application/json")
+	if apiKey != "" {
+		req.Header.Add("Authorization", fmt.Sprintf("Bearer %s", apiKey))
+	}
+	client := http.Client{Timeout: embedTimeout}
+	res, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer res.Body.Close()
+	if res.StatusCode != http.StatusOK {
+		detail, _ := io.ReadAll(io.LimitReader(res.Body, maxErrorDetail))
+		return fmt.Errorf("embedding request failed with status %d: %s", res.StatusCode, strings.TrimSpace(string(detail)))
+	}
+	if err := json.NewDecoder(res.Body).Decode(out); err != nil {
+		return fmt.Errorf("failed to read embeddings: %w", err)
+	}
+	return nil
+}
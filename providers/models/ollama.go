@@ -7,53 +7,100 @@ import (
 	"fmt"
 	"io"
 	"net/http"
+	"slices"
+	"strings"
+	"sync"
+	"time"
 
 	"github.com/falbanese9484/terminal-chat/logger"
 	"github.com/falbanese9484/terminal-chat/types"
 )
 
 const (
-	ApiURL     = "http://localhost:11434/api/generate"
+	ApiURL     = "http://localhost:11434/api/chat"
 	RefreshURL = "http://localhost:11434/api/tags"
+	ShowURL    = "http://localhost:11434/api/show"
+	// showConcurrency caps how many /api/show requests run at once.
+	showConcurrency = 8
+	// ServedContextLength caps the window Ollama models are run with. Their full trained
+	// window can need more memory for its cache than the machine has.
+	ServedContextLength = 8192
 )
 
 type OllamaProvider struct {
 	Url            string
+	EmbedURL       string
 	logger         *logger.Logger
-	context        []int
 	model          string
 	ModelRefresher *types.ModelRefresher
 }
 
 // NewOllamaProvider creates a new OllamaProvider configured to use ApiURL.
-// The provided logger is attached and the internal context is initialized as an empty slice.
+// The provided logger is attached.
 func NewOllamaProvider(logger *logger.Logger,
 	model string,
 	mf *types.ModelRefresher,
 ) *OllamaProvider {
 	return &OllamaProvider{
 		Url:            ApiURL,
+		EmbedURL:       EmbedURL,
 		logger:         logger,
-		context:        []int{},
 		model:          model,
 		ModelRefresher: mf,
 	}
 }
 
-func (op *OllamaProvider) GenerateRequest(prompt string) *types.ChatRequest {
+func (op *OllamaProvider) GenerateRequest(messages []types.Message) *types.ChatRequest {
 	return &types.ChatRequest{
-		Model:  op.model,
-		Prompt: prompt,
-		Stream: true,
+		Model:    op.model,
+		Messages: messages,
+		Stream:   true,
 	}
 }
 
-func (op *OllamaProvider) Chat(connector *types.BusConnector) {
-	if len(op.context) > 0 {
-		connector.Request.Context = op.context
+type OllamaChatRequest struct {
+	// Request Structure for Ollama's /api/chat
+	Model    string                  `json:"model"`
+	Messages []OllamaMessage         `json:"messages"`
+	Options  types.GenerationOptions `json:"options"`
+	Stream   bool                    `json:"stream"`
+}
+
+type OllamaMessage struct {
+	Role    string   `json:"role"`
+	Content string   `json:"content"`
+	Images  []string `json:"images,omitempty"`
+}
+
+type OllamaChatResponse struct {
+	// The counts and durations (in nanoseconds) are only set on the final chunk.
+	Message         OllamaMessage `json:"message"`
+	Done            bool          `json:"done"`
+	Error           string        `json:"error,omitempty"`
+	PromptEvalCount int           `json:"prompt_eval_count,omitempty"`
+	EvalCount       int           `json:"eval_count,omitempty"`
+	TotalDuration   int64         `json:"total_duration,omitempty"`
+}
+
+func newOllamaChatRequest(request *types.ChatRequest) *OllamaChatRequest {
+	messages := []OllamaMessage{}
+	if request.System != "" {
+		messages = append(messages, OllamaMessage{Role: types.RoleSystem, Content: request.System})
+	}
+	for _, m := range request.Messages {
+		messages = append(messages, OllamaMessage{Role: m.Role, Content: m.Content, Images: m.Images})
+	}
+	return &OllamaChatRequest{
+		Model:    request.Model,
+		Messages: messages,
+		Options:  request.Options,
+		Stream:   request.Stream,
 	}
-	data, err := json.Marshal(connector.Request)
-	op.logger.Debug(fmt.Sprintf("%v", connector.Request))
+}
+
+func (op *OllamaProvider) Chat(connector *types.BusConnector) {
+	data, err := json.Marshal(newOllamaChatRequest(connector.Request))
+	op.logger.Debug("sending chat request", "model", connector.Request.Model, "messages", len(connector.Request.Messages))
 	if err != nil {
 		connector.ErrorChan <- err
 		return
@@ -73,23 +120,35 @@ func (op *OllamaProvider) Chat(connector *types.BusConnector) {
 	}
 	defer res.Body.Close()
 	scanner := bufio.NewScanner(res.Body)
+	scanner.Buffer(make([]byte, 0, 64*1024), 1024*1024)
 	for scanner.Scan() {
 		line := scanner.Text()
-		var chunk types.ChatResponse
+		var chunk OllamaChatResponse
 		if err := json.Unmarshal([]byte(line), &chunk); err != nil {
 			continue
 		}
+		if chunk.Error != "" {
+			connector.ErrorChan <- fmt.Errorf("ollama: %s", chunk.Error)
+			return
+		}
 
-		if chunk.Response != "" {
-			connector.ResponseChan <- &chunk
+		if chunk.Message.Content != "" {
+			connector.ResponseChan <- &types.ChatResponse{Response: chunk.Message.Content}
 		}
 
 		if chunk.Done {
-			op.context = chunk.Context
+			connector.ResponseChan <- &types.ChatResponse{Usage: &types.Usage{
+				PromptTokens:     chunk.PromptEvalCount,
+				CompletionTokens: chunk.EvalCount,
+				Duration:         time.Duration(chunk.TotalDuration),
+			}}
 			connector.DoneChannel <- true
 			return
 		}
 	}
+	if err := scanner.Err(); err != nil {
+		connector.ErrorChan <- err
+	}
 }
 
 type OllamaResponse struct {
@@ -130,10 +189,25 @@ func (op *OllamaProvider) RetrieveModels() ([]types.Model, error) {
 		return nil, err
 	}
 
-	modelList := []types.Model{}
-	for _, v := range response.Models {
-		modelList = append(modelList, types.Model{Name: v.Name})
+	// Each model needs its own /api/show call, so they are made a few at a time
+	// rather than one after another.
+	modelList := make([]types.Model, len(response.Models))
+	limit := make(chan struct{}, showConcurrency)
+	var wg sync.WaitGroup
+	for i, v := range response.Models {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			limit <- struct{}{}
+			defer func() { <-limit }()
+			model, err := op.retrieveDetails(v.Name)
+			if err != nil {
+				op.logger.Warn("failed to retrieve model capabilities", "model", v.Name, "error", err)
+			}
+			modelList[i] = model
+		}()
 	}
+	wg.Wait()
 
 	if err := op.ModelRefresher.StashModels(modelList); err != nil {
 		op.logger.Error("failed to stash models", "error", err)
@@ -143,6 +217,56 @@ func (op *OllamaProvider) RetrieveModels() ([]types.Model, error) {
 	return modelList, nil
 }
 
+type OllamaShowResponse struct {
+	// ModelInfo keys are prefixed with the architecture, as in "llama.context_length".
+	Capabilities []string       `json:"capabilities"`
+	ModelInfo    map[string]any `json:"model_info"`
+}
+
+// retrieveDetails maps the capabilities reported by /api/show onto input modalities,
+// and reads the model's context length, capped at ServedContextLength. Ollama calls
+// image input "vision", and models that can only embed lack "completion". On error
+// only the name is filled in.
+func (op *OllamaProvider) retrieveDetails(name string) (types.Model, error) {
+	model := types.Model{Name: name}
+	data, err := json.Marshal(map[string]string{"model": name})
+	if err != nil {
+		return model, err
+	}
+	req, err := http.NewRequest("POST", ShowURL, bytes.NewReader(data))
+	if err != nil {
+		return model, err
+	}
+	req.Header.Add("Content-Type", "application/json")
+	client := http.Client{}
+	res, err := client.Do(req)
+	if err != nil {
+		return model, err
+	}
+	defer res.Body.Close()
+	if res.StatusCode != http.StatusOK {
+		return model, fmt.Errorf("request to show model failed with status: %d", res.StatusCode)
+	}
+	var response OllamaShowResponse
+	if err := json.NewDecoder(res.Body).Decode(&response); err != nil {
+		return model, err
+	}
+	model.Modalities = []string{types.ModalityText}
+	for _, c := range response.Capabilities {
+		if c == "vision" {
+			model.Modalities = append(model.Modalities, types.ModalityImage)
+		}
+	}
+	model.EmbedOnly = slices.Contains(response.Capabilities, "embedding") &&
+		!slices.Contains(response.Capabilities, "completion")
+	for key, value := range response.ModelInfo {
+		if n, ok := value.(float64); ok && strings.HasSuffix(key, ".context_length") {
+			model.ContextLength = min(int(n), ServedContextLength)
+		}
+	}
+	return model, nil
+}
+
 func (op *OllamaProvider) SetModel(model string) {
 	op.model = model
 }
@@ -3,6 +3,7 @@ package models
 import (
 	"bufio"
 	"bytes"
+	"encoding/base64"
 	"encoding/json"
 	"errors"
 	"fmt"
@@ -20,15 +21,21 @@ import (
 const (
 	ORApiURL     = "https://openrouter.ai/api/v1/chat/completions"
 	ORRefreshURL = "https://openrouter.ai/api/v1/models"
+	// OpenAIBaseURL is where NewOpenAICompatible goes without OPENAI_BASE_URL.
+	OpenAIBaseURL = "https://api.openai.com/v1"
 )
 
 type OpenRouter struct {
 	URL            string
+	EmbedURL       string
+	ModelsURL      string
 	ApiKey         string
 	ModelRefresher *types.ModelRefresher
 	Model          string
-	Context        []OpenRouterMessage
 	logger         *logger.Logger
+	// accountUsage asks for OpenRouter's usage accounting, which other OpenAI
+	// compatible servers reject.
+	accountUsage bool
 }
 
 func NewOpenRouter(logger *logger.Logger, model string, mf *types.ModelRefresher) (*OpenRouter, error) {
@@ -39,36 +46,105 @@ func NewOpenRouter(logger *logger.Logger, model string, mf *types.ModelRefresher
 
 	return &OpenRouter{
 		URL:            ORApiURL,
+		EmbedURL:       ORApiEmbedURL,
+		ModelsURL:      ORRefreshURL,
 		ApiKey:         APIKEY,
 		Model:          model,
 		logger:         logger,
 		ModelRefresher: mf,
+		accountUsage:   true,
 	}, nil
 }
 
-func (or *OpenRouter) GenerateRequest(prompt string) *types.ChatRequest {
+// NewOpenAICompatible talks to any server with OpenAI's chat, embeddings and models
+// endpoints under baseURL, such as api.openai.com/v1, vLLM or LM Studio. It reads
+// OPENAI_BASE_URL and OPENAI_API_KEY, defaulting to OpenAI itself.
+func NewOpenAICompatible(logger *logger.Logger, model string, mf *types.ModelRefresher) (*OpenRouter, error) {
+	apiKey := os.Getenv("OPENAI_API_KEY")
+	if apiKey == "" {
+		return nil, errors.New("OPENAI_API_KEY is required")
+	}
+	baseURL := strings.TrimSuffix(os.Getenv("OPENAI_BASE_URL"), "/")
+	if baseURL == "" {
+		baseURL = OpenAIBaseURL
+	}
+	return &OpenRouter{
+		URL:            baseURL + "/chat/completions",
+		EmbedURL:       baseURL + "/embeddings",
+		ModelsURL:      baseURL + "/models",
+		ApiKey:         apiKey,
+		Model:          model,
+		logger:         logger,
+		ModelRefresher: mf,
+	}, nil
+}
+
+func (or *OpenRouter) GenerateRequest(messages []types.Message) *types.ChatRequest {
 	// Generates the request the way that the Frontend UI expects.
 	return &types.ChatRequest{
-		Model:  or.Model,
-		Prompt: prompt,
-		Stream: true,
+		Model:    or.Model,
+		Messages: messages,
+		Stream:   true,
 	}
 }
 
 type OpenRouterMessage struct {
 	// Used to save context in the form of messages. User or Assistant
+	// Content is either a string or a []OpenRouterContentPart when images are attached.
 	Role    string `json:"role"`
-	Content string `json:"content"`
+	Content any    `json:"content"`
 }
 
-func (or *OpenRouter) buildScanner(conn *types.BusConnector,
-	msgs []OpenRouterMessage,
-) (*http.Response, error) {
+// newOpenRouterMessages converts the conversation, putting the system prompt first.
+func newOpenRouterMessages(request *types.ChatRequest) []OpenRouterMessage {
+	messages := []OpenRouterMessage{}
+	if request.System != "" {
+		messages = append(messages, OpenRouterMessage{Role: types.RoleSystem, Content: request.System})
+	}
+	for _, m := range request.Messages {
+		messages = append(messages, newOpenRouterMessage(m))
+	}
+	return messages
+}
+
+// newOpenRouterMessage switches to content parts when images are attached.
+func newOpenRouterMessage(m types.Message) OpenRouterMessage {
+	if len(m.Images) == 0 {
+		return OpenRouterMessage{Role: m.Role, Content: m.Content}
+	}
+	parts := []OpenRouterContentPart{{Type: "text", Text: m.Content}}
+	for _, img := range m.Images {
+		parts = append(parts, OpenRouterContentPart{
+			Type:     "image_url",
+			ImageURL: &OpenRouterImageURL{URL: imageDataURL(img)},
+		})
+	}
+	return OpenRouterMessage{Role: m.Role, Content: parts}
+}
+
+// imageDataURL wraps base64 image data in a data URL, sniffing the mime type from the
+// decoded header bytes.
+func imageDataURL(data string) string {
+	header := data
+	if len(header) > 64 {
+		header = header[:64]
+	}
+	raw, _ := base64.StdEncoding.DecodeString(header)
+	return fmt.Sprintf("data:%s;base64,%s", http.DetectContentType(raw), data)
+}
+
+func (or *OpenRouter) buildScanner(conn *types.BusConnector) (*http.Response, error) {
 	// Builds the *bufio.Scanner for the chat to iterate and read
 	request := OpenRouterRequest{
-		Model:    or.Model,
-		Messages: append(or.Context, msgs...),
-		Stream:   true,
+		Model:       conn.Request.Model,
+		Messages:    newOpenRouterMessages(conn.Request),
+		Temperature: conn.Request.Options.Temperature,
+		TopP:        conn.Request.Options.TopP,
+		MaxTokens:   conn.Request.Options.MaxTokens,
+		Stream:      true,
+	}
+	if or.accountUsage {
+		request.Usage = &OpenRouterUsageOpt{Include: true}
 	}
 	rawReq, err := json.Marshal(&request)
 	if err != nil {
@@ -98,26 +174,20 @@ func (or *OpenRouter) buildScanner(conn *types.BusConnector,
 
 func (or *OpenRouter) Chat(conn *types.BusConnector) {
 	// Handles Streaming LLM responses and forwarding to the ChatBus for the UI
-	messages := []OpenRouterMessage{{Role: "user", Content: conn.Request.Prompt}}
-	res, err := or.buildScanner(conn, messages)
+	res, err := or.buildScanner(conn)
 	if err != nil {
 		conn.ErrorChan <- err
 		return
 	}
 	defer res.Body.Close()
+	start := time.Now()
 	scanner := bufio.NewScanner(res.Body)
-	var assistantResponse strings.Builder
 	for scanner.Scan() {
 		line := scanner.Text()
 		if len(line) > 6 && line[:6] == "data: " {
 			data := line[6:]
 			if data == "[DONE]" {
-				messages = append(messages, OpenRouterMessage{
-					Role:    "assistant",
-					Content: assistantResponse.String(),
-				})
-				or.Context = append(or.Context, messages...)
-				or.logger.Debug("finished chat stream: %v", messages)
+				or.logger.Debug("finished chat stream", "model", conn.Request.Model)
 				conn.DoneChannel <- true
 				return
 			}
@@ -126,12 +196,23 @@ func (or *OpenRouter) Chat(conn *types.BusConnector) {
 				conn.ErrorChan <- err
 				return
 			}
+			if response.Usage != nil {
+				// The usage chunk comes last, just before [DONE].
+				conn.ResponseChan <- &types.ChatResponse{Usage: &types.Usage{
+					PromptTokens:     response.Usage.PromptTokens,
+					CompletionTokens: response.Usage.CompletionTokens,
+					Cost:             response.Usage.Cost,
+					Duration:         time.Since(start),
+				}}
+			}
 			if len(response.Choices) == 0 {
+				if response.Usage != nil {
+					continue
+				}
 				conn.DoneChannel <- true
 				return
 			}
 			textResponse := response.Choices[0].Delta.Content
-			assistantResponse.WriteString(textResponse)
 			returnRes := &types.ChatResponse{Response: textResponse}
 			conn.ResponseChan <- returnRes
 		}
@@ -147,10 +228,13 @@ func (or *OpenRouter) RetrieveModels() ([]types.Model, error) {
 	if !or.ModelRefresher.IsStale() {
 		return or.ModelRefresher.RetrieveModels(), nil
 	}
-	req, err := http.NewRequest("GET", ORRefreshURL, nil) // TODO: Needs some kind of context
+	req, err := http.NewRequest("GET", or.ModelsURL, nil) // TODO: Needs some kind of context
 	if err != nil {
 		return nil, err
 	}
+	if or.ApiKey != "" {
+		req.Header.Add("Authorization", fmt.Sprintf("Bearer %s", or.ApiKey))
+	}
 	client := http.Client{}
 
 	res, err := client.Do(req)
@@ -173,7 +257,9 @@ func (or *OpenRouter) RetrieveModels() ([]types.Model, error) {
 	modelsList := []types.Model{}
 	for _, v := range response.Data {
 		newM := types.Model{
-			Name: v.ID,
+			Name:          v.ID,
+			Modalities:    v.Architecture.InputModalities,
+			ContextLength: v.ContextLength,
 		}
 		modelsList = append(modelsList, newM)
 	}
@@ -2,7 +2,27 @@ package models
 
 type OpenRouterRequest struct {
 	// Request Structure unique to OpenRouter
-	Model    string              `json:"model"`
-	Messages []OpenRouterMessage `json:"messages"`
-	Stream   bool                `json:"stream"`
+	Model       string              `json:"model"`
+	Messages    []OpenRouterMessage `json:"messages"`
+	Temperature *float64            `json:"temperature,omitempty"`
+	TopP        *float64            `json:"top_p,omitempty"`
+	MaxTokens   *int                `json:"max_tokens,omitempty"`
+	Stream      bool                `json:"stream"`
+	Usage       *OpenRouterUsageOpt `json:"usage,omitempty"`
+}
+
+type OpenRouterUsageOpt struct {
+	// Asks for token counts and cost in the last chunk of the stream.
+	Include bool `json:"include"`
+}
+
+type OpenRouterContentPart struct {
+	// A single part of a multimodal message. Plain text messages send a string instead.
+	Type     string              `json:"type"`
+	Text     string              `json:"text,omitempty"`
+	ImageURL *OpenRouterImageURL `json:"image_url,omitempty"`
+}
+
+type OpenRouterImageURL struct {
+	URL string `json:"url"`
 }
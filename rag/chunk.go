@@ -0,0 +1,55 @@
+package rag
+
+import (
+	"strings"
+)
+
+const (
+	// chunkLines is how many lines a chunk spans at most, and chunkOverlap how many it
+	// shares with the one before so a passage cut in two is still found whole.
+	chunkLines   = 40
+	chunkOverlap = 8
+	// maxChunkChars ends a chunk early on files with long lines.
+	maxChunkChars = 2000
+)
+
+type Chunk struct {
+	// A run of lines from a file. Path is relative to the index root and the lines
+	// count from 1, inclusive. Vector is normalized to unit length.
+	Path      string
+	StartLine int
+	EndLine   int
+	Text      string
+	Vector    []float32
+}
+
+// Split cuts text into overlapping chunks of whole lines. Within the last third of a
+// window it prefers to end on a blank line, so paragraphs and functions stay together.
+func Split(path, text string) []Chunk {
+	lines := strings.Split(strings.TrimRight(text, "\n"), "\n")
+	chunks := []Chunk{}
+	for start := 0; start < len(lines); {
+		end, size := start, 0
+		for end < len(lines) && end-start < chunkLines && (end == start || size+len(lines[end]) <= maxChunkChars) {
+			size += len(lines[end]) + 1
+			end++
+		}
+		if end < len(lines) {
+			for i := end - 1; i > start+(end-start)*2/3; i-- {
+				if strings.TrimSpace(lines[i]) == "" {
+					end = i + 1
+					break
+				}
+			}
+		}
+		body := strings.Join(lines[start:end], "\n")
+		if strings.TrimSpace(body) != "" {
+			chunks = append(chunks, Chunk{Path: path, StartLine: start + 1, EndLine: end, Text: body})
+		}
+		if end == len(lines) {
+			break
+		}
+		start = max(end-chunkOverlap, start+1)
+	}
+	return chunks
+}
@@ -0,0 +1,79 @@
+package rag
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+)
+
+// numbered makes n lines of text, with the lines listed in blank left empty.
+func numbered(n int, blank ...int) string {
+	lines := make([]string, n)
+	for i := range lines {
+		lines[i] = fmt.Sprintf("line %d", i+1)
+	}
+	for _, b := range blank {
+		lines[b-1] = ""
+	}
+	return strings.Join(lines, "\n") + "\n"
+}
+
+func TestSplitRanges(t *testing.T) {
+	tests := []struct {
+		name string
+		text string
+		want [][2]int
+	}{
+		{"empty", "", nil},
+		{"blank", "\n\n  \n", nil},
+		{"one line", "hello", [][2]int{{1, 1}}},
+		{"short", numbered(10), [][2]int{{1, 10}}},
+		{"exactly one window", numbered(chunkLines), [][2]int{{1, 40}}},
+		{"long", numbered(100), [][2]int{{1, 40}, {33, 72}, {65, 100}}},
+		{"ends on a blank line", numbered(60, 36), [][2]int{{1, 36}, {29, 60}}},
+		// A blank line early in the window is ignored rather than leaving a short chunk.
+		{"early blank line", numbered(60, 5), [][2]int{{1, 40}, {33, 60}}},
+		{"long lines", strings.Repeat(strings.Repeat("x", 999)+"\n", 5), [][2]int{{1, 2}, {2, 3}, {3, 4}, {4, 5}}},
+		{"one huge line", strings.Repeat("x", 3*maxChunkChars), [][2]int{{1, 1}}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			chunks := Split("doc.md", tt.text)
+			var got [][2]int
+			for _, c := range chunks {
+				got = append(got, [2]int{c.StartLine, c.EndLine})
+			}
+			if fmt.Sprint(got) != fmt.Sprint(tt.want) {
+				t.Errorf("Split ranges = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestSplitText(t *testing.T) {
+	text := numbered(200, 20, 50, 51, 90, 130, 131, 132, 170)
+	lines := strings.Split(strings.TrimRight(text, "\n"), "\n")
+	chunks := Split("src/main.go", text)
+	covered := 0
+	for i, c := range chunks {
+		if c.Path != "src/main.go" {
+			t.Errorf("chunk %d has path %q", i, c.Path)
+		}
+		if want := strings.Join(lines[c.StartLine-1:c.EndLine], "\n"); c.Text != want {
+			t.Errorf("chunk %d (%d-%d) text doesn't match its lines", i, c.StartLine, c.EndLine)
+		}
+		if n := c.EndLine - c.StartLine + 1; n > chunkLines {
+			t.Errorf("chunk %d spans %d lines", i, n)
+		}
+		if c.StartLine > covered+1 {
+			t.Errorf("lines %d-%d are in no chunk", covered+1, c.StartLine-1)
+		}
+		if i > 0 && c.StartLine <= chunks[i-1].StartLine {
+			t.Errorf("chunk %d starts at %d, not after chunk %d", i, c.StartLine, i-1)
+		}
+		covered = max(covered, c.EndLine)
+	}
+	if covered != len(lines) {
+		t.Errorf("chunks end at line %d of %d", covered, len(lines))
+	}
+}
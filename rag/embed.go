@@ -0,0 +1,59 @@
+package rag
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+
+	"github.com/falbanese9484/terminal-chat/types"
+)
+
+// DefaultEmbedModel is used unless BUTLER_EMBED_MODEL names another.
+const DefaultEmbedModel = "ollama:nomic-embed-text"
+
+type Embedder interface {
+	// Embed returns one vector per text, in order. Model names what the vectors were
+	// made with; vectors from different models can't be compared.
+	Embed(ctx context.Context, texts []string) ([][]float32, error)
+	Model() string
+}
+
+type providerEmbedder struct {
+	provider *types.ProviderService
+	name     string
+	model    string
+}
+
+// NewEmbedder embeds with a "provider:model" spec through a configured provider that
+// can embed. The spec is resolved the way chat models are, so a model without a
+// provider prefix goes to the default provider.
+func NewEmbedder(providers *types.ProviderRegistry, spec string) (Embedder, error) {
+	provider, model, err := providers.Resolve(spec)
+	if err != nil {
+		return nil, fmt.Errorf("no provider for embedding model %q: %w", spec, err)
+	}
+	if model == "" {
+		return nil, errors.New("no embedding model given")
+	}
+	name, _ := providers.NameOf(provider)
+	if !provider.CanEmbed() {
+		return nil, fmt.Errorf("%s: %w", name, types.ErrNoEmbeddings)
+	}
+	return &providerEmbedder{provider: provider, name: name, model: model}, nil
+}
+
+// DefaultEmbedder reads BUTLER_EMBED_MODEL.
+func DefaultEmbedder(providers *types.ProviderRegistry) (Embedder, error) {
+	spec := os.Getenv("BUTLER_EMBED_MODEL")
+	if spec == "" {
+		spec = DefaultEmbedModel
+	}
+	return NewEmbedder(providers, spec)
+}
+
+func (e *providerEmbedder) Model() string { return e.name + ":" + e.model }
+
+func (e *providerEmbedder) Embed(ctx context.Context, texts []string) ([][]float32, error) {
+	return e.provider.Embed(ctx, e.model, texts, nil)
+}
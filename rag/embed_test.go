@@ -0,0 +1,75 @@
+package rag
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/falbanese9484/terminal-chat/types"
+)
+
+type chatOnly struct{}
+
+func (chatOnly) Chat(c *types.BusConnector) {}
+func (chatOnly) GenerateRequest(messages []types.Message) *types.ChatRequest {
+	return &types.ChatRequest{}
+}
+func (chatOnly) RetrieveModels() ([]types.Model, error) { return nil, nil }
+func (chatOnly) SetModel(model string)                  {}
+
+type embedding struct {
+	chatOnly
+	// models records the model each Embed call asked for.
+	models []string
+}
+
+func (e *embedding) Embed(ctx context.Context, model string, inputs []string) ([][]float32, error) {
+	e.models = append(e.models, model)
+	return make([][]float32, len(inputs)), nil
+}
+
+func TestNewEmbedder(t *testing.T) {
+	ollama, openai := &embedding{}, &embedding{}
+	providers := types.NewProviderRegistry("ollama")
+	providers.Register("ollama", types.NewProviderService(ollama))
+	providers.Register("openai", types.NewProviderService(openai))
+	providers.Register("openrouter", types.NewProviderService(chatOnly{}))
+
+	tests := []struct {
+		spec     string
+		provider *embedding
+		model    string
+	}{
+		{"ollama:nomic-embed-text", ollama, "ollama:nomic-embed-text"},
+		{"nomic-embed-text", ollama, "ollama:nomic-embed-text"},
+		{"nomic-embed-text:latest", ollama, "ollama:nomic-embed-text:latest"},
+		{"ollama:nomic-embed-text:v1.5", ollama, "ollama:nomic-embed-text:v1.5"},
+		{"openai:text-embedding-3-small", openai, "openai:text-embedding-3-small"},
+	}
+	for _, tt := range tests {
+		e, err := NewEmbedder(providers, tt.spec)
+		if err != nil {
+			t.Errorf("NewEmbedder(%q) = %v", tt.spec, err)
+			continue
+		}
+		if e.Model() != tt.model {
+			t.Errorf("NewEmbedder(%q).Model() = %q, want %q", tt.spec, e.Model(), tt.model)
+		}
+		before := len(tt.provider.models)
+		if _, err := e.Embed(context.Background(), []string{"text"}); err != nil {
+			t.Fatal(err)
+		}
+		if len(tt.provider.models) != before+1 {
+			t.Errorf("NewEmbedder(%q) embedded with the wrong provider", tt.spec)
+		}
+	}
+
+	if _, err := NewEmbedder(providers, "openrouter:openai/text-embedding-3-small"); !errors.Is(err, types.ErrNoEmbeddings) {
+		t.Errorf("embedding through a chat only provider = %v", err)
+	}
+	for _, spec := range []string{"", "ollama:"} {
+		if _, err := NewEmbedder(providers, spec); err == nil {
+			t.Errorf("NewEmbedder(%q) should fail", spec)
+		}
+	}
+}
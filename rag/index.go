@@ -0,0 +1,296 @@
+package rag
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/gob"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io/fs"
+	"math"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/falbanese9484/terminal-chat/config"
+	"github.com/falbanese9484/terminal-chat/types"
+)
+
+const (
+	// batchSize is how many chunks are embedded between progress reports.
+	batchSize = types.EmbedBatchSize
+	// maxFileSize skips files too large to be documentation or source.
+	maxFileSize = 1 << 20
+)
+
+// skipDirs are never indexed, on top of hidden directories.
+var skipDirs = map[string]bool{"node_modules": true, "vendor": true, "__pycache__": true}
+
+type FileStamp struct {
+	// What a file looked like when it was indexed, to tell whether it changed.
+	ModTime time.Time
+	Size    int64
+}
+
+type Index struct {
+	// The chunks of every text file under Root, embedded with Model. Files records
+	// the files indexed so unchanged ones are skipped when indexing again.
+	Root    string
+	Model   string
+	Files   map[string]FileStamp
+	Chunks  []Chunk
+	Updated time.Time
+
+	path     string
+	embedder Embedder
+	skipped  []error
+}
+
+type Progress struct {
+	// Files is how many files were read and Embedded how many of the Pending new
+	// chunks have vectors so far.
+	Files    int
+	Embedded int
+	Pending  int
+}
+
+type Hit struct {
+	Chunk
+	Score float64
+}
+
+// Open loads the index of root kept under the config directory, or starts an empty one.
+// An index made with another embedding model is started over.
+func Open(root string, embedder Embedder) (*Index, error) {
+	abs, err := filepath.Abs(root)
+	if err != nil {
+		return nil, err
+	}
+	if info, err := os.Stat(abs); err != nil {
+		return nil, err
+	} else if !info.IsDir() {
+		return nil, fmt.Errorf("%s is not a directory", root)
+	}
+	sum := sha256.Sum256([]byte(abs))
+	path, err := config.Path("indexes", hex.EncodeToString(sum[:8])+".gob")
+	if err != nil {
+		return nil, err
+	}
+	ix := &Index{Root: abs, Model: embedder.Model(), Files: map[string]FileStamp{}}
+	data, err := os.ReadFile(path)
+	switch {
+	case errors.Is(err, fs.ErrNotExist):
+	case err != nil:
+		return nil, err
+	default:
+		stored := &Index{}
+		if err := gob.NewDecoder(bytes.NewReader(data)).Decode(stored); err != nil {
+			return nil, fmt.Errorf("failed to read index of %s: %w", root, err)
+		}
+		if stored.Model == ix.Model {
+			ix = stored
+		}
+	}
+	ix.path, ix.embedder = path, embedder
+	return ix, nil
+}
+
+// Build brings the index up to date with the files under Root, embedding only files
+// that are new or changed, and saves it.
+func (ix *Index) Build(ctx context.Context, progress func(Progress)) error {
+	files := map[string]FileStamp{}
+	kept := []Chunk{}
+	byPath := map[string][]Chunk{}
+	for _, c := range ix.Chunks {
+		byPath[c.Path] = append(byPath[c.Path], c)
+	}
+	pending := []Chunk{}
+	p := Progress{}
+	ix.skipped = nil
+	err := filepath.WalkDir(ix.Root, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			// Only the root failing stops the walk; anything below it is left out.
+			if path == ix.Root {
+				return err
+			}
+			ix.skipped = append(ix.skipped, err)
+			if d != nil && d.IsDir() {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if d.IsDir() {
+			if path != ix.Root && (strings.HasPrefix(d.Name(), ".") || skipDirs[d.Name()]) {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if !d.Type().IsRegular() || strings.HasPrefix(d.Name(), ".") {
+			return nil
+		}
+		info, err := d.Info()
+		if err != nil {
+			ix.skipped = append(ix.skipped, err)
+			return nil
+		}
+		if info.Size() == 0 || info.Size() > maxFileSize {
+			return nil
+		}
+		rel, _ := filepath.Rel(ix.Root, path)
+		stamp := FileStamp{ModTime: info.ModTime(), Size: info.Size()}
+		if old, ok := ix.Files[rel]; ok && old == stamp {
+			files[rel] = stamp
+			kept = append(kept, byPath[rel]...)
+			return nil
+		}
+		data, err := os.ReadFile(path)
+		if err != nil {
+			ix.skipped = append(ix.skipped, err)
+			return nil
+		}
+		if !isText(data) {
+			return nil
+		}
+		files[rel] = stamp
+		pending = append(pending, Split(rel, string(data))...)
+		p.Files++
+		return ctx.Err()
+	})
+	if err != nil {
+		return fmt.Errorf("failed to read %s: %w", ix.Root, err)
+	}
+	p.Pending = len(pending)
+	if progress != nil {
+		progress(p)
+	}
+	for start := 0; start < len(pending); start += batchSize {
+		batch := pending[start:min(start+batchSize, len(pending))]
+		texts := make([]string, len(batch))
+		for i, c := range batch {
+			texts[i] = c.Path + "\n" + c.Text
+		}
+		vectors, err := ix.embedder.Embed(ctx, texts)
+		if err != nil {
+			return err
+		}
+		for i := range batch {
+			batch[i].Vector = normalize(vectors[i])
+		}
+		p.Embedded += len(batch)
+		if progress != nil {
+			progress(p)
+		}
+	}
+	ix.Files = files
+	ix.Chunks = append(kept, pending...)
+	ix.Updated = time.Now()
+	return ix.Save()
+}
+
+// Skipped lists the files and directories the last Build couldn't read.
+func (ix *Index) Skipped() []error {
+	return ix.skipped
+}
+
+// Save writes the index to disk, through a temporary file so an interrupted save
+// leaves the previous index in place.
+func (ix *Index) Save() error {
+	var buf bytes.Buffer
+	if err := gob.NewEncoder(&buf).Encode(ix); err != nil {
+		return fmt.Errorf("failed to encode index: %w", err)
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(ix.path), ".index-*")
+	if err != nil {
+		return fmt.Errorf("failed to save index: %w", err)
+	}
+	if _, err := tmp.Write(buf.Bytes()); err != nil {
+		tmp.Close()
+		os.Remove(tmp.Name())
+		return fmt.Errorf("failed to save index: %w", err)
+	}
+	if err := tmp.Close(); err != nil {
+		os.Remove(tmp.Name())
+		return fmt.Errorf("failed to save index: %w", err)
+	}
+	if err := os.Rename(tmp.Name(), ix.path); err != nil {
+		os.Remove(tmp.Name())
+		return fmt.Errorf("failed to save index: %w", err)
+	}
+	return nil
+}
+
+// Search returns the k chunks closest to query, best first.
+func (ix *Index) Search(ctx context.Context, query string, k int) ([]Hit, error) {
+	if len(ix.Chunks) == 0 {
+		return nil, nil
+	}
+	vectors, err := ix.embedder.Embed(ctx, []string{query})
+	if err != nil {
+		return nil, err
+	}
+	q := normalize(vectors[0])
+	hits := make([]Hit, 0, len(ix.Chunks))
+	for _, c := range ix.Chunks {
+		if len(c.Vector) != len(q) {
+			continue
+		}
+		score := 0.0
+		for i, x := range q {
+			score += float64(x * c.Vector[i])
+		}
+		hits = append(hits, Hit{Chunk: c, Score: score})
+	}
+	sort.SliceStable(hits, func(i, j int) bool { return hits[i].Score > hits[j].Score })
+	return hits[:min(k, len(hits))], nil
+}
+
+// DisplayPath is where a file of the index is, relative to the working directory when
+// it is under it.
+func (ix *Index) DisplayPath(rel string) string {
+	path := filepath.Join(ix.Root, rel)
+	if wd, err := os.Getwd(); err == nil {
+		if rel, err := filepath.Rel(wd, path); err == nil && !strings.HasPrefix(rel, "..") {
+			return rel
+		}
+	}
+	return path
+}
+
+// Cite names where a hit came from as path:start-end.
+func (ix *Index) Cite(h Hit) string {
+	return fmt.Sprintf("%s:%d-%d", ix.DisplayPath(h.Path), h.StartLine, h.EndLine)
+}
+
+func normalize(v []float32) []float32 {
+	norm := 0.0
+	for _, x := range v {
+		norm += float64(x * x)
+	}
+	if norm == 0 {
+		return v
+	}
+	scale := float32(1 / math.Sqrt(norm))
+	out := make([]float32, len(v))
+	for i, x := range v {
+		out[i] = x * scale
+	}
+	return out
+}
+
+// isText rejects binary files: ones with a NUL byte or that aren't UTF-8 near the start.
+func isText(data []byte) bool {
+	head := data[:min(len(data), 8192)]
+	if bytes.IndexByte(head, 0) >= 0 {
+		return false
+	}
+	// The cut may land inside a rune.
+	for i := 0; i < utf8.UTFMax && len(head) > 0 && !utf8.Valid(head); i++ {
+		head = head[:len(head)-1]
+	}
+	return utf8.Valid(head)
+}
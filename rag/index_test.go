@@ -0,0 +1,116 @@
+package rag
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+type fakeEmbedder struct{}
+
+// Embed puts each text on one of two axes, by whether it mentions "apple".
+func (fakeEmbedder) Embed(ctx context.Context, texts []string) ([][]float32, error) {
+	vectors := make([][]float32, len(texts))
+	for i, text := range texts {
+		if strings.Contains(text, "apple") {
+			vectors[i] = []float32{1, 0}
+		} else {
+			vectors[i] = []float32{0, 1}
+		}
+	}
+	return vectors, nil
+}
+
+func (fakeEmbedder) Model() string { return "fake:model" }
+
+func writeTree(t *testing.T, files map[string]string) string {
+	t.Helper()
+	root := t.TempDir()
+	for name, body := range files {
+		path := filepath.Join(root, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(body), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return root
+}
+
+func TestBuildAndSave(t *testing.T) {
+	t.Setenv("BUTLER_HOME", t.TempDir())
+	root := writeTree(t, map[string]string{
+		"fruit.md":          "an apple a day\n",
+		"docs/other.md":     "nothing to see\n",
+		".git/config":       "hidden apple\n",
+		"node_modules/x.js": "apple",
+	})
+	ix, err := Open(root, fakeEmbedder{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := ix.Build(context.Background(), nil); err != nil {
+		t.Fatal(err)
+	}
+	if len(ix.Files) != 2 || len(ix.Chunks) != 2 || len(ix.Skipped()) != 0 {
+		t.Fatalf("indexed %v, %d chunks, skipped %v", ix.Files, len(ix.Chunks), ix.Skipped())
+	}
+	hits, err := ix.Search(context.Background(), "apple?", 1)
+	if err != nil || len(hits) != 1 || hits[0].Path != "fruit.md" {
+		t.Fatalf("Search = %+v, %v", hits, err)
+	}
+
+	entries, _ := os.ReadDir(filepath.Dir(ix.path))
+	if len(entries) != 1 {
+		t.Errorf("Save left %d files in the index directory", len(entries))
+	}
+	reopened, err := Open(root, fakeEmbedder{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(reopened.Chunks) != 2 || reopened.Model != "fake:model" {
+		t.Errorf("reopened index has %d chunks from %s", len(reopened.Chunks), reopened.Model)
+	}
+}
+
+func TestBuildSkipsUnreadable(t *testing.T) {
+	if os.Geteuid() == 0 {
+		t.Skip("root can read everything")
+	}
+	t.Setenv("BUTLER_HOME", t.TempDir())
+	root := writeTree(t, map[string]string{
+		"fruit.md":         "an apple a day\n",
+		"locked/secret.md": "apple\n",
+	})
+	locked := filepath.Join(root, "locked")
+	if err := os.Chmod(locked, 0); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { os.Chmod(locked, 0o755) })
+	ix, err := Open(root, fakeEmbedder{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := ix.Build(context.Background(), nil); err != nil {
+		t.Fatalf("Build = %v, want the unreadable directory skipped", err)
+	}
+	if len(ix.Files) != 1 || len(ix.Skipped()) != 1 {
+		t.Errorf("indexed %v, skipped %v", ix.Files, ix.Skipped())
+	}
+}
+
+func TestBuildFailsOnMissingRoot(t *testing.T) {
+	t.Setenv("BUTLER_HOME", t.TempDir())
+	root := t.TempDir()
+	ix, err := Open(root, fakeEmbedder{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	os.RemoveAll(root)
+	if err := ix.Build(context.Background(), nil); err == nil {
+		t.Error("Build of a removed root should fail")
+	}
+}
@@ -1,9 +1,14 @@
 package main
 
 import (
+	"fmt"
+	"log"
 	"os"
 
 	"github.com/falbanese9484/terminal-chat/chat"
+	"github.com/falbanese9484/terminal-chat/logger"
+	"github.com/falbanese9484/terminal-chat/providers/models"
+	"github.com/falbanese9484/terminal-chat/types"
 )
 
 func main() {
@@ -14,15 +19,23 @@ func main() {
 	} else {
 		prompt = "Hey there! Please give me a recursive function in rust for the fibonacci sequence"
 	}
-	bus := chat.NewChatBus()
-	request := chat.ChatRequest{
-		Model:  "llama3.2",
-		Prompt: prompt,
-		Stream: true,
+	logger, err := logger.NewSafeLogger(true)
+	if err != nil {
+		log.Fatalf("%v", err)
 	}
+	ollama := models.NewOllamaProvider(logger, "llama3.2", types.NewModelRefresher(3600))
+	modelProvider := types.NewProviderService(ollama)
+	bus := chat.NewChatBus(logger, modelProvider)
+	byteReader := make(chan *types.ChatResponse, 100)
+	go bus.Start(byteReader)
 
-	go bus.Start()
-
-	bus.RunChat(&request)
-	select {}
+	request := modelProvider.GenerateRequest([]types.Message{{Role: types.RoleUser, Content: prompt}})
+	bus.RunChat("", request)
+	for response := range byteReader {
+		fmt.Print(response.Response)
+		if response.Done {
+			fmt.Println(response.Error)
+			return
+		}
+	}
 }
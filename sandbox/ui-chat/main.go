@@ -79,6 +79,7 @@ type model struct {
 	ChatBus           *chat.ChatBus
 	ByteReader        chan *types.ChatResponse
 	currentAIResponse string
+	history           []types.Message
 	modelProvider     *types.ProviderService
 	modelName         string
 	logger            *logger.Logger
@@ -143,7 +144,7 @@ func initialModel(m string) model {
 	// ollama := models.NewOllamaProvider(logger, m)
 	// modelProvider := types.NewProviderService(ollama)
 	// TODO: Need to make this part dynamic depending on env or select
-	openRouter, err := models.NewOpenRouter(logger, m)
+	openRouter, err := models.NewOpenRouter(logger, m, types.NewModelRefresher(3600))
 	if err != nil {
 		logger.Fatal("failed to initialize openRouter", "error", err)
 	}
@@ -243,6 +244,10 @@ func (m model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 		if !msg.Done {
 			return m, waitForChatResponse(m.ByteReader)
 		} else {
+			if msg.Error != "" {
+				m.logger.Debug("UI:chat failed", "error", msg.Error)
+			}
+			m.history = append(m.history, types.Message{Role: types.RoleAssistant, Content: m.currentAIResponse})
 			renderedtext, _ := m.renderer.Render(m.currentAIResponse)
 			m.messages = append(m.messages, formatMessage(m.modelName, renderedtext, m.aiStyle))
 			m.currentAIResponse = ""
@@ -265,8 +270,10 @@ func (m model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 					strings.Join(m.messages, "\n")))
 			m.textarea.Reset()
 			m.viewport.GotoBottom()
-			request := m.modelProvider.GenerateRequest(prompt)
-			go m.ChatBus.RunChat(request)
+			// Providers keep no history of their own, so the whole conversation goes out.
+			m.history = append(m.history, types.Message{Role: types.RoleUser, Content: prompt})
+			request := m.modelProvider.GenerateRequest(m.history)
+			m.ChatBus.RunChat("", request)
 			return m, waitForChatResponse(m.ByteReader)
 		}
 
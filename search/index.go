@@ -0,0 +1,167 @@
+package search
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/falbanese9484/terminal-chat/sessions"
+)
+
+// indexFile sits in the session directory; the store skips dot files.
+const indexFile = ".search-index.json"
+
+type Index struct {
+	// Sessions holds the term counts of every indexed message, keyed by session ID.
+	// The inverted postings are built from it when the index is loaded, so only the
+	// per-session data is stored and a changed session is reindexed on its own.
+	Sessions map[string]*sessionEntry `json:"sessions"`
+
+	store    *sessions.Store
+	postings map[string][]posting
+	docs     []*document
+	totalLen int
+}
+
+type sessionEntry struct {
+	Title   string      `json:"title"`
+	ModTime time.Time   `json:"mod_time"`
+	Docs    []*document `json:"docs"`
+}
+
+type document struct {
+	// A message of a session, the unit results are ranked by.
+	MessageID string         `json:"message_id"`
+	Role      string         `json:"role"`
+	Length    int            `json:"length"`
+	Terms     map[string]int `json:"terms"`
+	session   string
+}
+
+type posting struct {
+	doc int
+	tf  int
+}
+
+// Open loads the index kept with the store and brings it up to date with the sessions
+// on disk. Only sessions that changed since the last run are read again.
+func Open(store *sessions.Store) (*Index, error) {
+	idx := &Index{Sessions: map[string]*sessionEntry{}, store: store}
+	data, err := os.ReadFile(idx.path())
+	switch {
+	case errors.Is(err, os.ErrNotExist):
+	case err != nil:
+		return nil, fmt.Errorf("failed to read search index: %w", err)
+	default:
+		// A damaged index is rebuilt rather than reported.
+		if json.Unmarshal(data, idx) != nil || idx.Sessions == nil {
+			idx.Sessions = map[string]*sessionEntry{}
+		}
+	}
+	changed, err := idx.refresh()
+	if err != nil {
+		return nil, err
+	}
+	if changed {
+		if err := idx.save(); err != nil {
+			return nil, err
+		}
+	}
+	idx.build()
+	return idx, nil
+}
+
+func (idx *Index) path() string {
+	return filepath.Join(idx.store.Dir, indexFile)
+}
+
+func (idx *Index) refresh() (bool, error) {
+	ids, err := idx.store.IDs()
+	if err != nil {
+		return false, err
+	}
+	changed := false
+	seen := map[string]bool{}
+	for _, id := range ids {
+		seen[id] = true
+		info, err := os.Stat(filepath.Join(idx.store.Dir, id+".json"))
+		if err != nil {
+			continue
+		}
+		if entry, ok := idx.Sessions[id]; ok && entry.ModTime.Equal(info.ModTime()) {
+			continue
+		}
+		session, err := idx.store.Load(id)
+		if err != nil {
+			continue
+		}
+		idx.Sessions[id] = indexSession(session, info.ModTime())
+		changed = true
+	}
+	for id := range idx.Sessions {
+		if !seen[id] {
+			delete(idx.Sessions, id)
+			changed = true
+		}
+	}
+	return changed, nil
+}
+
+func indexSession(s *sessions.Session, modTime time.Time) *sessionEntry {
+	entry := &sessionEntry{Title: s.Title, ModTime: modTime}
+	for _, m := range s.Messages {
+		terms := map[string]int{}
+		length := 0
+		for _, t := range Tokenize(m.Content) {
+			terms[t]++
+			length++
+		}
+		if length == 0 {
+			continue
+		}
+		entry.Docs = append(entry.Docs, &document{MessageID: m.ID, Role: m.Role, Length: length, Terms: terms})
+	}
+	return entry
+}
+
+// build inverts the per-message term counts into postings lists.
+func (idx *Index) build() {
+	idx.postings = map[string][]posting{}
+	idx.docs = nil
+	idx.totalLen = 0
+	for id, entry := range idx.Sessions {
+		for _, doc := range entry.Docs {
+			doc.session = id
+			n := len(idx.docs)
+			idx.docs = append(idx.docs, doc)
+			idx.totalLen += doc.Length
+			for term, tf := range doc.Terms {
+				idx.postings[term] = append(idx.postings[term], posting{doc: n, tf: tf})
+			}
+		}
+	}
+}
+
+func (idx *Index) save() error {
+	data, err := json.Marshal(idx)
+	if err != nil {
+		return err
+	}
+	tmp, err := os.CreateTemp(idx.store.Dir, ".search-index-*")
+	if err != nil {
+		return fmt.Errorf("failed to save search index: %w", err)
+	}
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		os.Remove(tmp.Name())
+		return fmt.Errorf("failed to save search index: %w", err)
+	}
+	if err := tmp.Close(); err != nil {
+		os.Remove(tmp.Name())
+		return fmt.Errorf("failed to save search index: %w", err)
+	}
+	return os.Rename(tmp.Name(), idx.path())
+}
@@ -0,0 +1,121 @@
+package search
+
+import (
+	"math"
+	"sort"
+	"strings"
+	"unicode"
+
+	"github.com/falbanese9484/terminal-chat/types"
+)
+
+// BM25 parameters, at their usual values.
+const (
+	k1 = 1.2
+	b  = 0.75
+)
+
+type Result struct {
+	SessionID string
+	Title     string
+	MessageID string
+	Role      string
+	Score     float64
+	// Snippet is the part of the message around the first match. Terms are marked by
+	// the highlight function passed to Search.
+	Snippet string
+}
+
+// Tokenize lowercases text and splits it into words of letters and digits. Words
+// joined by dots, dashes or underscores, like kube-proxy or config.yaml, are kept
+// whole as well as split into their parts.
+func Tokenize(text string) []string {
+	tokens := []string{}
+	for _, field := range strings.FieldsFunc(strings.ToLower(text), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r) && !strings.ContainsRune("._-", r)
+	}) {
+		word := strings.Trim(field, "._-")
+		if word == "" {
+			continue
+		}
+		tokens = append(tokens, word)
+		if parts := strings.FieldsFunc(word, func(r rune) bool { return strings.ContainsRune("._-", r) }); len(parts) > 1 {
+			tokens = append(tokens, parts...)
+		}
+	}
+	return tokens
+}
+
+// Search ranks messages against query with BM25 and returns the best limit of them,
+// loading the matching sessions to cut snippets. A nil highlight leaves terms unmarked.
+func (idx *Index) Search(query string, limit int, highlight func(string) string) []Result {
+	terms := unique(Tokenize(query))
+	if len(terms) == 0 || len(idx.docs) == 0 {
+		return nil
+	}
+	avgLen := float64(idx.totalLen) / float64(len(idx.docs))
+	scores := map[int]float64{}
+	for _, term := range terms {
+		postings := idx.postings[term]
+		if len(postings) == 0 {
+			continue
+		}
+		n := float64(len(postings))
+		idf := math.Log(1 + (float64(len(idx.docs))-n+0.5)/(n+0.5))
+		for _, p := range postings {
+			tf := float64(p.tf)
+			norm := tf + k1*(1-b+b*float64(idx.docs[p.doc].Length)/avgLen)
+			scores[p.doc] += idf * tf * (k1 + 1) / norm
+		}
+	}
+
+	ranked := make([]int, 0, len(scores))
+	for doc := range scores {
+		ranked = append(ranked, doc)
+	}
+	sort.Slice(ranked, func(i, j int) bool {
+		if scores[ranked[i]] != scores[ranked[j]] {
+			return scores[ranked[i]] > scores[ranked[j]]
+		}
+		// Newer sessions first on a tie; IDs sort by time.
+		return idx.docs[ranked[i]].session > idx.docs[ranked[j]].session
+	})
+	if limit > 0 && len(ranked) > limit {
+		ranked = ranked[:limit]
+	}
+
+	results := make([]Result, 0, len(ranked))
+	contents := map[string]map[string]types.Message{}
+	for _, n := range ranked {
+		doc := idx.docs[n]
+		if _, ok := contents[doc.session]; !ok {
+			contents[doc.session] = map[string]types.Message{}
+			if s, err := idx.store.Load(doc.session); err == nil {
+				for _, m := range s.Messages {
+					contents[doc.session][m.ID] = m
+				}
+			}
+		}
+		results = append(results, Result{
+			SessionID: doc.session,
+			Title:     idx.Sessions[doc.session].Title,
+			MessageID: doc.MessageID,
+			Role:      doc.Role,
+			Score:     scores[n],
+			Snippet:   Snippet(contents[doc.session][doc.MessageID].Content, terms, highlight),
+		})
+	}
+	return results
+}
+
+func unique(terms []string) []string {
+	seen := map[string]bool{}
+	out := []string{}
+	for _, t := range terms {
+		if !seen[t] {
+			seen[t] = true
+			out = append(out, t)
+		}
+	}
+	return out
+}
@@ -0,0 +1,74 @@
+package search
+
+import (
+	"strings"
+	"unicode"
+)
+
+// snippetWidth is roughly how many characters of context a snippet shows.
+const snippetWidth = 160
+
+// Snippet cuts a window of text around the first query term, on one line, and marks
+// each whole-word match with highlight.
+func Snippet(text string, terms []string, highlight func(string) string) string {
+	text = strings.Join(strings.Fields(text), " ")
+	runes := []rune(text)
+	lower := []rune(strings.ToLower(text))
+
+	matches := [][2]int{}
+	for i := 0; i < len(lower); {
+		if i > 0 && isWordRune(lower[i-1]) {
+			i++
+			continue
+		}
+		matched := 0
+		for _, t := range terms {
+			tr := []rune(t)
+			end := i + len(tr)
+			if end <= len(lower) && string(lower[i:end]) == t && (end == len(lower) || !isWordRune(lower[end])) {
+				matched = max(matched, len(tr))
+			}
+		}
+		if matched > 0 {
+			matches = append(matches, [2]int{i, i + matched})
+			i += matched
+			continue
+		}
+		i++
+	}
+
+	start, end := 0, len(runes)
+	if len(runes) > snippetWidth {
+		if len(matches) > 0 {
+			start = max(0, matches[0][0]-snippetWidth/4)
+		}
+		end = min(len(runes), start+snippetWidth)
+		start = max(0, end-snippetWidth)
+	}
+	var sb strings.Builder
+	if start > 0 {
+		sb.WriteString("…")
+	}
+	pos := start
+	for _, m := range matches {
+		if m[0] < start || m[1] > end {
+			continue
+		}
+		sb.WriteString(string(runes[pos:m[0]]))
+		word := string(runes[m[0]:m[1]])
+		if highlight != nil {
+			word = highlight(word)
+		}
+		sb.WriteString(word)
+		pos = m[1]
+	}
+	sb.WriteString(string(runes[pos:end]))
+	if end < len(runes) {
+		sb.WriteString("…")
+	}
+	return sb.String()
+}
+
+func isWordRune(r rune) bool {
+	return unicode.IsLetter(r) || unicode.IsDigit(r) || r == '_'
+}
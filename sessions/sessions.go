@@ -0,0 +1,206 @@
+package sessions
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/falbanese9484/terminal-chat/config"
+	"github.com/falbanese9484/terminal-chat/types"
+)
+
+var ErrNotFound = errors.New("session not found")
+
+type Session struct {
+	ID           string `json:"id"`
+	Title        string `json:"title"`
+	Model        string `json:"model"`
+	SystemPrompt string `json:"system_prompt,omitempty"`
+	// Source names where an imported session came from, such as "chatgpt:<id>".
+	Source    string    `json:"source,omitempty"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+	// Messages is the active branch of the conversation, the one shown and sent to the
+	// provider. Nodes holds every message of every branch; see tree.go.
+	Messages []types.Message   `json:"messages"`
+	Nodes    []types.Message   `json:"-"`
+	Active   map[string]string `json:"active,omitempty"`
+	// Summary stands in for the start of the conversation once it no longer fits the
+	// model, when the summarize context strategy is used.
+	Summary *Summary `json:"summary,omitempty"`
+}
+
+type Summary struct {
+	// UpTo is the last message the summary covers. It only applies while that message
+	// is on the active branch.
+	UpTo string `json:"up_to"`
+	Text string `json:"text"`
+}
+
+// NewSession creates an empty session with a fresh ID.
+func NewSession(model string) *Session {
+	now := time.Now()
+	return &Session{
+		ID:        NewID(now),
+		Model:     model,
+		CreatedAt: now,
+		UpdatedAt: now,
+		Messages:  []types.Message{},
+		Active:    map[string]string{},
+	}
+}
+
+// NewID returns a sortable session ID: the creation time plus a random suffix.
+func NewID(t time.Time) string {
+	suffix := make([]byte, 3)
+	rand.Read(suffix)
+	return t.Format("20060102-150405") + "-" + hex.EncodeToString(suffix)
+}
+
+// DefaultTitle is the first line of the first user message, cut down to size.
+func (s *Session) DefaultTitle() string {
+	for _, m := range s.Messages {
+		if m.Role != types.RoleUser {
+			continue
+		}
+		title, _, _ := strings.Cut(strings.TrimSpace(m.Content), "\n")
+		return types.Truncate(title, 60)
+	}
+	return "Untitled"
+}
+
+type Store struct {
+	// Sessions are stored one JSON file per session in Dir.
+	Dir string
+}
+
+// NewStore opens the session store in dir, creating it if needed.
+func NewStore(dir string) (*Store, error) {
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, fmt.Errorf("failed to make session directory: %w", err)
+	}
+	return &Store{Dir: dir}, nil
+}
+
+// NewDefaultStore opens the store under the bash-butler config directory.
+func NewDefaultStore() (*Store, error) {
+	dir, err := config.Dir()
+	if err != nil {
+		return nil, err
+	}
+	return NewStore(filepath.Join(dir, "sessions"))
+}
+
+func (s *Store) path(id string) string {
+	return filepath.Join(s.Dir, filepath.Base(id)+".json")
+}
+
+// Save writes the session, replacing any earlier copy. The file is written to a temp
+// file first so a crash never leaves a half written session behind.
+func (s *Store) Save(session *Session) error {
+	session.UpdatedAt = time.Now()
+	if session.Title == "" {
+		session.Title = session.DefaultTitle()
+	}
+	data, err := json.MarshalIndent(session, "", "  ")
+	if err != nil {
+		return err
+	}
+	tmp, err := os.CreateTemp(s.Dir, ".session-*")
+	if err != nil {
+		return fmt.Errorf("failed to save session: %w", err)
+	}
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		os.Remove(tmp.Name())
+		return fmt.Errorf("failed to save session: %w", err)
+	}
+	if err := tmp.Close(); err != nil {
+		os.Remove(tmp.Name())
+		return fmt.Errorf("failed to save session: %w", err)
+	}
+	return os.Rename(tmp.Name(), s.path(session.ID))
+}
+
+// Load reads the session with the given ID. A unique ID prefix is accepted too.
+func (s *Store) Load(id string) (*Session, error) {
+	data, err := os.ReadFile(s.path(id))
+	if errors.Is(err, os.ErrNotExist) {
+		full, lookupErr := s.lookupPrefix(id)
+		if lookupErr != nil {
+			return nil, lookupErr
+		}
+		data, err = os.ReadFile(s.path(full))
+	}
+	if err != nil {
+		return nil, err
+	}
+	var session Session
+	if err := json.Unmarshal(data, &session); err != nil {
+		return nil, fmt.Errorf("failed to read session %s: %w", id, err)
+	}
+	return &session, nil
+}
+
+func (s *Store) lookupPrefix(prefix string) (string, error) {
+	ids, err := s.IDs()
+	if err != nil {
+		return "", err
+	}
+	match := ""
+	for _, id := range ids {
+		if strings.HasPrefix(id, prefix) {
+			if match != "" {
+				return "", fmt.Errorf("session ID %q is ambiguous", prefix)
+			}
+			match = id
+		}
+	}
+	if match == "" {
+		return "", ErrNotFound
+	}
+	return match, nil
+}
+
+// IDs lists the stored session IDs, newest first.
+func (s *Store) IDs() ([]string, error) {
+	entries, err := os.ReadDir(s.Dir)
+	if err != nil {
+		return nil, err
+	}
+	ids := []string{}
+	for _, entry := range entries {
+		name := entry.Name()
+		if entry.IsDir() || strings.HasPrefix(name, ".") || filepath.Ext(name) != ".json" {
+			continue
+		}
+		ids = append(ids, strings.TrimSuffix(name, ".json"))
+	}
+	sort.Sort(sort.Reverse(sort.StringSlice(ids)))
+	return ids, nil
+}
+
+// List loads every stored session, newest first. Sessions that fail to load are
+// skipped and reported in errs.
+func (s *Store) List() (list []*Session, errs []error) {
+	ids, err := s.IDs()
+	if err != nil {
+		return nil, []error{err}
+	}
+	for _, id := range ids {
+		session, err := s.Load(id)
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		list = append(list, session)
+	}
+	return list, errs
+}
@@ -0,0 +1,256 @@
+package sessions
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"slices"
+	"sort"
+
+	"github.com/falbanese9484/terminal-chat/types"
+)
+
+// A conversation is a tree. Each message points at the one it follows through ParentID,
+// so an edited prompt or a regenerated response becomes a sibling of the message it
+// replaces rather than overwriting it. Nodes holds every message; Active records which
+// child was last picked under each parent ("" for the root), and Messages is the path
+// those choices make from the root.
+
+// NewMessageID returns a random ID for a message.
+func NewMessageID() string {
+	id := make([]byte, 6)
+	rand.Read(id)
+	return hex.EncodeToString(id)
+}
+
+// Append adds msg to the end of the active branch.
+func (s *Session) Append(msg types.Message) types.Message {
+	if msg.ID == "" {
+		msg.ID = NewMessageID()
+	}
+	msg.ParentID = ""
+	if n := len(s.Messages); n > 0 {
+		msg.ParentID = s.Messages[n-1].ID
+	}
+	s.Nodes = append(s.Nodes, msg)
+	s.Messages = append(s.Messages, msg)
+	s.setActive(msg.ParentID, msg.ID)
+	return msg
+}
+
+func (s *Session) setActive(parentID, id string) {
+	if s.Active == nil {
+		s.Active = map[string]string{}
+	}
+	s.Active[parentID] = id
+}
+
+// Index returns the position of the message on the active branch, or -1.
+func (s *Session) Index(id string) int {
+	if id == "" {
+		return -1
+	}
+	return slices.IndexFunc(s.Messages, func(m types.Message) bool { return m.ID == id })
+}
+
+func (s *Session) node(id string) (types.Message, bool) {
+	i := slices.IndexFunc(s.Nodes, func(m types.Message) bool { return m.ID == id })
+	if i < 0 {
+		return types.Message{}, false
+	}
+	return s.Nodes[i], true
+}
+
+// Children returns the messages that follow parentID, oldest first.
+func (s *Session) Children(parentID string) []types.Message {
+	children := []types.Message{}
+	for _, m := range s.Nodes {
+		if m.ParentID == parentID {
+			children = append(children, m)
+		}
+	}
+	return children
+}
+
+// Branch returns which of its siblings the message is, counting from 1, and how many
+// there are.
+func (s *Session) Branch(id string) (int, int) {
+	msg, ok := s.node(id)
+	if !ok {
+		return 0, 0
+	}
+	siblings := s.Children(msg.ParentID)
+	i := slices.IndexFunc(siblings, func(m types.Message) bool { return m.ID == id })
+	return i + 1, len(siblings)
+}
+
+// SwitchBranch replaces the message on the active branch with the sibling delta places
+// away, wrapping around, and follows that sibling's own active choices to a leaf. It
+// returns the sibling now in place.
+func (s *Session) SwitchBranch(id string, delta int) (types.Message, bool) {
+	i := s.Index(id)
+	if i < 0 {
+		return types.Message{}, false
+	}
+	siblings := s.Children(s.Messages[i].ParentID)
+	if len(siblings) < 2 {
+		return types.Message{}, false
+	}
+	at := slices.IndexFunc(siblings, func(m types.Message) bool { return m.ID == id })
+	next := siblings[((at+delta)%len(siblings)+len(siblings))%len(siblings)]
+	s.setActive(next.ParentID, next.ID)
+	s.Messages = s.follow(append(s.Messages[:i:i], next))
+	return next, true
+}
+
+// SetTree replaces the conversation with nodes, making the branch that ends at leafID
+// the active one. An empty leafID picks the newest branch.
+func (s *Session) SetTree(nodes []types.Message, leafID string) {
+	s.Nodes = nodes
+	s.Active = map[string]string{}
+	path := []types.Message{}
+	for id := leafID; id != ""; {
+		msg, ok := s.node(id)
+		if !ok {
+			break
+		}
+		path = append(path, msg)
+		id = msg.ParentID
+	}
+	slices.Reverse(path)
+	for _, m := range path {
+		s.setActive(m.ParentID, m.ID)
+	}
+	s.Messages = s.follow(path)
+}
+
+// follow extends path along the active children until it reaches a leaf. A parent
+// without a recorded choice continues with its newest child.
+func (s *Session) follow(path []types.Message) []types.Message {
+	for {
+		parentID := ""
+		if len(path) > 0 {
+			parentID = path[len(path)-1].ID
+		}
+		children := s.Children(parentID)
+		if len(children) == 0 {
+			return path
+		}
+		next := children[len(children)-1]
+		if id, ok := s.Active[parentID]; ok {
+			if i := slices.IndexFunc(children, func(m types.Message) bool { return m.ID == id }); i >= 0 {
+				next = children[i]
+			}
+		}
+		path = append(path, next)
+	}
+}
+
+// TruncateFrom cuts the active branch just before the message. The message stays in
+// the tree, so whatever is appended next becomes its sibling.
+func (s *Session) TruncateFrom(id string) bool {
+	i := s.Index(id)
+	if i < 0 {
+		return false
+	}
+	s.Messages = s.Messages[:i]
+	return true
+}
+
+// Remove deletes a message from the tree. Its replies move up to its parent, so the
+// rest of the conversation is kept, and a deleted reply gives way to one of its siblings.
+func (s *Session) Remove(id string) bool {
+	msg, ok := s.node(id)
+	if !ok {
+		return false
+	}
+	i := s.Index(id)
+	s.Nodes = slices.DeleteFunc(s.Nodes, func(m types.Message) bool { return m.ID == id })
+	for j := range s.Nodes {
+		if s.Nodes[j].ParentID == id {
+			s.Nodes[j].ParentID = msg.ParentID
+		}
+	}
+	if s.Active[msg.ParentID] == id {
+		if child, ok := s.Active[id]; ok {
+			s.setActive(msg.ParentID, child)
+		} else {
+			delete(s.Active, msg.ParentID)
+		}
+	}
+	delete(s.Active, id)
+	if i >= 0 {
+		if i+1 < len(s.Messages) {
+			s.Messages[i+1].ParentID = msg.ParentID
+		}
+		s.Messages = s.follow(slices.Delete(s.Messages, i, i+1))
+	}
+	return true
+}
+
+// SetAttachments replaces the attachments of a message, on the active branch and in
+// the tree alike, so they survive switching branches.
+func (s *Session) SetAttachments(id string, atts []*types.Attachment) bool {
+	j := slices.IndexFunc(s.Nodes, func(m types.Message) bool { return m.ID == id })
+	if j < 0 {
+		return false
+	}
+	s.Nodes[j].Attachments = atts
+	if i := s.Index(id); i >= 0 {
+		s.Messages[i].Attachments = atts
+	}
+	return true
+}
+
+// Only the branches off the active path are stored next to messages, so a session
+// without any alternatives looks the same on disk as it always did.
+type sessionJSON Session
+
+func (s *Session) MarshalJSON() ([]byte, error) {
+	branches := []types.Message{}
+	for _, m := range s.Nodes {
+		if s.Index(m.ID) < 0 {
+			branches = append(branches, m)
+		}
+	}
+	return json.Marshal(struct {
+		*sessionJSON
+		Branches []types.Message `json:"branches,omitempty"`
+	}{(*sessionJSON)(s), branches})
+}
+
+func (s *Session) UnmarshalJSON(data []byte) error {
+	aux := struct {
+		*sessionJSON
+		Branches []types.Message `json:"branches"`
+	}{sessionJSON: (*sessionJSON)(s)}
+	if err := json.Unmarshal(data, &aux); err != nil {
+		return err
+	}
+	s.link(aux.Branches)
+	return nil
+}
+
+// link rebuilds the tree from the stored active path and branches. Sessions saved
+// before branching have no IDs or parents, so the path is chained up here.
+func (s *Session) link(branches []types.Message) {
+	if s.Messages == nil {
+		s.Messages = []types.Message{}
+	}
+	if s.Active == nil {
+		s.Active = map[string]string{}
+	}
+	for i := range s.Messages {
+		if s.Messages[i].ID == "" {
+			s.Messages[i].ID = NewMessageID()
+		}
+		if i > 0 && s.Messages[i].ParentID == "" {
+			s.Messages[i].ParentID = s.Messages[i-1].ID
+		}
+		s.Active[s.Messages[i].ParentID] = s.Messages[i].ID
+	}
+	s.Nodes = append(slices.Clone(s.Messages), branches...)
+	sort.SliceStable(s.Nodes, func(i, j int) bool {
+		return s.Nodes[i].CreatedAt.Before(s.Nodes[j].CreatedAt)
+	})
+}
@@ -0,0 +1,252 @@
+package sessions
+
+import (
+	"encoding/json"
+	"fmt"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/falbanese9484/terminal-chat/types"
+)
+
+var epoch = time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+
+// say appends a message whose ID is also its content, a second after the last one.
+func say(s *Session, role, id string) {
+	s.Append(types.Message{ID: id, Role: role, Content: id, CreatedAt: epoch.Add(time.Duration(len(s.Nodes)) * time.Second)})
+}
+
+// path is the active branch as its IDs joined with spaces.
+func path(s *Session) string {
+	ids := []string{}
+	for _, m := range s.Messages {
+		ids = append(ids, m.ID)
+	}
+	return strings.Join(ids, " ")
+}
+
+// conversation is u1 a1 u2 a2, one message after another.
+func conversation() *Session {
+	s := NewSession("llama3.2:latest")
+	say(s, types.RoleUser, "u1")
+	say(s, types.RoleAssistant, "a1")
+	say(s, types.RoleUser, "u2")
+	say(s, types.RoleAssistant, "a2")
+	return s
+}
+
+func TestTree(t *testing.T) {
+	tests := []struct {
+		name string
+		edit func(s *Session)
+		path string
+		// branches maps a message to its position among its siblings, "n/total".
+		branches map[string]string
+	}{
+		{
+			name:     "append",
+			edit:     func(s *Session) {},
+			path:     "u1 a1 u2 a2",
+			branches: map[string]string{"u1": "1/1", "a2": "1/1"},
+		},
+		{
+			name: "edit a prompt",
+			edit: func(s *Session) {
+				s.TruncateFrom("u2")
+				say(s, types.RoleUser, "u2b")
+				say(s, types.RoleAssistant, "a2b")
+			},
+			path:     "u1 a1 u2b a2b",
+			branches: map[string]string{"u2": "1/2", "u2b": "2/2", "a2b": "1/1"},
+		},
+		{
+			name: "regenerate",
+			edit: func(s *Session) {
+				s.TruncateFrom("a2")
+				say(s, types.RoleAssistant, "a2b")
+			},
+			path:     "u1 a1 u2 a2b",
+			branches: map[string]string{"a2": "1/2", "a2b": "2/2"},
+		},
+		{
+			name: "switch back to the first version",
+			edit: func(s *Session) {
+				s.TruncateFrom("u2")
+				say(s, types.RoleUser, "u2b")
+				say(s, types.RoleAssistant, "a2b")
+				s.SwitchBranch("u2b", -1)
+			},
+			path: "u1 a1 u2 a2",
+		},
+		{
+			name: "switching wraps around and keeps each branch's choices",
+			edit: func(s *Session) {
+				s.TruncateFrom("a2")
+				say(s, types.RoleAssistant, "a2b")
+				s.TruncateFrom("u2")
+				say(s, types.RoleUser, "u2b")
+				s.SwitchBranch("u2b", 1)
+			},
+			path:     "u1 a1 u2 a2b",
+			branches: map[string]string{"u2b": "2/2", "a2b": "2/2"},
+		},
+		{
+			name: "switching a message without siblings does nothing",
+			edit: func(s *Session) { s.SwitchBranch("a1", 1) },
+			path: "u1 a1 u2 a2",
+		},
+		{
+			name:     "delete a message with replies",
+			edit:     func(s *Session) { s.Remove("a1") },
+			path:     "u1 u2 a2",
+			branches: map[string]string{"u2": "1/1"},
+		},
+		{
+			name: "delete the active version of a branch",
+			edit: func(s *Session) {
+				s.TruncateFrom("a2")
+				say(s, types.RoleAssistant, "a2b")
+				s.Remove("a2b")
+			},
+			path:     "u1 a1 u2 a2",
+			branches: map[string]string{"a2": "1/1"},
+		},
+		{
+			name: "delete the first message",
+			edit: func(s *Session) { s.Remove("u1") },
+			path: "a1 u2 a2",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := conversation()
+			tt.edit(s)
+			if got := path(s); got != tt.path {
+				t.Errorf("active branch = %q, want %q", got, tt.path)
+			}
+			for id, want := range tt.branches {
+				n, total := s.Branch(id)
+				if got := fmt.Sprintf("%d/%d", n, total); got != want {
+					t.Errorf("Branch(%s) = %s, want %s", id, got, want)
+				}
+			}
+			checkLinks(t, s)
+		})
+	}
+}
+
+// checkLinks checks that the active branch is a chain of parents through Nodes, with
+// the same messages as in Nodes.
+func checkLinks(t *testing.T, s *Session) {
+	t.Helper()
+	parent := ""
+	for _, m := range s.Messages {
+		if m.ParentID != parent {
+			t.Errorf("%s follows %q, want %q", m.ID, m.ParentID, parent)
+		}
+		node, ok := s.node(m.ID)
+		if !ok {
+			t.Errorf("%s is on the active branch but not in the tree", m.ID)
+		} else if node.ParentID != m.ParentID || node.Content != m.Content || len(node.Attachments) != len(m.Attachments) {
+			t.Errorf("%s differs between the active branch and the tree", m.ID)
+		}
+		parent = m.ID
+	}
+	if n := len(s.Children(parent)); n != 0 {
+		t.Errorf("the active branch stops at %q, which has %d replies", parent, n)
+	}
+}
+
+func TestSetAttachmentsSurvivesSwitching(t *testing.T) {
+	s := conversation()
+	excerpt := &types.Attachment{Kind: types.AttachmentFile, Name: "docs/a.md:1-4", Content: "text"}
+	if !s.SetAttachments("u2", []*types.Attachment{excerpt}) {
+		t.Fatal("SetAttachments(u2) = false")
+	}
+	s.TruncateFrom("a2")
+	say(s, types.RoleAssistant, "a2b")
+	s.SwitchBranch("a2b", 1)
+	s.SwitchBranch("a2", 1)
+	if i := s.Index("u2"); i < 0 || len(s.Messages[i].Attachments) != 1 {
+		t.Errorf("u2 lost its attachments after switching branches: %+v", s.Messages)
+	}
+	s.TruncateFrom("u2")
+	s.Messages = s.follow(s.Messages)
+	if i := s.Index("u2"); i < 0 || len(s.Messages[i].Attachments) != 1 {
+		t.Errorf("u2 lost its attachments when the branch was rebuilt")
+	}
+	if s.SetAttachments("missing", nil) {
+		t.Error("SetAttachments of a missing message = true")
+	}
+}
+
+func TestJSONRoundTrip(t *testing.T) {
+	s := conversation()
+	s.TruncateFrom("u2")
+	say(s, types.RoleUser, "u2b")
+	say(s, types.RoleAssistant, "a2b")
+	s.TruncateFrom("a2b")
+	say(s, types.RoleAssistant, "a2c")
+
+	data, err := json.Marshal(s)
+	if err != nil {
+		t.Fatal(err)
+	}
+	loaded := &Session{}
+	if err := json.Unmarshal(data, loaded); err != nil {
+		t.Fatal(err)
+	}
+	if got, want := path(loaded), path(s); got != want {
+		t.Errorf("active branch = %q, want %q", got, want)
+	}
+	ids := func(s *Session) []string {
+		out := []string{}
+		for _, m := range s.Nodes {
+			out = append(out, m.ID)
+		}
+		return out
+	}
+	if got, want := ids(loaded), ids(s); !slices.Equal(got, want) {
+		t.Errorf("nodes = %v, want %v", got, want)
+	}
+	for _, id := range []string{"u2", "u2b", "a2b", "a2c"} {
+		n1, t1 := s.Branch(id)
+		n2, t2 := loaded.Branch(id)
+		if n1 != n2 || t1 != t2 {
+			t.Errorf("Branch(%s) = %d/%d after loading, want %d/%d", id, n2, t2, n1, t1)
+		}
+	}
+	loaded.SwitchBranch("u2b", 1)
+	if got := path(loaded); got != "u1 a1 u2 a2" {
+		t.Errorf("switching the loaded session = %q", got)
+	}
+	checkLinks(t, loaded)
+
+	// A session without branches is stored the way it was before there were any.
+	plain, _ := json.Marshal(conversation())
+	if strings.Contains(string(plain), "branches") {
+		t.Errorf("a session without branches stored them: %s", plain)
+	}
+}
+
+func TestLinkLegacySession(t *testing.T) {
+	data := `{"id":"old","messages":[{"role":"user","content":"hi"},{"role":"assistant","content":"hello"}]}`
+	s := &Session{}
+	if err := json.Unmarshal([]byte(data), s); err != nil {
+		t.Fatal(err)
+	}
+	if len(s.Messages) != 2 || len(s.Nodes) != 2 {
+		t.Fatalf("loaded %d messages and %d nodes", len(s.Messages), len(s.Nodes))
+	}
+	if s.Messages[0].ID == "" || s.Messages[1].ParentID != s.Messages[0].ID {
+		t.Errorf("legacy messages weren't chained: %+v", s.Messages)
+	}
+	checkLinks(t, s)
+	s.TruncateFrom(s.Messages[1].ID)
+	say(s, types.RoleAssistant, "again")
+	if n, total := s.Branch("again"); n != 2 || total != 2 {
+		t.Errorf("regenerating a legacy response = %d/%d", n, total)
+	}
+}
@@ -0,0 +1,179 @@
+package templates
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/falbanese9484/terminal-chat/attachments"
+	"github.com/falbanese9484/terminal-chat/clipboard"
+	"github.com/falbanese9484/terminal-chat/config"
+	"github.com/falbanese9484/terminal-chat/types"
+)
+
+// Placeholders look like {{name}}, or {{name:source}} to fill the value in without
+// asking: {{diff:sh:git diff --staged}}, {{notes:file:~/notes.md}}, {{code:clipboard}}.
+var placeholder = regexp.MustCompile(`\{\{\s*([A-Za-z_][\w-]*)\s*(?::((?:[^}]|\}[^}])*))?\}\}`)
+
+const (
+	SourceAsk       = ""
+	SourceClipboard = "clipboard"
+	SourceFile      = "file"
+	SourceShell     = "sh"
+)
+
+type Template struct {
+	// A prompt kept as a file. Name is the file name without its extension.
+	Name string
+	Body string
+	Path string
+}
+
+type Variable struct {
+	// Source says where the value comes from, with Arg naming the file or command.
+	Name   string
+	Source string
+	Arg    string
+}
+
+// Description is the first line of the template, to show in the picker.
+func (t *Template) Description() string {
+	line, _, _ := strings.Cut(strings.TrimSpace(t.Body), "\n")
+	return types.Truncate(line, 60)
+}
+
+// Variables lists the placeholders in the order they first appear. A name used more
+// than once is one variable, described by its first use.
+func (t *Template) Variables() []Variable {
+	vars := []Variable{}
+	seen := map[string]bool{}
+	for _, match := range placeholder.FindAllStringSubmatch(t.Body, -1) {
+		if seen[match[1]] {
+			continue
+		}
+		seen[match[1]] = true
+		vars = append(vars, parseVariable(match[1], strings.TrimSpace(match[2])))
+	}
+	return vars
+}
+
+func parseVariable(name, spec string) Variable {
+	source, arg, _ := strings.Cut(spec, ":")
+	switch source = strings.TrimSpace(source); source {
+	case SourceClipboard, SourceFile, SourceShell:
+		return Variable{Name: name, Source: source, Arg: strings.TrimSpace(arg)}
+	}
+	return Variable{Name: name}
+}
+
+// Render fills in the placeholders. Ones without a value are left as they are.
+func (t *Template) Render(values map[string]string) string {
+	return placeholder.ReplaceAllStringFunc(t.Body, func(match string) string {
+		name := placeholder.FindStringSubmatch(match)[1]
+		if value, ok := values[name]; ok {
+			return value
+		}
+		return match
+	})
+}
+
+// AnswerSource reports the source an answer names, if it names one. Typed text is taken
+// as it is unless it names a source the way a placeholder does: sh:command runs a
+// command, file:path reads a file and clipboard pastes.
+func AnswerSource(name, input string) (Variable, bool) {
+	v := parseVariable(name, strings.TrimSpace(input))
+	if v.Source == SourceAsk || (v.Source != SourceClipboard && v.Arg == "") {
+		return Variable{}, false
+	}
+	return v, true
+}
+
+// Resolve fetches a variable's value from its source.
+func Resolve(ctx context.Context, v Variable) (string, error) {
+	switch v.Source {
+	case SourceClipboard:
+		text, err := clipboard.Paste()
+		if err != nil {
+			return "", fmt.Errorf("failed to read the clipboard: %w", err)
+		}
+		return text, nil
+	case SourceFile:
+		data, err := os.ReadFile(attachments.ExpandHome(v.Arg))
+		if err != nil {
+			return "", fmt.Errorf("failed to read %s: %w", v.Arg, err)
+		}
+		return strings.TrimRight(string(data), "\n"), nil
+	case SourceShell:
+		att := attachments.RunCommand(ctx, v.Arg, attachments.CommandTimeout)
+		if att.ExitCode != 0 {
+			return "", fmt.Errorf("%s exited with %d: %s", v.Arg, att.ExitCode, strings.TrimSpace(att.Content))
+		}
+		return strings.TrimRight(att.Content, "\n"), nil
+	}
+	return "", fmt.Errorf("%s has to be asked for", v.Name)
+}
+
+type Library struct {
+	// Templates are kept one per file in Dir, as .md or .txt.
+	Dir string
+}
+
+// NewDefaultLibrary opens the templates directory under the config directory.
+func NewDefaultLibrary() (*Library, error) {
+	dir, err := config.Dir()
+	if err != nil {
+		return nil, err
+	}
+	dir = filepath.Join(dir, "templates")
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, fmt.Errorf("failed to make templates directory: %w", err)
+	}
+	return &Library{Dir: dir}, nil
+}
+
+// List loads every template, sorted by name.
+func (l *Library) List() ([]*Template, error) {
+	entries, err := os.ReadDir(l.Dir)
+	if err != nil {
+		return nil, err
+	}
+	list := []*Template{}
+	for _, entry := range entries {
+		ext := filepath.Ext(entry.Name())
+		if entry.IsDir() || strings.HasPrefix(entry.Name(), ".") || (ext != ".md" && ext != ".txt") {
+			continue
+		}
+		t, err := l.load(filepath.Join(l.Dir, entry.Name()))
+		if err != nil {
+			return nil, err
+		}
+		list = append(list, t)
+	}
+	sort.Slice(list, func(i, j int) bool { return list[i].Name < list[j].Name })
+	return list, nil
+}
+
+// Get loads the template with the given name.
+func (l *Library) Get(name string) (*Template, error) {
+	for _, ext := range []string{".md", ".txt"} {
+		t, err := l.load(filepath.Join(l.Dir, filepath.Base(name)+ext))
+		if err == nil || !errors.Is(err, os.ErrNotExist) {
+			return t, err
+		}
+	}
+	return nil, fmt.Errorf("no template named %q in %s", name, l.Dir)
+}
+
+func (l *Library) load(path string) (*Template, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	name := strings.TrimSuffix(filepath.Base(path), filepath.Ext(path))
+	return &Template{Name: name, Body: string(data), Path: path}, nil
+}
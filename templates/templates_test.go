@@ -0,0 +1,115 @@
+package templates
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestVariables(t *testing.T) {
+	tests := []struct {
+		body string
+		want []Variable
+	}{
+		{"no placeholders", []Variable{}},
+		{"{{name}}", []Variable{{Name: "name"}}},
+		{"{{ name }} and {{name}} again", []Variable{{Name: "name"}}},
+		{"{{diff:sh:git diff --staged}}", []Variable{{Name: "diff", Source: SourceShell, Arg: "git diff --staged"}}},
+		{"{{notes: file: ~/notes.md }}", []Variable{{Name: "notes", Source: SourceFile, Arg: "~/notes.md"}}},
+		{"{{code:clipboard}}", []Variable{{Name: "code", Source: SourceClipboard}}},
+		{"{{cmd:sh:awk '{print $1}'}}", []Variable{{Name: "cmd", Source: SourceShell, Arg: "awk '{print $1}'"}}},
+		// An unknown source is asked for like a plain placeholder.
+		{"{{lang:go}}", []Variable{{Name: "lang"}}},
+		{"{{a}} {{b-2}} {{_c}}", []Variable{{Name: "a"}, {Name: "b-2"}, {Name: "_c"}}},
+		{"{{1bad}} {{}} {{a b}} { {x} }", []Variable{}},
+	}
+	for _, tt := range tests {
+		got := (&Template{Body: tt.body}).Variables()
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("Variables(%q) = %+v, want %+v", tt.body, got, tt.want)
+		}
+	}
+}
+
+func TestRender(t *testing.T) {
+	tmpl := &Template{Body: "Review {{lang}} code:\n{{code:clipboard}}\n{{ lang }} {{missing}}"}
+	got := tmpl.Render(map[string]string{"lang": "Go", "code": "x := 1"})
+	want := "Review Go code:\nx := 1\nGo {{missing}}"
+	if got != want {
+		t.Errorf("Render = %q, want %q", got, want)
+	}
+}
+
+func TestAnswerSource(t *testing.T) {
+	tests := []struct {
+		input string
+		want  Variable
+		ok    bool
+	}{
+		{"plain text", Variable{}, false},
+		{"!rm -rf ~", Variable{}, false},
+		{"@/etc/passwd", Variable{}, false},
+		{"sh:", Variable{}, false},
+		{"sh: git log -1", Variable{Name: "v", Source: SourceShell, Arg: "git log -1"}, true},
+		{"file:~/notes.md", Variable{Name: "v", Source: SourceFile, Arg: "~/notes.md"}, true},
+		{" clipboard ", Variable{Name: "v", Source: SourceClipboard}, true},
+	}
+	for _, tt := range tests {
+		got, ok := AnswerSource("v", tt.input)
+		if ok != tt.ok || got != tt.want {
+			t.Errorf("AnswerSource(%q) = %+v, %v, want %+v, %v", tt.input, got, ok, tt.want, tt.ok)
+		}
+	}
+}
+
+func TestResolveFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "notes.md")
+	if err := os.WriteFile(path, []byte("remember this\n\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	got, err := Resolve(context.Background(), Variable{Name: "notes", Source: SourceFile, Arg: path})
+	if err != nil || got != "remember this" {
+		t.Errorf("Resolve = %q, %v", got, err)
+	}
+	if _, err := Resolve(context.Background(), Variable{Name: "lang"}); err == nil {
+		t.Error("Resolve of an asked variable should fail")
+	}
+}
+
+func TestLibrary(t *testing.T) {
+	dir := t.TempDir()
+	for name, body := range map[string]string{
+		"review.md":  "Review this\n{{code}}",
+		"commit.txt": "Write a commit message",
+		"skip.json":  "{}",
+		".hidden.md": "hidden",
+	} {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(body), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	library := &Library{Dir: dir}
+	list, err := library.List()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(list) != 2 || list[0].Name != "commit" || list[1].Name != "review" {
+		t.Fatalf("List = %+v", list)
+	}
+	if got := list[1].Description(); got != "Review this" {
+		t.Errorf("Description = %q", got)
+	}
+	long := &Template{Body: strings.Repeat("é", 70) + "\nsecond line"}
+	if got := long.Description(); got != strings.Repeat("é", 57)+"..." {
+		t.Errorf("Description = %q", got)
+	}
+	if _, err := library.Get("review"); err != nil {
+		t.Error(err)
+	}
+	if _, err := library.Get("missing"); err == nil {
+		t.Error("Get of a missing template should fail")
+	}
+}
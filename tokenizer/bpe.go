@@ -0,0 +1,103 @@
+package tokenizer
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/base64"
+	"fmt"
+	"io"
+	"math"
+	"strconv"
+
+	"github.com/dlclark/regexp2"
+)
+
+// patterns split text into the pieces BPE runs on, as tiktoken does. They need
+// lookahead, which is why regexp2 is used rather than regexp.
+var patterns = map[string]string{
+	"cl100k_base": `(?i:'s|'t|'re|'ve|'m|'ll|'d)|[^\r\n\p{L}\p{N}]?\p{L}+|\p{N}{1,3}| ?[^\s\p{L}\p{N}]+[\r\n]*|\s*[\r\n]+|\s+(?!\S)|\s+`,
+	"o200k_base": `[^\r\n\p{L}\p{N}]?[\p{Lu}\p{Lt}\p{Lm}\p{Lo}\p{M}]*[\p{Ll}\p{Lm}\p{Lo}\p{M}]+(?i:'s|'t|'re|'ve|'m|'ll|'d)?` +
+		`|[^\r\n\p{L}\p{N}]?[\p{Lu}\p{Lt}\p{Lm}\p{Lo}\p{M}]+[\p{Ll}\p{Lm}\p{Lo}\p{M}]*(?i:'s|'t|'re|'ve|'m|'ll|'d)?` +
+		`|\p{N}{1,3}| ?[^\s\p{L}\p{N}]+[\r\n/]*|\s*[\r\n]+|\s+(?!\S)|\s+`,
+}
+
+type BPE struct {
+	// A byte pair encoder. Ranks maps each token to its merge priority; lower merges
+	// first.
+	name    string
+	ranks   map[string]int
+	pattern *regexp2.Regexp
+}
+
+// NewBPE reads a vocabulary in tiktoken's format.
+func NewBPE(name string, vocabulary io.Reader, pattern string) (*BPE, error) {
+	re, err := regexp2.Compile(pattern, regexp2.Unicode)
+	if err != nil {
+		return nil, fmt.Errorf("failed to compile %s pattern: %w", name, err)
+	}
+	ranks := map[string]int{}
+	scanner := bufio.NewScanner(vocabulary)
+	for scanner.Scan() {
+		token, rank, ok := bytes.Cut(scanner.Bytes(), []byte(" "))
+		if !ok {
+			continue
+		}
+		decoded, err := base64.StdEncoding.DecodeString(string(token))
+		if err != nil {
+			return nil, fmt.Errorf("failed to read %s vocabulary: %w", name, err)
+		}
+		n, err := strconv.Atoi(string(rank))
+		if err != nil {
+			return nil, fmt.Errorf("failed to read %s vocabulary: %w", name, err)
+		}
+		ranks[string(decoded)] = n
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read %s vocabulary: %w", name, err)
+	}
+	if len(ranks) == 0 {
+		return nil, fmt.Errorf("the %s vocabulary is empty", name)
+	}
+	return &BPE{name: name, ranks: ranks, pattern: re}, nil
+}
+
+func (b *BPE) Name() string { return b.name }
+func (b *BPE) Exact() bool  { return true }
+
+func (b *BPE) Count(text string) int {
+	count := 0
+	m, err := b.pattern.FindStringMatch(text)
+	for err == nil && m != nil {
+		piece := m.String()
+		if _, ok := b.ranks[piece]; ok {
+			count++
+		} else {
+			count += b.merge([]byte(piece))
+		}
+		m, err = b.pattern.FindNextMatch(m)
+	}
+	return count
+}
+
+// merge runs byte pair merges over a piece and returns how many tokens are left.
+// Pieces are short, so the quadratic search for the best pair is fine.
+func (b *BPE) merge(piece []byte) int {
+	// bounds[i] is where the i-th part starts; the last entry is the end of the piece.
+	bounds := make([]int, len(piece)+1)
+	for i := range bounds {
+		bounds[i] = i
+	}
+	for len(bounds) > 2 {
+		best, at := math.MaxInt, -1
+		for i := 0; i+2 < len(bounds); i++ {
+			if rank, ok := b.ranks[string(piece[bounds[i]:bounds[i+2]])]; ok && rank < best {
+				best, at = rank, i
+			}
+		}
+		if at < 0 {
+			break
+		}
+		bounds = append(bounds[:at+1], bounds[at+2:]...)
+	}
+	return len(bounds) - 1
+}
@@ -0,0 +1,108 @@
+package tokenizer
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+)
+
+type vocabulary struct {
+	// Where tiktoken downloads an encoding from, and the SHA-256 it expects.
+	url    string
+	sha256 string
+}
+
+var vocabularies = map[string]vocabulary{
+	"cl100k_base": {
+		url:    "https://openaipublic.blob.core.windows.net/encodings/cl100k_base.tiktoken",
+		sha256: "223921b76ee99bde995b7ff738513eef100fb51d18c93597a113bcffe865b2a7",
+	},
+	"o200k_base": {
+		url:    "https://openaipublic.blob.core.windows.net/encodings/o200k_base.tiktoken",
+		sha256: "446a9538cb6c348e3516120d7c08b09f57c36495e2acfffe59a5bf8b0cfb1a2d",
+	},
+}
+
+// Encodings lists the encodings that can be fetched.
+func Encodings() []string {
+	names := make([]string, 0, len(vocabularies))
+	for name := range vocabularies {
+		names = append(names, name)
+	}
+	slices.Sort(names)
+	return names
+}
+
+// Fetch downloads the vocabulary of an encoding to VocabularyPath, checking it against
+// the hash tiktoken expects. The file is only replaced once the download is complete.
+func Fetch(ctx context.Context, name string) (string, error) {
+	vocab, ok := vocabularies[name]
+	if !ok {
+		return "", fmt.Errorf("unknown encoding %s, expected one of %s", name, strings.Join(Encodings(), ", "))
+	}
+	path, err := VocabularyPath(name)
+	if err != nil {
+		return "", err
+	}
+	req, err := http.NewRequestWithContext(ctx, "GET", vocab.url, nil)
+	if err != nil {
+		return "", err
+	}
+	res, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return "", fmt.Errorf("failed to download %s: %w", name, err)
+	}
+	defer res.Body.Close()
+	if res.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("failed to download %s: status %d", name, res.StatusCode)
+	}
+	return path, install(path, res.Body, vocab.sha256)
+}
+
+// Install copies a vocabulary that was downloaded some other way into place, for
+// machines that can't reach the download itself. It is checked the same way as Fetch.
+func Install(name, from string) (string, error) {
+	vocab, ok := vocabularies[name]
+	if !ok {
+		return "", fmt.Errorf("unknown encoding %s, expected one of %s", name, strings.Join(Encodings(), ", "))
+	}
+	path, err := VocabularyPath(name)
+	if err != nil {
+		return "", err
+	}
+	f, err := os.Open(from)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+	return path, install(path, f, vocab.sha256)
+}
+
+func install(path string, r io.Reader, want string) error {
+	tmp, err := os.CreateTemp(filepath.Dir(path), ".tiktoken-*")
+	if err != nil {
+		return fmt.Errorf("failed to save vocabulary: %w", err)
+	}
+	hash := sha256.New()
+	if _, err := io.Copy(io.MultiWriter(tmp, hash), r); err != nil {
+		tmp.Close()
+		os.Remove(tmp.Name())
+		return fmt.Errorf("failed to save vocabulary: %w", err)
+	}
+	if err := tmp.Close(); err != nil {
+		os.Remove(tmp.Name())
+		return fmt.Errorf("failed to save vocabulary: %w", err)
+	}
+	if got := hex.EncodeToString(hash.Sum(nil)); got != want {
+		os.Remove(tmp.Name())
+		return fmt.Errorf("vocabulary has SHA-256 %s, expected %s", got, want)
+	}
+	return os.Rename(tmp.Name(), path)
+}
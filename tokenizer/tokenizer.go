@@ -0,0 +1,186 @@
+package tokenizer
+
+import (
+	"errors"
+	"os"
+	"strings"
+	"sync"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/falbanese9484/terminal-chat/config"
+)
+
+type Tokenizer interface {
+	// Count returns how many tokens text takes. Exact reports whether that is the
+	// model's own count or an estimate.
+	Count(text string) int
+	Name() string
+	Exact() bool
+}
+
+var (
+	mu        sync.Mutex
+	encodings = map[string]Tokenizer{}
+)
+
+// For returns the tokenizer for a model. OpenAI models use their BPE encoding when its
+// vocabulary is cached under the config directory; everything else, and OpenAI models
+// without a cached vocabulary, gets the heuristic.
+//
+// Reading a vocabulary takes a moment, so it happens in the background the first time
+// an encoding is asked for, and the heuristic stands in until it is done.
+func For(model string) Tokenizer {
+	name := encodingFor(model)
+	if name == "" {
+		return Heuristic{}
+	}
+	mu.Lock()
+	defer mu.Unlock()
+	if tok, ok := encodings[name]; ok {
+		return tok
+	}
+	encodings[name] = Heuristic{}
+	go func() {
+		bpe, err := loadEncoding(name)
+		if err != nil {
+			return
+		}
+		mu.Lock()
+		encodings[name] = bpe
+		mu.Unlock()
+	}()
+	return Heuristic{}
+}
+
+// encodingFor maps a model name, with or without a vendor prefix such as "openai/",
+// onto its tiktoken encoding.
+func encodingFor(model string) string {
+	if i := strings.LastIndex(model, "/"); i >= 0 {
+		if !strings.HasPrefix(model, "openai/") {
+			return ""
+		}
+		model = model[i+1:]
+	}
+	switch {
+	case strings.HasPrefix(model, "gpt-4o"), strings.HasPrefix(model, "gpt-4.1"),
+		strings.HasPrefix(model, "gpt-5"), strings.HasPrefix(model, "o1"),
+		strings.HasPrefix(model, "o3"), strings.HasPrefix(model, "o4"):
+		return "o200k_base"
+	case strings.HasPrefix(model, "gpt-4"), strings.HasPrefix(model, "gpt-3.5"),
+		strings.HasPrefix(model, "text-embedding-"):
+		return "cl100k_base"
+	}
+	return ""
+}
+
+// VocabularyPath is where the vocabulary of an encoding is looked for, in the format
+// tiktoken publishes them: a base64 token and its rank on each line.
+func VocabularyPath(name string) (string, error) {
+	return config.Path("tokenizers", name+".tiktoken")
+}
+
+func loadEncoding(name string) (*BPE, error) {
+	pattern, ok := patterns[name]
+	if !ok {
+		return nil, errors.New("unknown encoding " + name)
+	}
+	path, err := VocabularyPath(name)
+	if err != nil {
+		return nil, err
+	}
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	return NewBPE(name, f, pattern)
+}
+
+// Heuristic estimates without a vocabulary. It splits text the way cl100k_base does
+// before merging and prices each piece: a word takes a token per five letters or so,
+// a number one per three digits, a run of punctuation one per two marks, and letters
+// outside ASCII about one each. Models with their own vocabularies, such as the ones
+// Ollama runs, split differently, so for them it is only a guide; tokenizer_test.go
+// keeps it within bounds of the exact counts for cl100k_base.
+type Heuristic struct{}
+
+func (Heuristic) Name() string { return "estimate" }
+func (Heuristic) Exact() bool  { return false }
+
+func (Heuristic) Count(text string) int {
+	count := 0
+	for text != "" {
+		r, size := utf8.DecodeRuneInString(text)
+		switch {
+		case unicode.IsSpace(r):
+			run := text[:len(text)-len(strings.TrimLeftFunc(text, unicode.IsSpace))]
+			text = text[len(run):]
+			if i := strings.LastIndexAny(run, "\r\n"); i >= 0 {
+				count++
+				run = run[i+1:]
+			}
+			// The last space goes with the word or punctuation after it.
+			if next, _ := utf8.DecodeRuneInString(text); run != "" && text != "" && !unicode.IsNumber(next) {
+				_, last := utf8.DecodeLastRuneInString(run)
+				run = run[:len(run)-last]
+			}
+			if run != "" {
+				count++
+			}
+		case unicode.IsNumber(r):
+			digits := 0
+			for text != "" {
+				r, size := utf8.DecodeRuneInString(text)
+				if !unicode.IsNumber(r) {
+					break
+				}
+				digits++
+				text = text[size:]
+			}
+			count += (digits + 2) / 3
+		case unicode.IsLetter(r):
+			var n int
+			n, text = countWord(text)
+			count += n
+		default:
+			// A single mark before a word is part of the word's piece.
+			if next, _ := utf8.DecodeRuneInString(text[size:]); unicode.IsLetter(next) {
+				var n int
+				n, text = countWord(text[size:])
+				count += n
+				continue
+			}
+			marks := 0
+			for text != "" {
+				r, size := utf8.DecodeRuneInString(text)
+				if unicode.IsSpace(r) || unicode.IsLetter(r) || unicode.IsNumber(r) {
+					break
+				}
+				marks++
+				text = text[size:]
+			}
+			count += (marks + 1) / 2
+			text = strings.TrimLeft(text, "\r\n")
+		}
+	}
+	return count
+}
+
+// countWord prices the run of letters text starts with and returns what follows it.
+func countWord(text string) (int, string) {
+	ascii, other := 0, 0
+	for text != "" {
+		r, size := utf8.DecodeRuneInString(text)
+		if !unicode.IsLetter(r) {
+			break
+		}
+		if r < utf8.RuneSelf {
+			ascii++
+		} else {
+			other++
+		}
+		text = text[size:]
+	}
+	return max(1, (ascii+3)/5+other), text
+}
@@ -0,0 +1,148 @@
+package tokenizer
+
+import (
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// known are cl100k_base counts published in OpenAI's "How to count tokens with
+// tiktoken" guide.
+var known = []struct {
+	text  string
+	count int
+}{
+	{"hello world", 2},
+	{"Hello, world!", 4},
+	{"tiktoken is great!", 6},
+	{"2 + 2 = 4", 7},
+	{"antidisestablishmentarianism", 6},
+	{"お誕生日おめでとう", 9},
+}
+
+func toyBPE(t *testing.T, tokens ...string) *BPE {
+	t.Helper()
+	var vocab strings.Builder
+	for rank, token := range tokens {
+		fmt.Fprintf(&vocab, "%s %d\n", base64.StdEncoding.EncodeToString([]byte(token)), rank)
+	}
+	bpe, err := NewBPE("toy", strings.NewReader(vocab.String()), patterns["cl100k_base"])
+	if err != nil {
+		t.Fatal(err)
+	}
+	return bpe
+}
+
+func TestMerge(t *testing.T) {
+	bpe := toyBPE(t, "a", "b", "c", "ab", "bc", "abc")
+	tests := []struct {
+		piece string
+		want  int
+	}{
+		{"a", 1},
+		{"ab", 1},
+		{"abc", 1},
+		{"cab", 2},
+		{"abab", 2},
+		{"bcab", 2},
+		{"xyz", 3},
+		{"", 0},
+	}
+	for _, tt := range tests {
+		if got := bpe.merge([]byte(tt.piece)); got != tt.want {
+			t.Errorf("merge(%q) = %d, want %d", tt.piece, got, tt.want)
+		}
+	}
+	if got := bpe.Count("abc abc"); got != 3 {
+		t.Errorf("Count(%q) = %d, want 3", "abc abc", got)
+	}
+}
+
+func TestCl100kCounts(t *testing.T) {
+	bpe, err := loadEncoding("cl100k_base")
+	if errors.Is(err, fs.ErrNotExist) {
+		t.Skip("cl100k_base vocabulary is not cached, fetch it with bash-butler tokenizer fetch")
+	}
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, tt := range known {
+		if got := bpe.Count(tt.text); got != tt.count {
+			t.Errorf("Count(%q) = %d, want %d", tt.text, got, tt.count)
+		}
+	}
+}
+
+func TestHeuristicBounds(t *testing.T) {
+	total, estimated := 0, 0
+	for _, tt := range known {
+		got := Heuristic{}.Count(tt.text)
+		if diff := got - tt.count; diff < -2 || diff > 2 {
+			t.Errorf("Count(%q) = %d, want within 2 of %d", tt.text, got, tt.count)
+		}
+		total += tt.count
+		estimated += got
+	}
+	if diff := estimated - total; diff*10 < -total || diff*10 > total {
+		t.Errorf("estimated %d tokens in all, want within 10%% of %d", estimated, total)
+	}
+}
+
+func TestHeuristicPieces(t *testing.T) {
+	tests := []struct {
+		text string
+		want int
+	}{
+		{"", 0},
+		{"word", 1},
+		{" word", 1},
+		{"1234567", 3},
+		{"a\n\nb", 3},
+		{"    return", 2},
+		{"if err != nil {\n", 5},
+	}
+	for _, tt := range tests {
+		if got := (Heuristic{}).Count(tt.text); got != tt.want {
+			t.Errorf("Count(%q) = %d, want %d", tt.text, got, tt.want)
+		}
+	}
+}
+
+func TestFor(t *testing.T) {
+	for _, model := range []string{"llama3.2:latest", "anthropic/claude-sonnet-4"} {
+		if tok := For(model); tok.Exact() {
+			t.Errorf("For(%q) is exact", model)
+		}
+	}
+	if got := encodingFor("openai/gpt-4o-mini"); got != "o200k_base" {
+		t.Errorf("encodingFor(openai/gpt-4o-mini) = %q", got)
+	}
+	if got := encodingFor("gpt-3.5-turbo"); got != "cl100k_base" {
+		t.Errorf("encodingFor(gpt-3.5-turbo) = %q", got)
+	}
+}
+
+func TestInstallChecksHash(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "toy.tiktoken")
+	const vocab = "YQ== 0\n"
+	sum := sha256.Sum256([]byte(vocab))
+	if err := install(path, strings.NewReader(vocab), "0000"); err == nil {
+		t.Fatal("install accepted a vocabulary with the wrong hash")
+	}
+	if _, err := os.Stat(path); !errors.Is(err, fs.ErrNotExist) {
+		t.Fatalf("a rejected vocabulary was left at %s", path)
+	}
+	if err := install(path, strings.NewReader(vocab), hex.EncodeToString(sum[:])); err != nil {
+		t.Fatal(err)
+	}
+	if data, err := os.ReadFile(path); err != nil || string(data) != vocab {
+		t.Fatalf("installed %q, %v", data, err)
+	}
+}
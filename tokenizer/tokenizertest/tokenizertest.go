@@ -0,0 +1,12 @@
+// Package tokenizertest provides a tokenizer for tests whose counts are easy to work
+// out by hand.
+package tokenizertest
+
+import "strings"
+
+// Words counts one token per whitespace separated word.
+type Words struct{}
+
+func (Words) Count(text string) int { return len(strings.Fields(text)) }
+func (Words) Name() string          { return "words" }
+func (Words) Exact() bool           { return true }
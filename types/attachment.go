@@ -0,0 +1,31 @@
+package types
+
+const (
+	AttachmentFile    = "file"
+	AttachmentCommand = "command"
+	AttachmentImage   = "image"
+	// AttachmentExcerpt is a passage retrieved from an index, with its line range.
+	AttachmentExcerpt = "excerpt"
+)
+
+type Attachment struct {
+	// Content pulled into a prompt from outside the input area, such as an @-mentioned file.
+	Kind      string `json:"kind"`
+	Name      string `json:"name"`
+	Path      string `json:"path,omitempty"`
+	Language  string `json:"language,omitempty"`
+	Content   string `json:"content"`
+	Size      int64  `json:"size"`
+	Truncated bool   `json:"truncated,omitempty"`
+	Command   string `json:"command,omitempty"`
+	ExitCode  int    `json:"exit_code,omitempty"`
+	TimedOut  bool   `json:"timed_out,omitempty"`
+	MimeType  string `json:"mime_type,omitempty"`
+	// Data is the base64 encoded file for attachments that can't be sent as text.
+	Data   string `json:"data,omitempty"`
+	Width  int    `json:"width,omitempty"`
+	Height int    `json:"height,omitempty"`
+	// StartLine and EndLine are the lines of Path an excerpt covers.
+	StartLine int `json:"start_line,omitempty"`
+	EndLine   int `json:"end_line,omitempty"`
+}
@@ -1,20 +1,105 @@
 package types
 
-import "context"
+import (
+	"context"
+	"time"
+)
+
+const (
+	RoleSystem    = "system"
+	RoleUser      = "user"
+	RoleAssistant = "assistant"
+)
+
+type Message struct {
+	// A single turn of the conversation. Content is the text as the user typed it or the
+	// model wrote it; attachments are kept alongside rather than folded into it.
+	// ParentID is the message this one follows, which is how branches hang together.
+	ID          string        `json:"id,omitempty"`
+	ParentID    string        `json:"parent_id,omitempty"`
+	Role        string        `json:"role"`
+	Content     string        `json:"content"`
+	Images      []string      `json:"images,omitempty"`
+	Attachments []*Attachment `json:"attachments,omitempty"`
+	Model       string        `json:"model,omitempty"`
+	CreatedAt   time.Time     `json:"created_at"`
+	Usage       *Usage        `json:"usage,omitempty"`
+}
+
+type Usage struct {
+	// Token counts and cost for one response, as reported by the provider. Cost is in
+	// USD and only set by providers that bill; Duration is the time spent generating.
+	PromptTokens     int           `json:"prompt_tokens"`
+	CompletionTokens int           `json:"completion_tokens"`
+	Cost             *float64      `json:"cost,omitempty"`
+	Duration         time.Duration `json:"duration,omitempty"`
+}
+
+// Add sums two usages, treating a nil usage as zero.
+func (u *Usage) Add(other *Usage) *Usage {
+	sum := &Usage{}
+	for _, x := range []*Usage{u, other} {
+		if x == nil {
+			continue
+		}
+		sum.PromptTokens += x.PromptTokens
+		sum.CompletionTokens += x.CompletionTokens
+		sum.Duration += x.Duration
+		if x.Cost != nil {
+			cost := *x.Cost
+			if sum.Cost != nil {
+				cost += *sum.Cost
+			}
+			sum.Cost = &cost
+		}
+	}
+	return sum
+}
+
+type GenerationOptions struct {
+	// Nil values are left to the provider's defaults. The JSON names are Ollama's, which
+	// takes the struct as is; MaxTokens is its num_predict. ContextLength is the window
+	// the model is run with, Ollama's num_ctx; providers that size it themselves ignore it.
+	Temperature   *float64 `json:"temperature,omitempty"`
+	TopP          *float64 `json:"top_p,omitempty"`
+	MaxTokens     *int     `json:"num_predict,omitempty"`
+	ContextLength *int     `json:"num_ctx,omitempty"`
+}
 
 type ChatRequest struct {
 	// Initial structure for the chat request.
-	Model   string `json:"model"`
-	Prompt  string `json:"prompt"`
-	Stream  bool   `json:"stream"`
-	Context []int  `json:"context,omitempty"`
+	// Messages is the whole conversation, oldest first, with attachments already folded
+	// into the content. Providers don't keep any history of their own.
+	Model    string            `json:"model"`
+	System   string            `json:"system,omitempty"`
+	Messages []Message         `json:"messages"`
+	Options  GenerationOptions `json:"options"`
+	Stream   bool              `json:"stream"`
 }
 
+// The lifecycle of a streamed response, as delivered by the chat bus. Started comes
+// first, then any number of deltas, then exactly one of done, error or cancelled.
+const (
+	EventStarted   = "started"
+	EventDelta     = "delta"
+	EventDone      = "done"
+	EventError     = "error"
+	EventCancelled = "cancelled"
+)
+
 type ChatResponse struct {
 	// What we get back from the LLM Api
-	Response string `json:"response"`
-	Context  []int  `json:"context,omitempty"`
-	Done     bool   `json:"done"`
+	// Usage arrives in its own response near the end of the stream, when the provider
+	// reports it at all. RequestID, SessionID and Event are filled in by the bus so the
+	// UI can route the response and drop ones from requests it no longer waits on;
+	// Error is set on a final response that failed.
+	Response  string `json:"response"`
+	Done      bool   `json:"done"`
+	Usage     *Usage `json:"usage,omitempty"`
+	RequestID string `json:"request_id,omitempty"`
+	SessionID string `json:"session_id,omitempty"`
+	Event     string `json:"event,omitempty"`
+	Error     string `json:"error,omitempty"`
 }
 
 type BusConnector struct {
@@ -0,0 +1,50 @@
+package types
+
+import (
+	"context"
+	"errors"
+	"fmt"
+)
+
+// EmbedBatchSize is how many inputs go to a provider per embedding request.
+const EmbedBatchSize = 64
+
+// ErrNoEmbeddings is returned for providers that can't embed.
+var ErrNoEmbeddings = errors.New("provider does not support embeddings")
+
+type Embedder interface {
+	// Embedder is an optional capability of a Provider. Embed returns one vector per
+	// input, in order; callers keep batches to a reasonable size.
+	Embed(ctx context.Context, model string, inputs []string) ([][]float32, error)
+}
+
+// CanEmbed reports whether the provider implements Embedder.
+func (ps *ProviderService) CanEmbed() bool {
+	_, ok := ps.modelProvider.(Embedder)
+	return ok
+}
+
+// Embed embeds inputs with model, EmbedBatchSize at a time. progress, if set, is told
+// how many are done after each batch.
+func (ps *ProviderService) Embed(ctx context.Context, model string, inputs []string, progress func(done int)) ([][]float32, error) {
+	embedder, ok := ps.modelProvider.(Embedder)
+	if !ok {
+		return nil, ErrNoEmbeddings
+	}
+	vectors := make([][]float32, 0, len(inputs))
+	for start := 0; start < len(inputs); start += EmbedBatchSize {
+		batch := inputs[start:min(start+EmbedBatchSize, len(inputs))]
+		out, err := embedder.Embed(ctx, model, batch)
+		if err != nil {
+			return nil, err
+		}
+		if len(out) != len(batch) {
+			return nil, fmt.Errorf("asked for %d embeddings, got %d", len(batch), len(out))
+		}
+		vectors = append(vectors, out...)
+		if progress != nil {
+			progress(len(vectors))
+		}
+	}
+	return vectors, nil
+}
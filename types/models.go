@@ -14,6 +14,30 @@ the local cache is stale, in which case it can pull it fresh from the API.
 type Model struct {
 	Name       string   `json:"name"`
 	Modalities []string `json:"modalities"`
+	// ContextLength is how many tokens the model takes in as the provider serves it,
+	// or 0 when the provider doesn't say.
+	ContextLength int `json:"context_length,omitempty"`
+	// EmbedOnly marks models that only make embeddings and can't chat.
+	EmbedOnly bool `json:"embed_only,omitempty"`
+}
+
+const (
+	ModalityText  = "text"
+	ModalityImage = "image"
+)
+
+// Supports reports whether the model accepts the given input modality. A model with no
+// modalities listed is treated as unknown and allowed through.
+func (m Model) Supports(modality string) bool {
+	if len(m.Modalities) == 0 {
+		return true
+	}
+	for _, v := range m.Modalities {
+		if v == modality {
+			return true
+		}
+	}
+	return false
 }
 
 type ModelRefresher struct {
@@ -1,11 +1,17 @@
 package types
 
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
 type Provider interface {
 	// Provider Takes the universal Chat Request, along with reponseChannel and errorChannel
 	// from the Chat Bus. This way each Provider can handle the serializing, deserializing
 	// and streaming that may be provider specific.
 	Chat(c *BusConnector)
-	GenerateRequest(prompt string) *ChatRequest
+	GenerateRequest(messages []Message) *ChatRequest
 	RetrieveModels() ([]Model, error)
 	SetModel(model string)
 }
@@ -25,8 +31,8 @@ func (ps *ProviderService) Chat(c *BusConnector) {
 	ps.modelProvider.Chat(c)
 }
 
-func (ps *ProviderService) GenerateRequest(prompt string) *ChatRequest {
-	return ps.modelProvider.GenerateRequest(prompt)
+func (ps *ProviderService) GenerateRequest(messages []Message) *ChatRequest {
+	return ps.modelProvider.GenerateRequest(messages)
 }
 
 func (ps *ProviderService) RetrieveModels() ([]Model, error) {
@@ -36,3 +42,81 @@ func (ps *ProviderService) RetrieveModels() ([]Model, error) {
 func (ps *ProviderService) SetModel(model string) {
 	ps.modelProvider.SetModel(model)
 }
+
+// FindModel looks up a model by name in the provider's model list.
+func (ps *ProviderService) FindModel(name string) (Model, bool, error) {
+	models, err := ps.modelProvider.RetrieveModels()
+	if err != nil {
+		return Model{}, false, err
+	}
+	for _, m := range models {
+		if m.Name == name {
+			return m, true, nil
+		}
+	}
+	return Model{}, false, nil
+}
+
+type ProviderRegistry struct {
+	// The configured providers by name, such as "ollama" or "openrouter". Model names
+	// without a "provider:" prefix go to Default.
+	Default   string
+	providers map[string]*ProviderService
+}
+
+// NewProviderRegistry creates an empty registry whose unprefixed models go to defaultName.
+func NewProviderRegistry(defaultName string) *ProviderRegistry {
+	return &ProviderRegistry{
+		Default:   defaultName,
+		providers: map[string]*ProviderService{},
+	}
+}
+
+func (r *ProviderRegistry) Register(name string, ps *ProviderService) {
+	r.providers[name] = ps
+}
+
+// Get returns the provider registered under name.
+func (r *ProviderRegistry) Get(name string) (*ProviderService, bool) {
+	ps, ok := r.providers[name]
+	return ps, ok
+}
+
+// Names lists the registered providers, sorted.
+func (r *ProviderRegistry) Names() []string {
+	names := make([]string, 0, len(r.providers))
+	for name := range r.providers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// NameOf returns the name the provider is registered under.
+func (r *ProviderRegistry) NameOf(ps *ProviderService) (string, bool) {
+	for name, registered := range r.providers {
+		if registered == ps {
+			return name, true
+		}
+	}
+	return "", false
+}
+
+// Resolve splits "provider:model" into the provider and the model name. Ollama tags
+// contain colons too ("llama3.2:latest"), so the prefix only counts when it names a
+// registered provider.
+func (r *ProviderRegistry) Resolve(spec string) (*ProviderService, string, error) {
+	if name, model, ok := strings.Cut(spec, ":"); ok {
+		if ps, ok := r.providers[name]; ok {
+			if model == "" {
+				return nil, "", fmt.Errorf("no model given for %s", name)
+			}
+			return ps, model, nil
+		}
+	}
+	ps, ok := r.providers[r.Default]
+	if !ok {
+		return nil, "", fmt.Errorf("no provider for %q", spec)
+	}
+	return ps, spec, nil
+}
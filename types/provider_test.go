@@ -0,0 +1,56 @@
+package types
+
+import "testing"
+
+type stubProvider struct{ name string }
+
+func (s *stubProvider) Chat(c *BusConnector)                            {}
+func (s *stubProvider) GenerateRequest(messages []Message) *ChatRequest { return &ChatRequest{} }
+func (s *stubProvider) RetrieveModels() ([]Model, error)                { return nil, nil }
+func (s *stubProvider) SetModel(model string)                           {}
+
+func TestProviderRegistryResolve(t *testing.T) {
+	ollama := NewProviderService(&stubProvider{"ollama"})
+	openRouter := NewProviderService(&stubProvider{"openrouter"})
+	r := NewProviderRegistry("ollama")
+	r.Register("ollama", ollama)
+	r.Register("openrouter", openRouter)
+
+	tests := []struct {
+		spec      string
+		provider  *ProviderService
+		model     string
+		wantError bool
+	}{
+		{spec: "llama3.2", provider: ollama, model: "llama3.2"},
+		{spec: "llama3.2:latest", provider: ollama, model: "llama3.2:latest"},
+		{spec: "ollama:llama3.2:latest", provider: ollama, model: "llama3.2:latest"},
+		{spec: "openrouter:openai/gpt-4o", provider: openRouter, model: "openai/gpt-4o"},
+		{spec: "x-ai/grok-4-fast:free", provider: ollama, model: "x-ai/grok-4-fast:free"},
+		{spec: "openrouter:", wantError: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.spec, func(t *testing.T) {
+			ps, model, err := r.Resolve(tt.spec)
+			if tt.wantError {
+				if err == nil {
+					t.Fatalf("Resolve(%q) = %q, want an error", tt.spec, model)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Resolve(%q): %v", tt.spec, err)
+			}
+			if ps != tt.provider || model != tt.model {
+				t.Errorf("Resolve(%q) = %v, %q, want %v, %q", tt.spec, ps.modelProvider, model, tt.provider.modelProvider, tt.model)
+			}
+		})
+	}
+}
+
+func TestProviderRegistryResolveWithoutDefault(t *testing.T) {
+	r := NewProviderRegistry("ollama")
+	if _, _, err := r.Resolve("llama3.2:latest"); err == nil {
+		t.Error("Resolve without a default provider should fail")
+	}
+}
@@ -0,0 +1,19 @@
+package types
+
+import "unicode/utf8"
+
+// Truncate shortens s to at most n runes, ending it with "..." when anything was cut.
+// It never splits a rune, so the result is as valid UTF-8 as s.
+func Truncate(s string, n int) string {
+	if utf8.RuneCountInString(s) <= n {
+		return s
+	}
+	keep := max(n-3, 0)
+	for i := range s {
+		if keep == 0 {
+			return s[:i] + "..."
+		}
+		keep--
+	}
+	return s
+}
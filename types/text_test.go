@@ -0,0 +1,32 @@
+package types
+
+import (
+	"testing"
+	"unicode/utf8"
+)
+
+func TestTruncate(t *testing.T) {
+	tests := []struct {
+		s    string
+		n    int
+		want string
+	}{
+		{"", 10, ""},
+		{"short", 10, "short"},
+		{"exactly 10", 10, "exactly 10"},
+		{"one too long", 11, "one too ..."},
+		{"日本語のタイトルです", 9, "日本語のタイ..."},
+		{"日本語のタイトルです", 10, "日本語のタイトルです"},
+		{"héllo wörld", 8, "héllo..."},
+		{"abcdef", 2, "..."},
+	}
+	for _, tt := range tests {
+		got := Truncate(tt.s, tt.n)
+		if got != tt.want {
+			t.Errorf("Truncate(%q, %d) = %q, want %q", tt.s, tt.n, got, tt.want)
+		}
+		if !utf8.ValidString(got) {
+			t.Errorf("Truncate(%q, %d) is not valid UTF-8", tt.s, tt.n)
+		}
+	}
+}
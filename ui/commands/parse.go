@@ -0,0 +1,108 @@
+package commands
+
+import (
+	"errors"
+	"strings"
+	"unicode"
+)
+
+var ErrUnterminatedQuote = errors.New("unterminated quote")
+
+type Input struct {
+	// A parsed /command line. Rest is everything after the name, untouched, for commands
+	// that take free text rather than arguments.
+	Name string
+	Args []string
+	Rest string
+}
+
+// IsCommand reports whether the input should be dispatched as a slash command. A
+// leading // escapes the slash so a message can start with one.
+func IsCommand(input string) bool {
+	trimmed := strings.TrimSpace(input)
+	return strings.HasPrefix(trimmed, "/") && !strings.HasPrefix(trimmed, "//")
+}
+
+// Unescape strips the extra slash from an escaped //message.
+func Unescape(input string) string {
+	trimmed := strings.TrimLeftFunc(input, unicode.IsSpace)
+	if strings.HasPrefix(trimmed, "//") {
+		return trimmed[1:]
+	}
+	return input
+}
+
+// Parse splits a /command line into its name and arguments.
+func Parse(input string) (*Input, error) {
+	trimmed := strings.TrimSpace(input)
+	trimmed = strings.TrimPrefix(trimmed, "/")
+	name, rest, _ := strings.Cut(trimmed, " ")
+	rest = strings.TrimSpace(rest)
+	args, err := Split(rest)
+	if err != nil {
+		return nil, err
+	}
+	return &Input{Name: strings.ToLower(name), Args: args, Rest: rest}, nil
+}
+
+// After returns Rest with its first n words dropped, for commands that take a few
+// arguments followed by free text.
+func (in *Input) After(n int) string {
+	rest := in.Rest
+	for i := 0; i < n; i++ {
+		rest = strings.TrimLeftFunc(rest, unicode.IsSpace)
+		end := strings.IndexFunc(rest, unicode.IsSpace)
+		if end < 0 {
+			return ""
+		}
+		rest = rest[end:]
+	}
+	return strings.TrimSpace(rest)
+}
+
+// Split breaks s into shell style words. Single and double quotes group words and a
+// backslash escapes the next character outside single quotes.
+func Split(s string) ([]string, error) {
+	args := []string{}
+	var (
+		current strings.Builder
+		inWord  bool
+		quote   rune
+		escaped bool
+	)
+	for _, r := range s {
+		switch {
+		case escaped:
+			current.WriteRune(r)
+			escaped = false
+		case r == '\\' && quote != '\'':
+			escaped = true
+			inWord = true
+		case quote != 0:
+			if r == quote {
+				quote = 0
+			} else {
+				current.WriteRune(r)
+			}
+		case r == '"' || r == '\'':
+			quote = r
+			inWord = true
+		case unicode.IsSpace(r):
+			if inWord {
+				args = append(args, current.String())
+				current.Reset()
+				inWord = false
+			}
+		default:
+			current.WriteRune(r)
+			inWord = true
+		}
+	}
+	if quote != 0 {
+		return nil, ErrUnterminatedQuote
+	}
+	if inWord {
+		args = append(args, current.String())
+	}
+	return args, nil
+}
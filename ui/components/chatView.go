@@ -8,25 +8,378 @@ import (
 	"github.com/charmbracelet/lipgloss"
 )
 
+// maxCachedWidths bounds how many widths a message keeps renderings for.
+const maxCachedWidths = 4
+
+type ViewMessage struct {
+	// Header is the styled "[15:04] sender:" prefix. Body is raw markdown when Markdown is
+	// set and plain text otherwise; either way it is laid out at the view's current width.
+	// ID links the message to the conversation and is empty for UI-only messages.
+	ID       string
+	Header   string
+	Body     string
+	Markdown bool
+	rendered map[int]string
+}
+
 type ChatView struct {
 	Viewport viewport.Model
-	Messages []string
-	renderer *glamour.TermRenderer
+	Messages []*ViewMessage
+
+	// style is the glamour style, picked once at startup. Renderers are created per
+	// width from it since glamour fixes the word wrap when a renderer is built.
+	style     string
+	renderers map[int]*glamour.TermRenderer
+
+	// content caches the finished messages, wrapped and joined, so appending a message
+	// or repainting a stream doesn't touch the rest of the transcript.
+	content      string
+	contentWidth int
+	stream       *stream
+
+	// Selected is the index of the message under the cursor in selection mode, or -1.
+	Selected int
+	// offsets holds the first line of each message within content.
+	offsets []int
+	lines   int
 }
 
-func NewChatView(width, height int, renderer *glamour.TermRenderer) *ChatView {
+type stream struct {
+	// A message still being generated. Raw markdown up to stableLen ends on a block
+	// boundary and its rendering is kept in stable; only the tail after it is rendered
+	// again as tokens arrive.
+	header    string
+	raw       string
+	width     int
+	stableLen int
+	stable    string
+	dirty     bool
+	// wrapped caches header+stable wrapped to the view width.
+	wrapped string
+}
+
+func NewChatView(width, height int, style string) *ChatView {
 	vp := viewport.New(width, height)
 	return &ChatView{
-		Viewport: vp,
-		Messages: []string{},
-		renderer: renderer,
+		Viewport:  vp,
+		Messages:  []*ViewMessage{},
+		style:     style,
+		renderers: map[int]*glamour.TermRenderer{},
+		Selected:  -1,
 	}
 }
 
+// Style is the glamour style the view renders with, for creating views alike.
+func (c *ChatView) Style() string {
+	return c.style
+}
+
+// Append adds a finished plain text message to the transcript.
+func (c *ChatView) Append(text string) *ViewMessage {
+	return c.add(&ViewMessage{Body: text})
+}
+
+// AppendMarkdown adds a finished message whose body is rendered as markdown.
+func (c *ChatView) AppendMarkdown(header, body string) *ViewMessage {
+	return c.add(&ViewMessage{Header: header, Body: body, Markdown: true})
+}
+
+func (c *ChatView) add(msg *ViewMessage) *ViewMessage {
+	c.Messages = append(c.Messages, msg)
+	if c.contentWidth != c.Viewport.Width {
+		c.rebuild()
+	} else {
+		c.appendContent(c.layout(msg))
+	}
+	c.Set()
+	return msg
+}
+
+func (c *ChatView) appendContent(laidOut string) {
+	if c.content != "" {
+		c.content += "\n"
+		c.lines++
+	}
+	c.offsets = append(c.offsets, c.lines)
+	c.content += laidOut
+	c.lines += strings.Count(laidOut, "\n")
+}
+
+// Clear empties the transcript.
+func (c *ChatView) Clear() {
+	c.Messages = []*ViewMessage{}
+	c.stream = nil
+	c.Selected = -1
+	c.rebuild()
+	c.Set()
+}
+
+// Select moves the selection cursor to message i, scrolling it into view. Passing -1
+// leaves selection mode.
+func (c *ChatView) Select(i int) {
+	if i >= len(c.Messages) {
+		i = len(c.Messages) - 1
+	}
+	c.Selected = max(i, -1)
+	c.rebuild()
+	c.Set()
+}
+
+// MoveSelection moves the cursor by delta messages, stopping at either end.
+func (c *ChatView) MoveSelection(delta int) {
+	if len(c.Messages) == 0 {
+		return
+	}
+	c.Select(min(max(c.Selected+delta, 0), len(c.Messages)-1))
+}
+
+// SelectedMessage returns the message under the cursor.
+func (c *ChatView) SelectedMessage() (*ViewMessage, bool) {
+	if c.Selected < 0 || c.Selected >= len(c.Messages) {
+		return nil, false
+	}
+	return c.Messages[c.Selected], true
+}
+
+// Streaming reports whether a response is being written into the view.
+func (c *ChatView) Streaming() bool {
+	return c.stream != nil
+}
+
+// Set repaints the viewport from the cached transcript plus any message in flight.
 func (c *ChatView) Set() {
-	c.Viewport.SetContent(
-		lipgloss.NewStyle().Width(
-			c.Viewport.Width).Render(
-			strings.Join(c.Messages, "\n")))
-	c.Viewport.GotoBottom()
+	if c.contentWidth != c.Viewport.Width {
+		c.rebuild()
+	}
+	content := c.content
+	// A stream follows the bottom unless the user has scrolled up to read back.
+	follow := true
+	if c.stream != nil {
+		// Only the stream's tail is rendered again; the transcript before it is cached.
+		content = joinMessage(c.content, c.streamView())
+		follow = c.Viewport.AtBottom()
+		c.stream.dirty = false
+	}
+	c.Viewport.SetContent(content)
+	if c.Selected < 0 || c.Selected >= len(c.offsets) {
+		if follow {
+			c.Viewport.GotoBottom()
+		}
+		return
+	}
+	top := c.offsets[c.Selected]
+	bottom := c.lines
+	if c.Selected+1 < len(c.offsets) {
+		bottom = c.offsets[c.Selected+1] - 1
+	}
+	// Keep the whole message on screen when it fits, otherwise show its start.
+	if top < c.Viewport.YOffset || bottom-top >= c.Viewport.Height {
+		c.Viewport.SetYOffset(top)
+	} else if bottom >= c.Viewport.YOffset+c.Viewport.Height {
+		c.Viewport.SetYOffset(bottom - c.Viewport.Height + 1)
+	}
+}
+
+// Render renders markdown at the view's current width.
+func (c *ChatView) Render(markdown string) string {
+	r := c.renderer(c.Viewport.Width)
+	if r == nil {
+		return markdown
+	}
+	rendered, err := r.Render(markdown)
+	if err != nil {
+		return markdown
+	}
+	return rendered
+}
+
+func (c *ChatView) renderer(width int) *glamour.TermRenderer {
+	if r, ok := c.renderers[width]; ok {
+		return r
+	}
+	r, err := glamour.NewTermRenderer(
+		glamour.WithStandardStyle(c.style),
+		glamour.WithWordWrap(max(20, width-4)),
+	)
+	if err != nil {
+		return nil
+	}
+	c.renderers[width] = r
+	return r
+}
+
+// layout renders a message at the current width, reusing an earlier rendering at the
+// same width. Going back and forth between sizes is cheap this way.
+func (c *ChatView) layout(msg *ViewMessage) string {
+	width := c.Viewport.Width
+	if rendered, ok := msg.rendered[width]; ok {
+		return rendered
+	}
+	body := msg.Body
+	if msg.Markdown {
+		body = c.Render(body)
+	}
+	rendered := c.wrap(msg.Header + body)
+	if msg.rendered == nil || len(msg.rendered) >= maxCachedWidths {
+		msg.rendered = map[int]string{}
+	}
+	msg.rendered[width] = rendered
+	return rendered
+}
+
+// rebuild joins every finished message again. Messages keep their layout for each width,
+// so this only renders anything when the width changes.
+func (c *ChatView) rebuild() {
+	c.contentWidth = c.Viewport.Width
+	c.content = ""
+	c.lines = 0
+	c.offsets = c.offsets[:0]
+	for i, msg := range c.Messages {
+		if i == c.Selected {
+			c.appendContent(c.layoutSelected(msg))
+			continue
+		}
+		c.appendContent(c.layout(msg))
+	}
+}
+
+// layoutSelected marks the message under the cursor with a bar down its left side.
+func (c *ChatView) layoutSelected(msg *ViewMessage) string {
+	body := msg.Body
+	if msg.Markdown {
+		body = c.Render(body)
+	}
+	return lipgloss.NewStyle().
+		Border(lipgloss.ThickBorder(), false, false, false, true).
+		BorderForeground(lipgloss.Color("62")).
+		Render(lipgloss.NewStyle().Width(max(1, c.Viewport.Width-1)).Render(msg.Header + body))
+}
+
+func (c *ChatView) wrap(msg string) string {
+	return lipgloss.NewStyle().Width(c.Viewport.Width).Render(msg)
+}
+
+func joinMessage(content, msg string) string {
+	if content == "" {
+		return msg
+	}
+	return content + "\n" + msg
+}
+
+// lastLines returns the last n lines of s.
+func lastLines(s string, n int) string {
+	end := len(s)
+	for i := 0; i < n; i++ {
+		j := strings.LastIndexByte(s[:end], '\n')
+		if j < 0 {
+			return s
+		}
+		end = j
+	}
+	return s[end+1:]
+}
+
+// StartStream begins a message that will be filled in with UpdateStream.
+func (c *ChatView) StartStream(header string) {
+	c.stream = &stream{header: header, width: c.Viewport.Width}
+}
+
+// UpdateStream records the full markdown of the message so far. Blocks that can no
+// longer change are rendered once here; the view is repainted by Set, which callers
+// throttle to a frame rate.
+func (c *ChatView) UpdateStream(raw string) {
+	if c.stream == nil {
+		return
+	}
+	s := c.stream
+	s.raw = raw
+	s.dirty = true
+	c.advanceStream()
+}
+
+func (c *ChatView) advanceStream() {
+	s := c.stream
+	if s.width != c.Viewport.Width {
+		// The stable blocks were wrapped for the old width.
+		s.width = c.Viewport.Width
+		s.stableLen = 0
+		s.stable = ""
+		s.wrapped = ""
+	}
+	if boundary := stableBoundary(s.raw); boundary > s.stableLen {
+		rendered := c.Render(s.raw[s.stableLen:boundary])
+		s.stable += strings.TrimRight(rendered, "\n") + "\n"
+		s.stableLen = boundary
+		s.wrapped = ""
+	}
+}
+
+// StreamDirty reports whether the stream changed since it was last painted.
+func (c *ChatView) StreamDirty() bool {
+	return c.stream != nil && c.stream.dirty
+}
+
+// FinishStream drops the in-flight message. The caller appends the final message,
+// which is rendered in one pass so block spacing matches the rest of the transcript.
+func (c *ChatView) FinishStream() {
+	c.stream = nil
+}
+
+func (c *ChatView) streamView() string {
+	c.advanceStream()
+	s := c.stream
+	tail := c.renderTail()
+	if s.stable == "" {
+		return c.wrap(s.header + tail)
+	}
+	if s.wrapped == "" {
+		s.wrapped = c.wrap(s.header + strings.TrimRight(s.stable, "\n"))
+	}
+	if tail == "" {
+		return s.wrapped
+	}
+	return s.wrapped + "\n" + c.wrap(tail)
+}
+
+func (c *ChatView) renderTail() string {
+	tail := c.stream.raw[c.stream.stableLen:]
+	if strings.TrimSpace(tail) == "" {
+		return ""
+	}
+	return c.Render(tail)
+}
+
+// stableBoundary returns the offset just past the last blank line that sits outside a
+// code fence and has more text after it. Everything before it is complete markdown
+// blocks that further tokens can't change.
+func stableBoundary(raw string) int {
+	boundary := 0
+	inFence := false
+	fence := ""
+	offset := 0
+	for offset < len(raw) {
+		end := strings.IndexByte(raw[offset:], '\n')
+		if end < 0 {
+			// The last line is still being written.
+			break
+		}
+		line := raw[offset : offset+end]
+		offset += end + 1
+		trimmed := strings.TrimSpace(line)
+		switch {
+		case inFence:
+			if strings.HasPrefix(trimmed, fence) && strings.Trim(trimmed, fence[:1]) == "" {
+				inFence = false
+			}
+		case strings.HasPrefix(trimmed, "```") || strings.HasPrefix(trimmed, "~~~"):
+			inFence = true
+			fence = trimmed[:3]
+			for len(fence) < len(trimmed) && trimmed[len(fence)] == fence[0] {
+				fence += fence[:1]
+			}
+		case trimmed == "" && offset < len(raw):
+			boundary = offset
+		}
+	}
+	return boundary
 }
@@ -0,0 +1,137 @@
+package components
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/charmbracelet/glamour"
+	"github.com/charmbracelet/lipgloss"
+)
+
+const (
+	benchWidth   = 100
+	benchHistory = 30
+	// tokensPerFrame approximates a fast model streaming ~90 tokens/s into 30 frames/s.
+	tokensPerFrame = 3
+)
+
+var paragraph = "Here is some **markdown** with `inline code` and a [link](https://example.com). " +
+	"It goes on for a little while so that wrapping has some work to do as well.\n\n"
+
+var codeBlock = "```go\nfunc fib(n int) int {\n\tif n < 2 {\n\t\treturn n\n\t}\n\treturn fib(n-1) + fib(n-2)\n}\n```\n\n"
+
+func response(blocks int) string {
+	var sb strings.Builder
+	for i := 0; i < blocks; i++ {
+		sb.WriteString(paragraph)
+		if i%3 == 0 {
+			sb.WriteString(codeBlock)
+		}
+	}
+	return sb.String()
+}
+
+// tokens splits the response into roughly word sized chunks, like a streaming model.
+func tokens(s string) []string {
+	out := []string{}
+	start := 0
+	for i, r := range s {
+		if r == ' ' || r == '\n' {
+			out = append(out, s[start:i+1])
+			start = i + 1
+		}
+	}
+	return append(out, s[start:])
+}
+
+func newTestChatView(history int) *ChatView {
+	view := NewChatView(benchWidth, 40, "dark")
+	for i := 0; i < history; i++ {
+		view.AppendMarkdown("model: ", response(4))
+	}
+	return view
+}
+
+func TestChatViewStreamKeepsScrollback(t *testing.T) {
+	view := newTestChatView(5)
+	view.StartStream("model: ")
+	view.UpdateStream(paragraph)
+	view.Set()
+	if !view.Viewport.AtBottom() {
+		t.Fatal("a new stream should follow the bottom")
+	}
+
+	view.Viewport.SetYOffset(0)
+	view.UpdateStream(paragraph + paragraph + paragraph)
+	view.Set()
+	if view.Viewport.YOffset != 0 {
+		t.Errorf("YOffset = %d after a repaint, want the reader left at the top", view.Viewport.YOffset)
+	}
+	if got := view.Viewport.TotalLineCount(); got <= strings.Count(view.content, "\n") {
+		t.Errorf("viewport has %d lines, want the whole transcript plus the stream", got)
+	}
+
+	view.Viewport.GotoBottom()
+	view.UpdateStream(response(3))
+	view.Set()
+	if !view.Viewport.AtBottom() {
+		t.Error("scrolling back down should follow the stream again")
+	}
+}
+
+// naive mirrors how the view used to work: every token re-rendered the whole response
+// through glamour and re-wrapped the whole transcript.
+func naive(b *testing.B, toks []string) {
+	r, err := glamour.NewTermRenderer(glamour.WithStandardStyle("dark"), glamour.WithWordWrap(benchWidth-4))
+	if err != nil {
+		b.Fatal(err)
+	}
+	msgs := []string{}
+	for i := 0; i < benchHistory; i++ {
+		rendered, _ := r.Render(response(4))
+		msgs = append(msgs, "model: "+rendered)
+	}
+	view := NewChatView(benchWidth, 40, "dark")
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		current := ""
+		for _, tok := range toks {
+			current += tok
+			rendered, _ := r.Render(current)
+			all := append(msgs[:len(msgs):len(msgs)], "model: "+rendered)
+			view.Viewport.SetContent(lipgloss.NewStyle().Width(benchWidth).Render(strings.Join(all, "\n")))
+			view.Viewport.GotoBottom()
+		}
+	}
+}
+
+func incremental(b *testing.B, toks []string, perFrame int) {
+	for i := 0; i < b.N; i++ {
+		b.StopTimer()
+		view := newTestChatView(benchHistory)
+		b.StartTimer()
+		view.StartStream("model: ")
+		current := ""
+		for j, tok := range toks {
+			current += tok
+			view.UpdateStream(current)
+			if j%perFrame == 0 {
+				view.Set()
+			}
+		}
+		view.FinishStream()
+		view.AppendMarkdown("model: ", current)
+	}
+}
+
+// BenchmarkChatViewStream streams a response into a view that already holds a
+// transcript, comparing a full re-render per token with the incremental view.
+func BenchmarkChatViewStream(b *testing.B) {
+	for _, blocks := range []int{5, 20} {
+		toks := tokens(response(blocks))
+		b.Run(fmt.Sprintf("tokens=%d/naive", len(toks)), func(b *testing.B) { naive(b, toks) })
+		b.Run(fmt.Sprintf("tokens=%d/incremental", len(toks)), func(b *testing.B) { incremental(b, toks, 1) })
+		b.Run(fmt.Sprintf("tokens=%d/throttled", len(toks)), func(b *testing.B) { incremental(b, toks, tokensPerFrame) })
+	}
+}
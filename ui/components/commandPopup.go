@@ -0,0 +1,113 @@
+package components
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/charmbracelet/lipgloss"
+)
+
+// maxSuggestions caps how many commands the popup lists at once.
+const maxSuggestions = 6
+
+type CommandSuggestion struct {
+	Name        string
+	Usage       string
+	Description string
+}
+
+type CommandPopup struct {
+	Suggestions []CommandSuggestion
+	Selected    int
+	Width       int
+	// Prefix is drawn before each name: "/" for commands, nothing for templates.
+	Prefix string
+}
+
+func NewCommandPopup(width int) *CommandPopup {
+	return &CommandPopup{Width: width, Prefix: "/"}
+}
+
+// Filter narrows the popup down to the commands starting with prefix.
+func (c *CommandPopup) Filter(all []CommandSuggestion, prefix string) {
+	current := ""
+	if sel, ok := c.Current(); ok {
+		current = sel.Name
+	}
+	c.Suggestions = []CommandSuggestion{}
+	c.Selected = 0
+	for _, s := range all {
+		if strings.HasPrefix(s.Name, prefix) {
+			if s.Name == current {
+				c.Selected = len(c.Suggestions)
+			}
+			c.Suggestions = append(c.Suggestions, s)
+		}
+	}
+}
+
+func (c *CommandPopup) Visible() bool {
+	return len(c.Suggestions) > 0
+}
+
+func (c *CommandPopup) Hide() {
+	c.Suggestions = nil
+	c.Selected = 0
+}
+
+func (c *CommandPopup) Current() (CommandSuggestion, bool) {
+	if c.Selected < 0 || c.Selected >= len(c.Suggestions) {
+		return CommandSuggestion{}, false
+	}
+	return c.Suggestions[c.Selected], true
+}
+
+func (c *CommandPopup) Next() {
+	if len(c.Suggestions) > 0 {
+		c.Selected = (c.Selected + 1) % len(c.Suggestions)
+	}
+}
+
+func (c *CommandPopup) Prev() {
+	if len(c.Suggestions) > 0 {
+		c.Selected = (c.Selected - 1 + len(c.Suggestions)) % len(c.Suggestions)
+	}
+}
+
+// Height is the number of lines View takes up.
+func (c *CommandPopup) Height() int {
+	if !c.Visible() {
+		return 0
+	}
+	return lipgloss.Height(c.View())
+}
+
+func (c *CommandPopup) View() string {
+	if !c.Visible() {
+		return ""
+	}
+	start := 0
+	if c.Selected >= maxSuggestions {
+		start = c.Selected - maxSuggestions + 1
+	}
+	end := min(start+maxSuggestions, len(c.Suggestions))
+
+	nameStyle := lipgloss.NewStyle().Foreground(lipgloss.Color("75"))
+	descStyle := lipgloss.NewStyle().Foreground(lipgloss.Color("241"))
+	selectedStyle := lipgloss.NewStyle().Background(lipgloss.Color("238"))
+
+	lines := []string{}
+	for i := start; i < end; i++ {
+		s := c.Suggestions[i]
+		line := fmt.Sprintf("%s %s", nameStyle.Render(c.Prefix+s.Name+" "+s.Usage), descStyle.Render(s.Description))
+		if i == c.Selected {
+			line = selectedStyle.Render(line)
+		}
+		lines = append(lines, line)
+	}
+	return lipgloss.NewStyle().
+		Border(lipgloss.RoundedBorder()).
+		BorderForeground(lipgloss.Color("62")).
+		MaxWidth(c.Width).
+		Render(strings.Join(lines, "\n"))
+}
@@ -0,0 +1,93 @@
+package components
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/charmbracelet/glamour"
+	"github.com/charmbracelet/lipgloss"
+)
+
+type ComparePane struct {
+	// One model's answer in compare mode. Body is the raw markdown so far; it is only
+	// rendered as markdown once Done, plain text is cheaper to repaint while streaming.
+	Title  string
+	Stats  string
+	Body   string
+	Done   bool
+	Failed bool
+}
+
+type CompareView struct {
+	Panes  []*ComparePane
+	Width  int
+	Height int
+	// Hint is shown under the panes.
+	Hint      string
+	style     string
+	renderers map[int]*glamour.TermRenderer
+}
+
+func NewCompareView(style string) *CompareView {
+	return &CompareView{style: style, renderers: map[int]*glamour.TermRenderer{}}
+}
+
+// View lays the panes out side by side, each showing the end of its answer.
+func (v *CompareView) View() string {
+	if len(v.Panes) == 0 {
+		return ""
+	}
+	height := max(3, v.Height-1)
+	width := max(12, v.Width/len(v.Panes))
+	borderStyle := lipgloss.NewStyle().Border(lipgloss.RoundedBorder()).BorderForeground(lipgloss.Color("240"))
+	titleStyle := lipgloss.NewStyle().Bold(true).Foreground(lipgloss.Color("75"))
+	statsStyle := lipgloss.NewStyle().Foreground(lipgloss.Color("241"))
+	failedStyle := lipgloss.NewStyle().Foreground(lipgloss.Color("9"))
+
+	panes := make([]string, 0, len(v.Panes))
+	for i, p := range v.Panes {
+		inner := width - 2
+		header := titleStyle.Render(fmt.Sprintf("[%d] %s", i+1, p.Title))
+		stats := statsStyle.Render(p.Stats)
+		if p.Failed {
+			stats = failedStyle.Render(p.Stats)
+		}
+		body := p.Body
+		if p.Done && !p.Failed {
+			body = v.render(body, inner)
+		}
+		body = lipgloss.NewStyle().Width(inner).Render(body)
+		bodyHeight := max(1, height-2-lipgloss.Height(header)-lipgloss.Height(stats))
+		pane := strings.Join([]string{
+			lipgloss.NewStyle().MaxWidth(inner).Render(header),
+			lipgloss.NewStyle().MaxWidth(inner).Render(stats),
+			lastLines(body, bodyHeight),
+		}, "\n")
+		panes = append(panes, borderStyle.Width(inner).Height(height-2).MaxHeight(height).Render(pane))
+	}
+	out := lipgloss.JoinHorizontal(lipgloss.Top, panes...)
+	if v.Hint != "" {
+		out += "\n" + statsStyle.Render(v.Hint)
+	}
+	return out
+}
+
+func (v *CompareView) render(markdown string, width int) string {
+	r, ok := v.renderers[width]
+	if !ok {
+		var err error
+		r, err = glamour.NewTermRenderer(
+			glamour.WithStandardStyle(v.style),
+			glamour.WithWordWrap(max(10, width-2)),
+		)
+		if err != nil {
+			return markdown
+		}
+		v.renderers[width] = r
+	}
+	rendered, err := r.Render(markdown)
+	if err != nil {
+		return markdown
+	}
+	return strings.Trim(rendered, "\n")
+}
@@ -0,0 +1,56 @@
+package components
+
+import (
+	"os"
+	"os/exec"
+	"strings"
+
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+type EditorClosedMsg struct {
+	Content string
+	Err     error
+}
+
+// OpenEditor suspends the UI and opens content in $EDITOR (falling back to $VISUAL and
+// then vi) through a temp file. The edited text comes back in an EditorClosedMsg.
+func OpenEditor(content string) tea.Cmd {
+	f, err := os.CreateTemp("", "bash-butler-*.md")
+	if err != nil {
+		return func() tea.Msg { return EditorClosedMsg{Err: err} }
+	}
+	path := f.Name()
+	_, err = f.WriteString(content)
+	if closeErr := f.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		os.Remove(path)
+		return func() tea.Msg { return EditorClosedMsg{Err: err} }
+	}
+
+	// $EDITOR may carry flags, e.g. "code --wait".
+	args := strings.Fields(editor())
+	cmd := exec.Command(args[0], append(args[1:], path)...)
+	return tea.ExecProcess(cmd, func(err error) tea.Msg {
+		defer os.Remove(path)
+		if err != nil {
+			return EditorClosedMsg{Err: err}
+		}
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return EditorClosedMsg{Err: err}
+		}
+		return EditorClosedMsg{Content: strings.TrimRight(string(data), "\n")}
+	})
+}
+
+func editor() string {
+	for _, env := range []string{"EDITOR", "VISUAL"} {
+		if e := strings.TrimSpace(os.Getenv(env)); e != "" {
+			return e
+		}
+	}
+	return "vi"
+}
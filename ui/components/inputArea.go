@@ -1,14 +1,44 @@
 package components
 
 import (
+	"path/filepath"
+	"strings"
+
+	"github.com/charmbracelet/bubbles/key"
 	"github.com/charmbracelet/bubbles/textarea"
+	tea "github.com/charmbracelet/bubbletea"
 	"github.com/charmbracelet/glamour"
 	"github.com/charmbracelet/lipgloss"
+	"github.com/falbanese9484/terminal-chat/attachments"
+	"github.com/falbanese9484/terminal-chat/history"
+	"github.com/falbanese9484/terminal-chat/tokenizer"
+	"github.com/falbanese9484/terminal-chat/types"
+)
+
+const (
+	MinInputHeight = 3
+	MaxInputHeight = 12
 )
 
 type InputArea struct {
 	Textarea textarea.Model
-	renderer *glamour.TermRenderer
+	Hint     string
+	// Pending holds attachments, like !command output, waiting to go out with the next prompt.
+	Pending []*types.Attachment
+	History *history.History
+	// Searching is set while Ctrl+R reverse search is active.
+	Searching bool
+	renderer  *glamour.TermRenderer
+
+	// historyPos is the entry being shown while walking history, or -1.
+	historyPos  int
+	searchQuery string
+	searchPos   int
+	searchDraft string
+	// tokens caches the count TokenCount last made, and what it was made of.
+	tokens    int
+	tokensOf  string
+	tokenizer tokenizer.Tokenizer
 }
 
 func NewInputArea(renderer *glamour.TermRenderer) *InputArea {
@@ -16,14 +46,196 @@ func NewInputArea(renderer *glamour.TermRenderer) *InputArea {
 	ta.Placeholder = "Send a message..."
 	ta.Focus()
 	ta.Prompt = "| "
-	ta.SetHeight(3)
+	ta.SetHeight(MinInputHeight)
+	// Lines are unbounded, only the visible height is capped by Grow.
+	ta.MaxHeight = 0
 
-	ta.KeyMap.InsertNewline.SetEnabled(false)
+	// Enter sends, so newlines need a modifier. Most terminals report Shift+Enter as
+	// plain Enter, so Alt+Enter and Ctrl+J are the dependable ones.
+	ta.KeyMap.InsertNewline = key.NewBinding(key.WithKeys("alt+enter", "shift+enter", "ctrl+j"))
+	// Ctrl+E opens $EDITOR instead of jumping to the end of the line.
+	ta.KeyMap.LineEnd = key.NewBinding(key.WithKeys("end"))
+	// Ctrl+B toggles the sidebar instead of moving back a character.
+	ta.KeyMap.CharacterBackward = key.NewBinding(key.WithKeys("left"))
 	ta.FocusedStyle.CursorLine = lipgloss.NewStyle()
 	ta.ShowLineNumbers = false
 
 	return &InputArea{
-		Textarea: ta,
-		renderer: renderer,
+		Textarea:   ta,
+		renderer:   renderer,
+		historyPos: -1,
+	}
+}
+
+// CompleteMention tab-completes an @path at the end of the input. When more than one
+// file matches, the candidates are left in Hint for the view to show.
+func (i *InputArea) CompleteMention() bool {
+	value := i.Textarea.Value()
+	start := strings.LastIndexAny(value, " \n\t") + 1
+	word := value[start:]
+	if !strings.HasPrefix(word, "@") {
+		return false
+	}
+	completion, candidates := attachments.CompletePath(word[1:])
+	i.Textarea.SetValue(value[:start] + "@" + completion)
+	i.Textarea.CursorEnd()
+
+	i.Hint = ""
+	if len(candidates) > 1 {
+		names := make([]string, 0, len(candidates))
+		for _, c := range candidates {
+			names = append(names, filepath.Base(c)+trailingSlash(c))
+		}
+		i.Hint = strings.Join(names, "  ")
+	}
+	return true
+}
+
+// Grow resizes the textarea to fit its content, between MinInputHeight and
+// MaxInputHeight rows. It reports whether the height changed.
+func (i *InputArea) Grow() bool {
+	height := min(max(i.Textarea.LineCount(), MinInputHeight), MaxInputHeight)
+	if height == i.Textarea.Height() {
+		return false
+	}
+	i.Textarea.SetHeight(height)
+	return true
+}
+
+// TokenCount counts what the next prompt would send, attachments included. It only
+// counts again when the input changed.
+func (i *InputArea) TokenCount(tok tokenizer.Tokenizer) int {
+	text := attachments.Compose(i.Textarea.Value(), i.Pending)
+	if text != i.tokensOf || tok != i.tokenizer {
+		i.tokens, i.tokensOf, i.tokenizer = tok.Count(text), text, tok
+	}
+	return i.tokens
+}
+
+// TakePending returns the pending attachments and clears them.
+func (i *InputArea) TakePending() []*types.Attachment {
+	pending := i.Pending
+	i.Pending = nil
+	return pending
+}
+
+func trailingSlash(path string) string {
+	if strings.HasSuffix(path, "/") {
+		return "/"
+	}
+	return ""
+}
+
+// browsing reports whether the input still shows an unedited history entry.
+func (i *InputArea) browsing() bool {
+	return i.historyPos >= 0 && i.historyPos < len(i.History.Entries) &&
+		i.Textarea.Value() == i.History.Entries[i.historyPos]
+}
+
+// HistoryPrev shows the previous prompt. Walking starts only from an empty input, so
+// Up still moves the cursor in a multi-line draft.
+func (i *InputArea) HistoryPrev() bool {
+	if i.History == nil || len(i.History.Entries) == 0 {
+		return false
+	}
+	switch {
+	case i.browsing():
+		if i.historyPos == 0 {
+			return true
+		}
+		i.historyPos--
+	case i.Textarea.Value() == "":
+		i.historyPos = len(i.History.Entries) - 1
+	default:
+		i.historyPos = -1
+		return false
+	}
+	i.Textarea.SetValue(i.History.Entries[i.historyPos])
+	return true
+}
+
+// HistoryNext shows the next prompt, ending back on an empty input.
+func (i *InputArea) HistoryNext() bool {
+	if i.History == nil || !i.browsing() {
+		i.historyPos = -1
+		return false
+	}
+	i.historyPos++
+	if i.historyPos >= len(i.History.Entries) {
+		i.historyPos = -1
+		i.Textarea.Reset()
+		return true
+	}
+	i.Textarea.SetValue(i.History.Entries[i.historyPos])
+	return true
+}
+
+// Remember records a sent prompt and stops any history walk.
+func (i *InputArea) Remember(prompt string) error {
+	i.historyPos = -1
+	if i.History == nil {
+		return nil
+	}
+	return i.History.Add(prompt)
+}
+
+// StartSearch enters Ctrl+R reverse incremental search.
+func (i *InputArea) StartSearch() {
+	if i.History == nil {
+		return
+	}
+	i.Searching = true
+	i.searchQuery = ""
+	i.searchPos = -1
+	i.searchDraft = i.Textarea.Value()
+	i.updateSearchHint()
+}
+
+// SearchKey handles a key press during reverse search. Typing narrows the search,
+// Ctrl+R steps to older matches, Enter keeps the match and Esc restores the draft.
+func (i *InputArea) SearchKey(msg tea.KeyMsg) {
+	switch msg.Type {
+	case tea.KeyRunes, tea.KeySpace:
+		i.searchQuery += string(msg.Runes)
+		from := len(i.History.Entries)
+		if i.searchPos >= 0 {
+			from = i.searchPos + 1
+		}
+		i.searchPos = i.History.Search(i.searchQuery, from)
+	case tea.KeyBackspace:
+		if i.searchQuery != "" {
+			runes := []rune(i.searchQuery)
+			i.searchQuery = string(runes[:len(runes)-1])
+		}
+		i.searchPos = i.History.Search(i.searchQuery, len(i.History.Entries))
+	case tea.KeyCtrlR:
+		if i.searchPos > 0 {
+			if next := i.History.Search(i.searchQuery, i.searchPos); next >= 0 {
+				i.searchPos = next
+			}
+		}
+	case tea.KeyEscape, tea.KeyCtrlC, tea.KeyCtrlG:
+		i.Searching = false
+		i.Hint = ""
+		i.Textarea.SetValue(i.searchDraft)
+		return
+	default:
+		i.Searching = false
+		i.Hint = ""
+		return
+	}
+	if i.searchPos >= 0 {
+		i.Textarea.SetValue(i.History.Entries[i.searchPos])
+	} else {
+		i.Textarea.SetValue(i.searchDraft)
+	}
+	i.updateSearchHint()
+}
+
+func (i *InputArea) updateSearchHint() {
+	status := "reverse-i-search"
+	if i.searchQuery != "" && i.searchPos < 0 {
+		status = "failing reverse-i-search"
 	}
+	i.Hint = "(" + status + ")`" + i.searchQuery + "': Ctrl+R older, Enter keep, Esc cancel"
 }
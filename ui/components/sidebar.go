@@ -0,0 +1,131 @@
+package components
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/charmbracelet/lipgloss"
+)
+
+// SidebarWidth is the width of the sidebar including its border.
+const SidebarWidth = 30
+
+type SidebarEntry struct {
+	// A conversation in the sidebar. Tab is its position among the open tabs, or -1
+	// for a saved session that isn't open.
+	ID        string
+	Title     string
+	Tab       int
+	Active    bool
+	Streaming bool
+	Unread    bool
+}
+
+type Sidebar struct {
+	Entries  []SidebarEntry
+	Selected int
+	Height   int
+	Visible  bool
+	// Focused is set while the sidebar has the keyboard rather than the input.
+	Focused bool
+}
+
+func NewSidebar() *Sidebar {
+	return &Sidebar{}
+}
+
+// SetEntries replaces the list, keeping the cursor on the same conversation.
+func (s *Sidebar) SetEntries(entries []SidebarEntry) {
+	current := ""
+	if e, ok := s.Current(); ok {
+		current = e.ID
+	}
+	s.Entries = entries
+	s.Selected = min(s.Selected, max(len(entries)-1, 0))
+	for i, e := range entries {
+		if e.ID == current {
+			s.Selected = i
+		}
+	}
+}
+
+func (s *Sidebar) Current() (SidebarEntry, bool) {
+	if s.Selected < 0 || s.Selected >= len(s.Entries) {
+		return SidebarEntry{}, false
+	}
+	return s.Entries[s.Selected], true
+}
+
+func (s *Sidebar) Next() {
+	if s.Selected < len(s.Entries)-1 {
+		s.Selected++
+	}
+}
+
+func (s *Sidebar) Prev() {
+	if s.Selected > 0 {
+		s.Selected--
+	}
+}
+
+func (s *Sidebar) View() string {
+	width := SidebarWidth - 2
+	titleStyle := lipgloss.NewStyle().Bold(true).Foreground(lipgloss.Color("75"))
+	sectionStyle := lipgloss.NewStyle().Foreground(lipgloss.Color("241"))
+	activeStyle := lipgloss.NewStyle().Foreground(lipgloss.Color("10"))
+	selectedStyle := lipgloss.NewStyle().Background(lipgloss.Color("238"))
+
+	lines := []string{titleStyle.Render("Conversations")}
+	// Open tabs come first, then saved sessions.
+	section := ""
+	start := 0
+	if visible := s.Height - 3; visible > 0 && s.Selected >= visible {
+		start = s.Selected - visible + 1
+	}
+	for i := start; i < len(s.Entries); i++ {
+		e := s.Entries[i]
+		if name := sectionName(e); name != section {
+			section = name
+			lines = append(lines, sectionStyle.Render(name))
+		}
+		marker := " "
+		switch {
+		case e.Streaming:
+			marker = "●"
+		case e.Unread:
+			marker = "*"
+		}
+		label := e.Title
+		if e.Tab >= 0 {
+			label = fmt.Sprintf("%d %s", e.Tab+1, e.Title)
+		}
+		line := lipgloss.NewStyle().MaxWidth(width).Render(marker + " " + label)
+		if e.Active {
+			line = activeStyle.Render(line)
+		}
+		if i == s.Selected && s.Focused {
+			line = selectedStyle.Render(line)
+		}
+		lines = append(lines, line)
+	}
+	if s.Focused {
+		lines = append(lines, "", sectionStyle.Render("enter open · x close · esc back"))
+	}
+	if s.Height > 0 && len(lines) > s.Height {
+		lines = lines[:s.Height]
+	}
+	return lipgloss.NewStyle().
+		Width(SidebarWidth-1).
+		Height(max(s.Height, 1)).
+		Border(lipgloss.NormalBorder(), false, true, false, false).
+		BorderForeground(lipgloss.Color("240")).
+		PaddingRight(1).
+		Render(strings.Join(lines, "\n"))
+}
+
+func sectionName(e SidebarEntry) string {
+	if e.Tab >= 0 {
+		return "Open"
+	}
+	return "Saved"
+}
@@ -0,0 +1,679 @@
+package models
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"strconv"
+	"strings"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/falbanese9484/terminal-chat/attachments"
+	"github.com/falbanese9484/terminal-chat/clipboard"
+	"github.com/falbanese9484/terminal-chat/codeblocks"
+	"github.com/falbanese9484/terminal-chat/export"
+	"github.com/falbanese9484/terminal-chat/personas"
+	"github.com/falbanese9484/terminal-chat/search"
+	"github.com/falbanese9484/terminal-chat/types"
+	"github.com/falbanese9484/terminal-chat/ui/commands"
+	"github.com/falbanese9484/terminal-chat/ui/components"
+	"github.com/falbanese9484/terminal-chat/ui/styles"
+)
+
+type Command struct {
+	Name        string
+	Aliases     []string
+	Usage       string
+	Description string
+	// MinArgs and MaxArgs bound the number of arguments. A MaxArgs of -1 means unbounded.
+	MinArgs int
+	MaxArgs int
+	Run     func(m *ChatModel, in *commands.Input) tea.Cmd
+}
+
+// commandList is the single place slash commands are defined. The dispatcher, the
+// autocomplete popup and /help are all driven from it.
+func commandList() []*Command {
+	return []*Command{
+		{
+			Name:        "help",
+			Aliases:     []string{"?"},
+			Description: "List the available commands",
+			MaxArgs:     0,
+			Run:         runHelp,
+		},
+		{
+			Name:        "model",
+			Usage:       "[name]",
+			Description: "Switch model, or open the model picker",
+			MaxArgs:     1,
+			Run:         runModel,
+		},
+		{
+			Name:        "clear",
+			Description: "Start a new conversation",
+			MaxArgs:     0,
+			Run:         runClear,
+		},
+		{
+			Name:        "save",
+			Usage:       "[title]",
+			Description: "Save the conversation to the session store",
+			MaxArgs:     -1,
+			Run:         runSave,
+		},
+		{
+			Name:        "sessions",
+			Usage:       "[count]",
+			Description: "List saved and imported sessions, newest first",
+			MaxArgs:     1,
+			Run:         runSessions,
+		},
+		{
+			Name:        "search",
+			Usage:       "<query>",
+			Description: "Search every saved session",
+			MinArgs:     1,
+			MaxArgs:     -1,
+			Run:         runSearch,
+		},
+		{
+			Name:        "open",
+			Usage:       "<session|result>",
+			Description: "Continue a saved session, or open a /search result by number",
+			MinArgs:     1,
+			MaxArgs:     1,
+			Run:         runOpen,
+		},
+		{
+			Name:        "compare",
+			Usage:       "[off|model model [model] [model]]",
+			Description: "Send each prompt to 2-4 models side by side and pick a winner",
+			MaxArgs:     maxCompareModels,
+			Run:         runCompare,
+		},
+		{
+			Name:        "arena",
+			Usage:       "[off|model...]",
+			Description: "Blind A/B rounds between two random models, with a vote after each",
+			MaxArgs:     -1,
+			Run:         runArena,
+		},
+		{
+			Name:        "leaderboard",
+			Description: "Show the arena ratings",
+			MaxArgs:     0,
+			Run:         runLeaderboard,
+		},
+		{
+			Name:        "new",
+			Description: "Open a new conversation in another tab",
+			MaxArgs:     0,
+			Run:         runNew,
+		},
+		{
+			Name:        "tab",
+			Usage:       "[N|close]",
+			Description: "List the open tabs, switch to one (Alt+N) or close this one",
+			MaxArgs:     1,
+			Run:         runTab,
+		},
+		{
+			Name:        "sidebar",
+			Description: "Show or hide the conversation sidebar (Ctrl+B)",
+			MaxArgs:     0,
+			Run:         runSidebar,
+		},
+		{
+			Name:        "context",
+			Usage:       "[off|drop-oldest|keep-last [N]|summarize [model]]",
+			Description: "Show the context window use, or set how history is cut to fit",
+			MaxArgs:     2,
+			Run:         runContext,
+		},
+		{
+			Name:        "index",
+			Usage:       "[dir|off]",
+			Description: "Index a directory and answer questions from its files",
+			MaxArgs:     1,
+			Run:         runIndex,
+		},
+		{
+			Name:        "remember",
+			Usage:       "<fact>",
+			Description: "Keep a fact about you for every future conversation",
+			MinArgs:     1,
+			MaxArgs:     -1,
+			Run:         runRemember,
+		},
+		{
+			Name:        "memories",
+			Usage:       "[edit|forget N|clear|propose on|off]",
+			Description: "List, edit or forget what is remembered, or let the model suggest memories",
+			MaxArgs:     2,
+			Run:         runMemories,
+		},
+		{
+			Name:        "persona",
+			Usage:       "[name|off]",
+			Description: "List personas, or switch the conversation to one",
+			MaxArgs:     1,
+			Run:         runPersona,
+		},
+		{
+			Name:        "template",
+			Usage:       "[name]",
+			Description: "Fill in a prompt template and put it in the input",
+			MaxArgs:     1,
+			Run:         runTemplate,
+		},
+		{
+			Name:        "system",
+			Usage:       "[prompt|reset]",
+			Description: "Show or set the system prompt",
+			MaxArgs:     -1,
+			Run:         runSystem,
+		},
+		{
+			Name:        "temp",
+			Usage:       "[0-2|reset]",
+			Description: "Show or set the sampling temperature",
+			MaxArgs:     1,
+			Run:         runTemp,
+		},
+		{
+			Name:        "image",
+			Usage:       "<path>",
+			Description: "Attach an image to the next message",
+			MinArgs:     1,
+			MaxArgs:     1,
+			Run:         runImage,
+		},
+		{
+			Name:        "select",
+			Description: "Pick a message to copy, edit, delete or regenerate (Ctrl+S)",
+			MaxArgs:     0,
+			Run:         runSelect,
+		},
+		{
+			Name:        "code",
+			Usage:       "[copy N|save N path|pipe N command]",
+			Description: "List, copy, save or pipe the code blocks of the last response",
+			MaxArgs:     -1,
+			Run:         runCode,
+		},
+		{
+			Name:        "export",
+			Usage:       "[md|json|html] [path]",
+			Description: "Write the conversation to a Markdown, JSON or HTML file",
+			MaxArgs:     2,
+			Run:         runExport,
+		},
+	}
+}
+
+var (
+	// commandRegistry is filled in init since /help refers back to it.
+	commandRegistry *registry
+	errUsage        = errors.New("usage")
+)
+
+func init() {
+	commandRegistry = newCommandRegistry(commandList())
+}
+
+type registry struct {
+	commands []*Command
+	byName   map[string]*Command
+}
+
+func newCommandRegistry(cmds []*Command) *registry {
+	r := &registry{commands: cmds, byName: map[string]*Command{}}
+	for _, c := range cmds {
+		r.byName[c.Name] = c
+		for _, alias := range c.Aliases {
+			r.byName[alias] = c
+		}
+	}
+	return r
+}
+
+func (r *registry) Lookup(name string) (*Command, bool) {
+	c, ok := r.byName[name]
+	return c, ok
+}
+
+func (r *registry) Suggestions() []components.CommandSuggestion {
+	suggestions := make([]components.CommandSuggestion, 0, len(r.commands))
+	for _, c := range r.commands {
+		suggestions = append(suggestions, components.CommandSuggestion{
+			Name:        c.Name,
+			Usage:       c.Usage,
+			Description: c.Description,
+		})
+	}
+	return suggestions
+}
+
+// Help renders the command reference as a markdown table.
+func (r *registry) Help() string {
+	var sb strings.Builder
+	sb.WriteString("| Command | Description |\n|---|---|\n")
+	for _, c := range r.commands {
+		usage := "/" + c.Name
+		if c.Usage != "" {
+			usage += " " + c.Usage
+		}
+		fmt.Fprintf(&sb, "| `%s` | %s |\n", usage, c.Description)
+	}
+	sb.WriteString("\nStart a message with `//` to send a literal leading slash.\n")
+	return sb.String()
+}
+
+func usageError(c *Command) error {
+	usage := "/" + c.Name
+	if c.Usage != "" {
+		usage += " " + c.Usage
+	}
+	return fmt.Errorf("%w: %s", errUsage, usage)
+}
+
+// runCommand parses and dispatches a slash command typed into the input area.
+func runCommand(m *ChatModel, input string) tea.Cmd {
+	in, err := commands.Parse(input)
+	if err != nil {
+		addSystemError(m, err)
+		return nil
+	}
+	c, ok := commandRegistry.Lookup(in.Name)
+	if !ok {
+		addSystemError(m, fmt.Errorf("unknown command /%s, try /help", in.Name))
+		return nil
+	}
+	if len(in.Args) < c.MinArgs || (c.MaxArgs >= 0 && len(in.Args) > c.MaxArgs) {
+		addSystemError(m, usageError(c))
+		return nil
+	}
+	return c.Run(m, in)
+}
+
+func runHelp(m *ChatModel, in *commands.Input) tea.Cmd {
+	addSystemMarkdown(m, commandRegistry.Help())
+	return nil
+}
+
+func runModel(m *ChatModel, in *commands.Input) tea.Cmd {
+	if len(in.Args) == 0 {
+		openModelSelector(m)
+		return nil
+	}
+	return func() tea.Msg {
+		return components.ModelSelectedMsg{Name: in.Args[0]}
+	}
+}
+
+func runClear(m *ChatModel, in *commands.Input) tea.Cmd {
+	m.ChatService.Clear()
+	m.ChatView.Clear()
+	return nil
+}
+
+func runSave(m *ChatModel, in *commands.Input) tea.Cmd {
+	if len(m.ChatService.Session.Messages) == 0 {
+		addSystemError(m, errors.New("nothing to save yet"))
+		return nil
+	}
+	if in.Rest != "" {
+		m.ChatService.Session.Title = in.Rest
+	}
+	if err := m.ChatService.Save(); err != nil {
+		m.Logger.Error("failed to save session", "error", err)
+		addSystemError(m, err)
+		return nil
+	}
+	addSystemMessage(m, fmt.Sprintf("Saved session %s (%s)", m.ChatService.Session.ID, m.ChatService.Session.Title))
+	return nil
+}
+
+func runSessions(m *ChatModel, in *commands.Input) tea.Cmd {
+	count := 20
+	if len(in.Args) > 0 {
+		n, err := strconv.Atoi(in.Args[0])
+		if err != nil || n < 1 {
+			addSystemError(m, fmt.Errorf("expected a number of sessions, got %q", in.Args[0]))
+			return nil
+		}
+		count = n
+	}
+	list, errs := m.ChatService.Store.List()
+	for _, err := range errs {
+		m.Logger.Warn("failed to load session", "error", err)
+	}
+	if len(list) == 0 {
+		addSystemMessage(m, "No saved sessions yet")
+		return nil
+	}
+	var sb strings.Builder
+	sb.WriteString("| Session | Title | Messages | Updated |\n|---|---|---|---|\n")
+	for _, s := range list[:min(count, len(list))] {
+		title := strings.ReplaceAll(s.Title, "|", "\\|")
+		fmt.Fprintf(&sb, "| `%s` | %s | %d | %s |\n", s.ID, title, len(s.Messages), s.UpdatedAt.Format("2006-01-02 15:04"))
+	}
+	if len(list) > count {
+		fmt.Fprintf(&sb, "\n_%d more, use `/sessions %d` to see them._\n", len(list)-count, len(list))
+	}
+	sb.WriteString("\nContinue one with `/open <session>`; a unique prefix of the ID is enough.\n")
+	addSystemMarkdown(m, sb.String())
+	return nil
+}
+
+func runSearch(m *ChatModel, in *commands.Input) tea.Cmd {
+	idx, err := search.Open(m.ChatService.Store)
+	if err != nil {
+		m.Logger.Error("failed to open search index", "error", err)
+		addSystemError(m, err)
+		return nil
+	}
+	m.searchResults = idx.Search(in.Rest, maxSearchResults, func(s string) string { return "**" + s + "**" })
+	if len(m.searchResults) == 0 {
+		addSystemMessage(m, "No matches for "+in.Rest)
+		return nil
+	}
+	var sb strings.Builder
+	for i, r := range m.searchResults {
+		fmt.Fprintf(&sb, "%d. **%s** · %s · `%s`\n   %s\n", i+1, r.Title, r.Role, r.SessionID, r.Snippet)
+	}
+	sb.WriteString("\nJump to a match with `/open <number>`.\n")
+	addSystemMarkdown(m, sb.String())
+	return nil
+}
+
+func runOpen(m *ChatModel, in *commands.Input) tea.Cmd {
+	id, messageID := in.Args[0], ""
+	if n, err := strconv.Atoi(id); err == nil && n >= 1 && n <= len(m.searchResults) {
+		id, messageID = m.searchResults[n-1].SessionID, m.searchResults[n-1].MessageID
+	}
+	if err := openInTab(m, id, messageID); err != nil {
+		addSystemError(m, err)
+	}
+	return nil
+}
+
+func runNew(m *ChatModel, in *commands.Input) tea.Cmd {
+	newTab(m)
+	return nil
+}
+
+func runTab(m *ChatModel, in *commands.Input) tea.Cmd {
+	if len(in.Args) == 0 {
+		var sb strings.Builder
+		for i, t := range m.Tabs {
+			marker := ""
+			switch {
+			case i == m.ActiveTab:
+				marker = " _(this tab)_"
+			case t.ChatView.Streaming():
+				marker = " _(streaming)_"
+			case t.Unread:
+				marker = " _(unread)_"
+			}
+			fmt.Fprintf(&sb, "%d. %s · `%s`%s\n", i+1, tabTitle(t), t.ChatService.Session.ID, marker)
+		}
+		addSystemMarkdown(m, sb.String())
+		return nil
+	}
+	if in.Args[0] == "close" {
+		if err := closeTab(m, m.ActiveTab); err != nil {
+			addSystemError(m, err)
+		}
+		return nil
+	}
+	n, err := strconv.Atoi(in.Args[0])
+	if err != nil || n < 1 || n > len(m.Tabs) {
+		addSystemError(m, fmt.Errorf("expected a tab between 1 and %d, got %q", len(m.Tabs), in.Args[0]))
+		return nil
+	}
+	switchTab(m, n-1)
+	return nil
+}
+
+func runSidebar(m *ChatModel, in *commands.Input) tea.Cmd {
+	toggleSidebar(m)
+	return nil
+}
+
+// Open continues a stored session. When messageID is set that message is selected, so
+// it can be acted on straight away.
+func (m *ChatModel) Open(id, messageID string) error {
+	if m.ChatView.Streaming() {
+		return errors.New("wait for the response to finish before opening a session")
+	}
+	if err := m.ChatService.Open(id); err != nil {
+		return fmt.Errorf("failed to open session: %w", err)
+	}
+	m.editingID = ""
+	renderSession(m)
+	session := m.ChatService.Session
+	addSystemMessage(m, fmt.Sprintf("Opened %s (%s), continuing with %s", session.ID, session.Title, m.ChatService.ModelName))
+	if i := session.Index(messageID); i >= 0 {
+		enterSelectMode(m)
+		m.ChatView.Select(i)
+	}
+	return nil
+}
+
+func runSystem(m *ChatModel, in *commands.Input) tea.Cmd {
+	switch in.Rest {
+	case "":
+		if m.ChatService.Session.SystemPrompt == "" {
+			addSystemMessage(m, "No system prompt set")
+		} else {
+			addSystemMessage(m, "System prompt: "+m.ChatService.Session.SystemPrompt)
+		}
+	case "reset":
+		m.ChatService.Session.SystemPrompt = ""
+		addSystemMessage(m, "System prompt cleared")
+	default:
+		m.ChatService.Session.SystemPrompt = in.Rest
+		addSystemMessage(m, "System prompt set")
+	}
+	return nil
+}
+
+func runTemp(m *ChatModel, in *commands.Input) tea.Cmd {
+	if len(in.Args) == 0 {
+		if m.ChatService.Options.Temperature == nil {
+			addSystemMessage(m, "Temperature: provider default")
+		} else {
+			addSystemMessage(m, fmt.Sprintf("Temperature: %.2f", *m.ChatService.Options.Temperature))
+		}
+		return nil
+	}
+	if in.Args[0] == "reset" {
+		m.ChatService.Options.Temperature = nil
+		addSystemMessage(m, "Temperature reset to the provider default")
+		return nil
+	}
+	temp, err := strconv.ParseFloat(in.Args[0], 64)
+	if err != nil || temp < 0 || temp > 2 {
+		addSystemError(m, fmt.Errorf("temperature must be a number between 0 and 2, got %q", in.Args[0]))
+		return nil
+	}
+	m.ChatService.Options.Temperature = &temp
+	addSystemMessage(m, fmt.Sprintf("Temperature set to %.2f", temp))
+	return nil
+}
+
+func runImage(m *ChatModel, in *commands.Input) tea.Cmd {
+	if err := checkTool(m, personas.ToolFiles); err != nil {
+		addSystemError(m, err)
+		return nil
+	}
+	att, err := attachments.LoadImage(attachments.ExpandHome(in.Args[0]))
+	if err != nil {
+		addSystemError(m, fmt.Errorf("/image %s: %w", in.Args[0], err))
+		return nil
+	}
+	m.InputArea.Pending = append(m.InputArea.Pending, att)
+	return nil
+}
+
+func runExport(m *ChatModel, in *commands.Input) tea.Cmd {
+	session := m.ChatService.Session
+	if len(session.Messages) == 0 {
+		addSystemError(m, errors.New("nothing to export yet"))
+		return nil
+	}
+	args := in.Args
+	format := ""
+	if len(args) > 0 && export.IsFormat(args[0]) {
+		format, args = args[0], args[1:]
+	}
+	path := ""
+	switch {
+	case len(args) == 1:
+		path = attachments.ExpandHome(args[0])
+	case len(args) > 1:
+		c, _ := commandRegistry.Lookup("export")
+		addSystemError(m, usageError(c))
+		return nil
+	}
+	if format == "" {
+		format = export.FormatFor(path)
+	}
+	if path == "" {
+		path = session.ID + "." + format
+	}
+	data, err := export.Render(session, format)
+	if err == nil {
+		err = os.WriteFile(path, data, 0o644)
+	}
+	if err != nil {
+		addSystemError(m, fmt.Errorf("failed to export conversation: %w", err))
+		return nil
+	}
+	addSystemMessage(m, "Exported conversation to "+styles.HintStyle.Render(path))
+	return nil
+}
+
+func runSelect(m *ChatModel, in *commands.Input) tea.Cmd {
+	enterSelectMode(m)
+	return nil
+}
+
+// lastResponseBlocks returns the code blocks of the newest assistant message.
+func lastResponseBlocks(m *ChatModel) ([]codeblocks.Block, error) {
+	messages := m.ChatService.Session.Messages
+	for i := len(messages) - 1; i >= 0; i-- {
+		if messages[i].Role == types.RoleAssistant {
+			blocks := codeblocks.Extract(messages[i].Content)
+			if len(blocks) == 0 {
+				return nil, errors.New("the last response has no code blocks")
+			}
+			return blocks, nil
+		}
+	}
+	return nil, errors.New("no response yet")
+}
+
+func runCode(m *ChatModel, in *commands.Input) tea.Cmd {
+	blocks, err := lastResponseBlocks(m)
+	if err != nil {
+		addSystemError(m, err)
+		return nil
+	}
+	if len(in.Args) == 0 {
+		var sb strings.Builder
+		sb.WriteString("| # | Language | Lines | First line |\n|---|---|---|---|\n")
+		for i, b := range blocks {
+			first, _, _ := strings.Cut(strings.TrimSpace(b.Code), "\n")
+			fmt.Fprintf(&sb, "| %d | %s | %d | `%s` |\n", i+1, b.Language, strings.Count(b.Code, "\n")+1, strings.ReplaceAll(first, "|", "\\|"))
+		}
+		addSystemMarkdown(m, sb.String())
+		return nil
+	}
+	c, _ := commandRegistry.Lookup("code")
+	if len(in.Args) < 2 {
+		addSystemError(m, usageError(c))
+		return nil
+	}
+	n, err := strconv.Atoi(in.Args[1])
+	if err != nil || n < 1 || n > len(blocks) {
+		addSystemError(m, fmt.Errorf("no code block %q, the last response has %d", in.Args[1], len(blocks)))
+		return nil
+	}
+	block := blocks[n-1]
+	switch in.Args[0] {
+	case "copy":
+		if err := clipboard.Copy(block.Code); err != nil {
+			m.Logger.Error("failed to copy to clipboard", "error", err)
+			addSystemError(m, fmt.Errorf("failed to copy code block: %w", err))
+			return nil
+		}
+		addSystemMessage(m, fmt.Sprintf("Copied code block %d", n))
+	case "save":
+		if len(in.Args) != 3 {
+			addSystemError(m, usageError(c))
+			return nil
+		}
+		if err := checkTool(m, personas.ToolFiles); err != nil {
+			addSystemError(m, err)
+			return nil
+		}
+		saveCodeBlock(m, block, attachments.ExpandHome(in.Args[2]))
+	case "pipe":
+		line := in.After(2)
+		if line == "" {
+			addSystemError(m, usageError(c))
+			return nil
+		}
+		if err := checkTool(m, personas.ToolShell); err != nil {
+			addSystemError(m, err)
+			return nil
+		}
+		m.InputArea.Hint = "running " + line + "..."
+		return func() tea.Msg {
+			att := attachments.PipeCommand(context.Background(), line, block.Code, attachments.CommandTimeout)
+			att.Name = fmt.Sprintf("block %d | %s", n, line)
+			return commandOutputMsg(att)
+		}
+	default:
+		addSystemError(m, usageError(c))
+	}
+	return nil
+}
+
+// saveCodeBlock writes a block to path. An existing file is only replaced once the user
+// has seen the diff and said yes.
+func saveCodeBlock(m *ChatModel, block codeblocks.Block, path string) {
+	code := block.Code + "\n"
+	write := func(m *ChatModel) {
+		if err := os.WriteFile(path, []byte(code), 0o644); err != nil {
+			addSystemError(m, fmt.Errorf("failed to save code block: %w", err))
+			return
+		}
+		addSystemMessage(m, "Saved code block to "+styles.HintStyle.Render(path))
+	}
+	existing, err := os.ReadFile(path)
+	switch {
+	case errors.Is(err, fs.ErrNotExist):
+		write(m)
+		return
+	case err != nil:
+		addSystemError(m, fmt.Errorf("failed to read %s: %w", path, err))
+		return
+	}
+	diff := codeblocks.Diff(string(existing), code)
+	if diff == "" {
+		addSystemMessage(m, path+" already matches the code block")
+		return
+	}
+	fence := "```"
+	for strings.Contains(diff, fence) {
+		fence += "`"
+	}
+	addSystemMarkdown(m, fmt.Sprintf("%s exists, changes:\n\n%sdiff\n%s%s", path, fence, diff, fence))
+	askConfirm(m, "Overwrite "+path+"?", write)
+}
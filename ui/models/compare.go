@@ -0,0 +1,408 @@
+package models
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/falbanese9484/terminal-chat/arena"
+	"github.com/falbanese9484/terminal-chat/chat"
+	"github.com/falbanese9484/terminal-chat/types"
+	"github.com/falbanese9484/terminal-chat/ui/commands"
+	"github.com/falbanese9484/terminal-chat/ui/components"
+)
+
+const (
+	minCompareModels = 2
+	maxCompareModels = 4
+)
+
+type comparison struct {
+	// One prompt fanned out to several models. It belongs to the active tab; tabs
+	// can't be switched until a winner is picked or the comparison is cancelled.
+	// A blind comparison is an arena round: the models stay hidden until the vote.
+	runs  []*compareRun
+	view  *components.CompareView
+	blind bool
+}
+
+type compareRun struct {
+	// One model's side of a comparison. Spec is the model as given to /compare, with
+	// any provider prefix. FirstToken is the latency until the first text arrived and
+	// Elapsed the time until the stream ended.
+	spec       string
+	model      string
+	provider   *types.ProviderService
+	stream     *chat.Stream
+	started    time.Time
+	firstToken time.Duration
+	elapsed    time.Duration
+	response   string
+	usage      *types.Usage
+	done       bool
+	err        string
+	pane       *components.ComparePane
+}
+
+func (c *comparison) run(requestID string) *compareRun {
+	if c == nil {
+		return nil
+	}
+	i := slices.IndexFunc(c.runs, func(r *compareRun) bool { return r.stream.ID == requestID })
+	if i < 0 {
+		return nil
+	}
+	return c.runs[i]
+}
+
+func (c *comparison) finished() bool {
+	return !slices.ContainsFunc(c.runs, func(r *compareRun) bool { return !r.done })
+}
+
+func (c *comparison) cancel() {
+	for _, r := range c.runs {
+		r.stream.Cancel()
+	}
+}
+
+// stats formats what a pane shows under the model name.
+func (r *compareRun) stats() string {
+	if r.err != "" {
+		return r.err
+	}
+	parts := []string{}
+	if r.firstToken > 0 {
+		parts = append(parts, fmt.Sprintf("first token %.1fs", r.firstToken.Seconds()))
+	}
+	if r.done {
+		parts = append(parts, fmt.Sprintf("total %.1fs", r.elapsed.Seconds()))
+	} else {
+		parts = append(parts, "streaming...")
+	}
+	if r.usage != nil {
+		parts = append(parts, fmt.Sprintf("%d+%d tok", r.usage.PromptTokens, r.usage.CompletionTokens))
+		if r.usage.Cost != nil {
+			parts = append(parts, fmt.Sprintf("$%.4f", *r.usage.Cost))
+		}
+	}
+	return strings.Join(parts, " · ")
+}
+
+func (r *compareRun) refresh(blind bool) {
+	r.pane.Stats = r.stats()
+	if blind && r.err == "" {
+		// Timings and costs would give the model away.
+		r.pane.Stats = "streaming..."
+		if r.done {
+			r.pane.Stats = "done"
+		}
+	}
+	r.pane.Body = r.response
+	r.pane.Done = r.done
+	r.pane.Failed = r.err != ""
+}
+
+func runCompare(m *ChatModel, in *commands.Input) tea.Cmd {
+	switch {
+	case len(in.Args) == 0:
+		if len(m.compareModels) == 0 {
+			addSystemMessage(m, "Compare mode is off. Turn it on with /compare <model> <model> [model] [model]")
+		} else {
+			addSystemMessage(m, "Comparing "+strings.Join(m.compareModels, ", ")+". Turn it off with /compare off")
+		}
+		return nil
+	case len(in.Args) == 1 && in.Args[0] == "off":
+		m.compareModels = nil
+		addSystemMessage(m, "Compare mode off, continuing with "+m.ChatService.ModelName)
+		return nil
+	case len(in.Args) < minCompareModels || len(in.Args) > maxCompareModels:
+		addSystemError(m, fmt.Errorf("compare takes %d to %d models", minCompareModels, maxCompareModels))
+		return nil
+	}
+	if m.ChatService.Providers == nil {
+		addSystemError(m, errors.New("no providers are configured for compare mode"))
+		return nil
+	}
+	for _, spec := range in.Args {
+		if _, _, err := m.ChatService.Providers.Resolve(spec); err != nil {
+			addSystemError(m, err)
+			return nil
+		}
+	}
+	m.compareModels = in.Args
+	m.arenaModels = nil
+	addSystemMessage(m, fmt.Sprintf(
+		"Compare mode on: each prompt goes to %s. Providers: %s, prefix a model with one like openrouter:openai/gpt-4o",
+		strings.Join(m.compareModels, ", "), strings.Join(m.ChatService.Providers.Names(), ", ")))
+	return nil
+}
+
+// startComparison sends the conversation to every model in specs at once. Each stream
+// has its own wait outstanding, as with startGeneration.
+func startComparison(m *ChatModel, specs []string, blind bool) tea.Cmd {
+	c := &comparison{view: components.NewCompareView(m.ChatView.Style()), blind: blind}
+	cmds := []tea.Cmd{}
+	for i, spec := range specs {
+		provider, model, err := m.ChatService.Providers.Resolve(spec)
+		if err != nil {
+			addSystemError(m, err)
+			continue
+		}
+		request := m.ChatService.NewRequestFor(provider, model)
+		r := &compareRun{
+			spec:     spec,
+			model:    model,
+			provider: provider,
+			stream:   m.ChatService.Bus.RunChatOn(provider, m.ChatService.Session.ID, request),
+			started:  time.Now(),
+			pane:     &components.ComparePane{Title: spec},
+		}
+		if blind {
+			r.pane.Title = "Model " + string(rune('A'+i))
+		}
+		r.refresh(blind)
+		c.runs = append(c.runs, r)
+		c.view.Panes = append(c.view.Panes, r.pane)
+		cmds = append(cmds, waitForChatResponse(m.ChatService.ByteReader))
+	}
+	if len(c.runs) == 0 {
+		return nil
+	}
+	c.view.Hint = "streaming... · 1-4 pick a winner once it's done · esc cancel"
+	if blind {
+		c.view.Hint = "streaming... · vote once both are done · esc cancel"
+	}
+	m.compare = c
+	m.Mode = CompareMode
+	m.InputArea.Textarea.Blur()
+	return tea.Batch(cmds...)
+}
+
+func (m ChatModel) handleCompareResponse(r *compareRun, msg chatResponsemsg) (tea.Model, tea.Cmd) {
+	if msg.Response != "" {
+		if r.firstToken == 0 {
+			r.firstToken = time.Since(r.started)
+		}
+		r.response += msg.Response
+	}
+	if msg.Usage != nil {
+		r.usage = msg.Usage
+	}
+	var cmd tea.Cmd
+	if msg.Done {
+		r.done = true
+		r.elapsed = time.Since(r.started)
+		switch msg.Event {
+		case types.EventError:
+			r.err = "failed: " + msg.Error
+		case types.EventCancelled:
+			r.err = "cancelled"
+		}
+		if m.compare.finished() {
+			m.compare.view.Hint = "1-4 pick a winner to continue with · esc discard"
+			if m.compare.blind {
+				m.compare.view.Hint = "a/1 A is better · b/2 B is better · t tie · esc discard"
+			}
+		}
+	} else {
+		cmd = waitForChatResponse(m.ChatService.ByteReader)
+	}
+	r.refresh(m.compare.blind)
+	return m, cmd
+}
+
+// arenaVotes maps the keys that vote in a blind comparison to their outcome.
+var arenaVotes = map[string]arena.Outcome{
+	"a": arena.AWins, "1": arena.AWins,
+	"b": arena.BWins, "2": arena.BWins,
+	"t": arena.Tie, "=": arena.Tie,
+}
+
+func (m ChatModel) handleCompareKey(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
+	key := msg.String()
+	switch {
+	case key == "esc" || key == "q":
+		m.compare.cancel()
+		endComparison(&m)
+		addSystemMessage(&m, "Comparison discarded")
+	case key == "ctrl+c":
+		return m, tea.Quit
+	case m.compare.blind:
+		if outcome, ok := arenaVotes[key]; ok {
+			if err := vote(&m, outcome); err != nil {
+				m.compare.view.Hint = err.Error()
+			}
+		}
+	case len(msg.Runes) == 1 && msg.Runes[0] >= '1' && msg.Runes[0] <= '9':
+		if err := pickWinner(&m, int(msg.Runes[0]-'1')); err != nil {
+			m.compare.view.Hint = err.Error()
+		}
+	}
+	return m, nil
+}
+
+func endComparison(m *ChatModel) {
+	m.compare = nil
+	m.Mode = ChatMode
+	m.InputArea.Textarea.Focus()
+}
+
+// pickWinner continues the conversation with one of the answers, on that answer's
+// model. The other finished answers are kept as branches of the same prompt, so they
+// can still be looked at with Ctrl+S.
+func pickWinner(m *ChatModel, i int) error {
+	if i < 0 || i >= len(m.compare.runs) {
+		return fmt.Errorf("there is no answer %d", i+1)
+	}
+	winner := m.compare.runs[i]
+	if !winner.done || winner.err != "" || winner.response == "" {
+		return fmt.Errorf("answer %d isn't complete", i+1)
+	}
+	m.compare.cancel()
+	session := m.ChatService.Session
+	for _, r := range m.compare.runs {
+		if r == winner || !r.done || r.err != "" || r.response == "" {
+			continue
+		}
+		loser := session.Append(compareMessage(r))
+		session.TruncateFrom(loser.ID)
+	}
+	session.Append(compareMessage(winner))
+	m.ChatService.ModelProvider = winner.provider
+	m.ChatService.ModelName = winner.model
+	winner.provider.SetModel(winner.model)
+	endComparison(m)
+	renderSession(m)
+	addSystemMessage(m, "Continuing with "+winner.spec)
+	return nil
+}
+
+func compareMessage(r *compareRun) types.Message {
+	return types.Message{
+		Role:      types.RoleAssistant,
+		Content:   r.response,
+		Model:     r.model,
+		CreatedAt: time.Now(),
+		Usage:     r.usage,
+	}
+}
+
+func runArena(m *ChatModel, in *commands.Input) tea.Cmd {
+	if len(in.Args) == 1 && in.Args[0] == "off" {
+		m.arenaModels = nil
+		addSystemMessage(m, "Arena off, continuing with "+m.ChatService.ModelName)
+		return nil
+	}
+	if m.ChatService.Providers == nil {
+		addSystemError(m, errors.New("no providers are configured for the arena"))
+		return nil
+	}
+	candidates := in.Args
+	if len(candidates) == 0 {
+		// Every model the default provider can chat with, which for Ollama is what's
+		// pulled locally less the embedding models.
+		provider, ok := m.ChatService.Providers.Get(m.ChatService.Providers.Default)
+		if !ok {
+			addSystemError(m, errors.New("no default provider is configured"))
+			return nil
+		}
+		models, err := provider.RetrieveModels()
+		if err != nil {
+			m.Logger.Error("failed to load models..", "error", err)
+			addSystemError(m, fmt.Errorf("failed to list models: %w", err))
+			return nil
+		}
+		for _, model := range models {
+			if !model.EmbedOnly {
+				candidates = append(candidates, model.Name)
+			}
+		}
+	}
+	if len(candidates) < 2 {
+		addSystemError(m, errors.New("the arena needs at least two models"))
+		return nil
+	}
+	for _, spec := range candidates {
+		if _, _, err := m.ChatService.Providers.Resolve(spec); err != nil {
+			addSystemError(m, err)
+			return nil
+		}
+	}
+	m.arenaModels = candidates
+	m.compareModels = nil
+	addSystemMessage(m, fmt.Sprintf(
+		"Arena on: each prompt goes to two of %d models picked at random, shown as A and B until you vote. See the standings with /leaderboard",
+		len(candidates)))
+	return nil
+}
+
+// startArena runs a blind comparison between two random candidates.
+func startArena(m *ChatModel) tea.Cmd {
+	a, b, err := arena.Pair(m.arenaModels)
+	if err != nil {
+		addSystemError(m, err)
+		return nil
+	}
+	return startComparison(m, []string{a, b}, true)
+}
+
+// vote records the result of an arena round, reveals the models and continues with
+// the better answer, or with A on a tie.
+func vote(m *ChatModel, outcome arena.Outcome) error {
+	if !m.compare.finished() {
+		return errors.New("wait for both answers before voting")
+	}
+	// A model that couldn't be resolved never joined the round.
+	if len(m.compare.runs) != 2 {
+		return errors.New("this round is missing a model, esc to discard it")
+	}
+	a, b := m.compare.runs[0], m.compare.runs[1]
+	if a.err != "" || b.err != "" || a.response == "" || b.response == "" {
+		return errors.New("one of the answers didn't finish, esc to discard the round")
+	}
+	board, err := arena.LoadDefault()
+	if err != nil {
+		m.Logger.Error("failed to load leaderboard", "error", err)
+		return err
+	}
+	board.Record(a.spec, b.spec, outcome)
+	if err := board.Save(); err != nil {
+		m.Logger.Error("failed to save leaderboard", "error", err)
+		return err
+	}
+	winner := 0
+	if outcome == arena.BWins {
+		winner = 1
+	}
+	if err := pickWinner(m, winner); err != nil {
+		return err
+	}
+	addSystemMessage(m, fmt.Sprintf("A was %s (now %.0f), B was %s (now %.0f)",
+		a.spec, board.Ratings[a.spec].Rating, b.spec, board.Ratings[b.spec].Rating))
+	return nil
+}
+
+func runLeaderboard(m *ChatModel, in *commands.Input) tea.Cmd {
+	board, err := arena.LoadDefault()
+	if err != nil {
+		m.Logger.Error("failed to load leaderboard", "error", err)
+		addSystemError(m, err)
+		return nil
+	}
+	ranked := board.Ranked()
+	if len(ranked) == 0 {
+		addSystemMessage(m, "No arena votes yet, start with /arena")
+		return nil
+	}
+	var sb strings.Builder
+	sb.WriteString("| # | Model | Rating | W | L | T |\n|---|---|---|---|---|---|\n")
+	for i, r := range ranked {
+		fmt.Fprintf(&sb, "| %d | %s | %.0f | %d | %d | %d |\n", i+1, r.Model, r.Rating, r.Wins, r.Losses, r.Ties)
+	}
+	fmt.Fprintf(&sb, "\n_%d votes in total._\n", len(board.Votes))
+	addSystemMarkdown(m, sb.String())
+	return nil
+}
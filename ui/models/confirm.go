@@ -0,0 +1,57 @@
+package models
+
+import (
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+type confirmation struct {
+	// A yes/no question asked in the hint line. accept runs if the answer is y, and
+	// reject, if set, on any other answer.
+	prompt string
+	accept func(m *ChatModel) tea.Cmd
+	reject func(m *ChatModel)
+}
+
+// askConfirm puts the UI in ConfirmMode until the next key press answers the question.
+func askConfirm(m *ChatModel, prompt string, accept func(m *ChatModel)) {
+	askConfirmOr(m, prompt, accept, nil)
+}
+
+// askConfirmOr is askConfirm with something to do when the answer is no.
+func askConfirmOr(m *ChatModel, prompt string, accept, reject func(m *ChatModel)) {
+	askConfirmCmd(m, prompt, func(m *ChatModel) tea.Cmd {
+		accept(m)
+		return nil
+	}, reject)
+}
+
+// askConfirmCmd is askConfirmOr for answers that start work in the background, such as
+// running a command.
+func askConfirmCmd(m *ChatModel, prompt string, accept func(m *ChatModel) tea.Cmd, reject func(m *ChatModel)) {
+	m.confirm = &confirmation{prompt: prompt, accept: accept, reject: reject}
+	m.Mode = ConfirmMode
+	m.InputArea.Hint = prompt + " [y/N]"
+}
+
+func (m ChatModel) handleConfirmKey(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
+	c := m.confirm
+	m.confirm = nil
+	m.Mode = ChatMode
+	m.InputArea.Hint = ""
+	if msg.String() == "ctrl+c" {
+		return m, tea.Quit
+	}
+	if c == nil {
+		return m, nil
+	}
+	if msg.String() == "y" || msg.String() == "Y" {
+		cmd := c.accept(&m)
+		return m, cmd
+	}
+	if c.reject != nil {
+		c.reject(&m)
+		return m, nil
+	}
+	addSystemMessage(&m, "Cancelled")
+	return m, nil
+}
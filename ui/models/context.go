@@ -0,0 +1,195 @@
+package models
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/falbanese9484/terminal-chat/compaction"
+	"github.com/falbanese9484/terminal-chat/sessions"
+	"github.com/falbanese9484/terminal-chat/tokenizer"
+	"github.com/falbanese9484/terminal-chat/types"
+	"github.com/falbanese9484/terminal-chat/ui/commands"
+	"github.com/falbanese9484/terminal-chat/ui/styles"
+)
+
+type pendingSummary struct {
+	// A summary of the start of a conversation being streamed. UpTo is the last
+	// message it covers, and more is set when later dropped messages didn't fit in
+	// the request and need another round.
+	upTo string
+	more bool
+	text strings.Builder
+}
+
+// summarizeHistory streams a summary of the messages that no longer fit before the
+// response is generated. It runs on the bus like a response, so Esc cancels it.
+func summarizeHistory(m *ChatModel, t *Tab, dropped []types.Message) tea.Cmd {
+	cs := t.ChatService
+	provider, model := cs.ModelProvider, cs.ModelName
+	if spec := cs.Context.SummaryModel; spec != "" && cs.Providers != nil {
+		p, name, err := cs.Providers.Resolve(spec)
+		if err != nil {
+			m.Logger.Warn("failed to resolve summary model, using the chat model", "model", spec, "error", err)
+		} else {
+			provider, model = p, name
+		}
+	}
+	previous := ""
+	if cs.Session.Summary != nil {
+		previous = cs.Session.Summary.Text
+	}
+	length := cs.ContextLength(provider, model)
+	request, n := compaction.SummaryRequest(tokenizer.For(model), provider, model, previous, dropped, compaction.Budget(length))
+	window := compaction.Window(length)
+	request.Options.ContextLength = &window
+	cs.Cancel()
+	t.summary = &pendingSummary{upTo: dropped[n-1].ID, more: n < len(dropped)}
+	cs.Stream = cs.Bus.RunChatOn(provider, cs.Session.ID, request)
+	m.InputArea.Hint = fmt.Sprintf("summarizing %d older messages with %s... · esc cancel", n, model)
+	refreshSidebar(m, false)
+	return waitForChatResponse(cs.ByteReader)
+}
+
+// handleSummaryResponse collects the summary and, once it is written, stores it and
+// goes on with the response. If summarizing failed the oldest messages are dropped
+// instead; if it was cancelled nothing is sent.
+func (m ChatModel) handleSummaryResponse(t *Tab, msg chatResponsemsg) (tea.Model, tea.Cmd) {
+	cs := t.ChatService
+	s := t.summary
+	s.text.WriteString(msg.Response)
+	if !msg.Done {
+		return m, waitForChatResponse(cs.ByteReader)
+	}
+	t.summary = nil
+	cs.Stream = nil
+	m.InputArea.Hint = ""
+	text := strings.TrimSpace(s.text.String())
+	switch {
+	case msg.Event == types.EventCancelled:
+		t.ChatView.Append(formatMessage("System", styles.HintStyle.Render("Summary cancelled, nothing was sent"), styles.AiStyle))
+		refreshSidebar(&m, false)
+		return m, nil
+	case msg.Event == types.EventError || text == "":
+		reason := msg.Error
+		if reason == "" {
+			reason = "the model returned nothing"
+		}
+		m.Logger.Error("failed to summarize history", "error", reason)
+		t.ChatView.Append(formatMessage("System", "Couldn't summarize older messages, dropping them instead: "+reason, styles.AiStyle))
+	default:
+		cs.Session.Summary = &sessions.Summary{UpTo: s.upTo, Text: text}
+		if s.more {
+			// The summary now covers part of what was dropped; the next round takes
+			// on the rest.
+			return m, generate(&m, t, true)
+		}
+	}
+	return m, generate(&m, t, false)
+}
+
+// formatCompaction is the status line shown while the history sent to the model is cut
+// down, with the estimated prompt size.
+func formatCompaction(c *compaction.Result) string {
+	parts := []string{}
+	if c.Summarized > 0 {
+		parts = append(parts, fmt.Sprintf("%d summarized", c.Summarized))
+	}
+	if len(c.Dropped) > 0 {
+		parts = append(parts, fmt.Sprintf("%d dropped", len(c.Dropped)))
+	}
+	return fmt.Sprintf("history compacted: %s · %s/%s tokens",
+		strings.Join(parts, ", "), approx(c.Exact)+formatTokens(c.Tokens), formatTokens(c.Budget))
+}
+
+// formatTokenCount is the live counter for what's being typed.
+func formatTokenCount(n int, tok tokenizer.Tokenizer) string {
+	return fmt.Sprintf("%s%s tokens (%s)", approx(tok.Exact()), formatTokens(n), tok.Name())
+}
+
+func approx(exact bool) string {
+	if exact {
+		return ""
+	}
+	return "~"
+}
+
+func formatTokens(n int) string {
+	if n < 1000 {
+		return strconv.Itoa(n)
+	}
+	return fmt.Sprintf("%.1fk", float64(n)/1000)
+}
+
+func runContext(m *ChatModel, in *commands.Input) tea.Cmd {
+	cs := m.ChatService
+	if len(in.Args) == 0 {
+		_, result := cs.ContextFor(cs.ModelProvider, cs.ModelName)
+		length := cs.ContextLength(cs.ModelProvider, cs.ModelName)
+		known := formatTokens(length)
+		if length == 0 {
+			known = fmt.Sprintf("unknown, assuming %s", formatTokens(compaction.DefaultContextLength))
+		}
+		var sb strings.Builder
+		fmt.Fprintf(&sb, "| | |\n|---|---|\n")
+		fmt.Fprintf(&sb, "| Strategy | %s |\n", describeStrategy(cs.Context))
+		fmt.Fprintf(&sb, "| Context window | %s |\n", known)
+		fmt.Fprintf(&sb, "| Next request | %s%s of %s tokens, %d messages |\n",
+			approx(result.Exact), formatTokens(result.Tokens), formatTokens(result.Budget), len(result.Messages))
+		fmt.Fprintf(&sb, "| Tokenizer | %s |\n", tokenizer.For(cs.ModelName).Name())
+		if result.Compacted() {
+			fmt.Fprintf(&sb, "| Left out | %d summarized, %d dropped |\n", result.Summarized, len(result.Dropped))
+		}
+		fmt.Fprintf(&sb, "\nChange it with `/context off|drop-oldest|keep-last [N]|summarize [model]`.\n")
+		addSystemMarkdown(m, sb.String())
+		return nil
+	}
+	strategy, err := compaction.ParseStrategy(in.Args[0])
+	if err != nil {
+		addSystemError(m, err)
+		return nil
+	}
+	opts := cs.Context
+	opts.Strategy = strategy
+	switch {
+	case len(in.Args) == 1:
+	case strategy == compaction.KeepLast:
+		n, err := strconv.Atoi(in.Args[1])
+		if err != nil || n < 1 {
+			addSystemError(m, fmt.Errorf("expected a number of messages, got %q", in.Args[1]))
+			return nil
+		}
+		opts.KeepLast = n
+	case strategy == compaction.Summarize:
+		if cs.Providers != nil {
+			if _, _, err := cs.Providers.Resolve(in.Args[1]); err != nil {
+				addSystemError(m, err)
+				return nil
+			}
+		}
+		opts.SummaryModel = in.Args[1]
+	default:
+		addSystemError(m, errors.New(string(strategy)+" takes no argument"))
+		return nil
+	}
+	cs.Context = opts
+	addSystemMessage(m, "Context strategy: "+describeStrategy(opts))
+	return nil
+}
+
+func describeStrategy(opts compaction.Options) string {
+	switch opts.Strategy {
+	case compaction.KeepLast:
+		return fmt.Sprintf("keep-last %d", opts.KeepLast)
+	case compaction.Summarize:
+		if opts.SummaryModel != "" {
+			return "summarize with " + opts.SummaryModel
+		}
+		return "summarize"
+	case "":
+		return string(compaction.DropOldest)
+	}
+	return string(opts.Strategy)
+}
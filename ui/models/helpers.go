@@ -1,31 +1,129 @@
 package models
 
 import (
+	"context"
 	"fmt"
 	"strings"
 	"time"
 
 	tea "github.com/charmbracelet/bubbletea"
 	"github.com/charmbracelet/lipgloss"
+	"github.com/falbanese9484/terminal-chat/attachments"
 	"github.com/falbanese9484/terminal-chat/types"
 	"github.com/falbanese9484/terminal-chat/ui/styles"
 )
 
 func formatMessage(sender, content string, style lipgloss.Style) string {
-	timestamp := time.Now().Format("15:04")
+	return formatMessageAt(sender, content, style, time.Now())
+}
+
+// formatMessageAt is formatMessage for a message sent at t, used when replaying history.
+func formatMessageAt(sender, content string, style lipgloss.Style, t time.Time) string {
+	timestamp := t.Format("15:04")
 	prefix := style.Render(fmt.Sprintf("[%s] %s:", timestamp, sender))
 	return prefix + " " + content
 }
 
-func setAIResponse(m *ChatModel, msg *types.ChatResponse) {
-	m.ChatService.CurrentAIResponse += msg.Response
-	renderedText, _ := m.Renderer.Render(m.ChatService.CurrentAIResponse)
-	allMessages := append(m.ChatView.Messages, formatMessage(m.ChatService.ModelName, renderedText, styles.AiStyle))
-	m.ChatView.Viewport.SetContent(
-		lipgloss.NewStyle().Width(
-			m.ChatView.Viewport.Width).Render(
-			strings.Join(allMessages, "\n")))
-	m.ChatView.Viewport.GotoBottom()
+// formatUserMessage renders the prompt as typed, followed by a collapsed chip for each
+// attachment rather than the attached content itself.
+func formatUserMessage(prompt string, atts []*types.Attachment) string {
+	msg := styles.UserStyle.Render("You: ") + prompt
+	if len(atts) == 0 {
+		return msg
+	}
+	return msg + "\n" + formatChips(atts)
+}
+
+// formatBranch shows which of n versions of a message is on screen.
+func formatBranch(i, n int) string {
+	if n < 2 {
+		return ""
+	}
+	return styles.HintStyle.Render(fmt.Sprintf("< %d/%d >", i, n))
+}
+
+func formatChips(atts []*types.Attachment) string {
+	chips := make([]string, 0, len(atts))
+	for _, att := range atts {
+		icon := "@"
+		switch att.Kind {
+		case types.AttachmentCommand:
+			icon = "$"
+		case types.AttachmentImage:
+			icon = "[img]"
+		}
+		chips = append(chips, styles.AttachmentStyle.Render(icon+" "+attachments.Label(att)))
+	}
+	return strings.Join(chips, " ")
+}
+
+// formatCommandPreview shows the head of captured command output so the user can check
+// it before it goes out with their next prompt.
+func formatCommandPreview(att *types.Attachment) string {
+	lines := strings.Split(strings.TrimRight(att.Content, "\n"), "\n")
+	if len(lines) > previewLines {
+		lines = append(lines[:previewLines], fmt.Sprintf("... %d more lines", len(lines)-previewLines))
+	}
+	preview := *att
+	preview.Content = strings.Join(lines, "\n")
+	return attachments.Fence(&preview) + "\n\n_Attached to your next message. Press Esc to discard._"
+}
+
+func addSystemMessage(m *ChatModel, text string) {
+	m.ChatView.Append(formatMessage("System", text, styles.AiStyle))
+}
+
+func addSystemMarkdown(m *ChatModel, markdown string) {
+	m.ChatView.AppendMarkdown(formatMessage("System", "", styles.AiStyle), markdown)
+}
+
+func openModelSelector(m *ChatModel) {
+	m.Mode = ModelSelectMode
+	models, err := m.ChatService.ModelProvider.RetrieveModels()
+	if err != nil {
+		m.Logger.Error("failed to load models..", "error", err)
+	}
+	m.ModelSelector.SetModels(models)
+	m.ModelSelector.Toggle()
+}
+
+func addSystemError(m *ChatModel, err error) {
+	m.ChatView.Append(formatMessage("System", styles.ErrorStyle.Render(err.Error()), styles.AiStyle))
+}
+
+// checkModality refuses input the current model can't take. If the model list can't be
+// fetched the check is skipped and the provider gets the final say.
+func checkModality(m *ChatModel, modality string) error {
+	model, ok, err := m.ChatService.ModelProvider.FindModel(m.ChatService.ModelName)
+	if err != nil {
+		m.Logger.Warn("failed to look up model modalities", "model", m.ChatService.ModelName, "error", err)
+		return nil
+	}
+	if ok && !model.Supports(modality) {
+		return fmt.Errorf("%s does not accept %s input, switch to a model that does with Ctrl+F", model.Name, modality)
+	}
+	return nil
+}
+
+// checkTool refuses a tool the conversation's persona isn't allowed.
+func checkTool(m *ChatModel, tool string) error {
+	if p := m.ChatService.Persona; !p.Allows(tool) {
+		return fmt.Errorf("the %s persona isn't allowed the %s tool", p.Name, tool)
+	}
+	return nil
+}
+
+func runShellCommand(input string) tea.Cmd {
+	return func() tea.Msg {
+		return commandOutputMsg(attachments.RunCommand(context.Background(), input, attachments.CommandTimeout))
+	}
+}
+
+// frameTick schedules the next repaint of a streaming response.
+func frameTick() tea.Cmd {
+	return tea.Tick(frameInterval, func(time.Time) tea.Msg {
+		return frameMsg{}
+	})
 }
 
 func waitForChatResponse(sub chan *types.ChatResponse) tea.Cmd {
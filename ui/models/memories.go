@@ -0,0 +1,162 @@
+package models
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/falbanese9484/terminal-chat/memory"
+	"github.com/falbanese9484/terminal-chat/tokenizer"
+	"github.com/falbanese9484/terminal-chat/ui/commands"
+	"github.com/falbanese9484/terminal-chat/ui/components"
+)
+
+var errNoMemory = errors.New("memories aren't available, see the log for why")
+
+func runRemember(m *ChatModel, in *commands.Input) tea.Cmd {
+	store := m.ChatService.Memory
+	if store == nil {
+		addSystemError(m, errNoMemory)
+		return nil
+	}
+	added, err := store.Add(in.Rest, memory.SourceUser)
+	switch {
+	case err != nil:
+		m.Logger.Error("failed to save memory", "error", err)
+		addSystemError(m, err)
+	case !added:
+		addSystemMessage(m, "Already remembered")
+	default:
+		addSystemMessage(m, "Remembered: "+strings.TrimSpace(in.Rest))
+	}
+	return nil
+}
+
+func runMemories(m *ChatModel, in *commands.Input) tea.Cmd {
+	store := m.ChatService.Memory
+	if store == nil {
+		addSystemError(m, errNoMemory)
+		return nil
+	}
+	c, _ := commandRegistry.Lookup("memories")
+	if len(in.Args) == 0 {
+		addSystemMarkdown(m, formatMemories(m, store))
+		return nil
+	}
+	switch in.Args[0] {
+	case "edit":
+		m.editingMemories = true
+		return components.OpenEditor(store.Text())
+	case "forget":
+		if len(in.Args) != 2 {
+			addSystemError(m, usageError(c))
+			return nil
+		}
+		n, err := strconv.Atoi(in.Args[1])
+		if err != nil {
+			addSystemError(m, fmt.Errorf("expected a memory number, got %q", in.Args[1]))
+			return nil
+		}
+		text := ""
+		if n >= 1 && n <= len(store.Memories) {
+			text = store.Memories[n-1].Text
+		}
+		if err := store.Remove(n - 1); err != nil {
+			addSystemError(m, err)
+			return nil
+		}
+		addSystemMessage(m, "Forgot: "+text)
+	case "clear":
+		askConfirm(m, fmt.Sprintf("Forget all %d memories?", len(store.Memories)), func(m *ChatModel) {
+			if err := store.Replace(""); err != nil {
+				addSystemError(m, err)
+				return
+			}
+			addSystemMessage(m, "Forgot everything")
+		})
+	case "propose":
+		if len(in.Args) != 2 || (in.Args[1] != "on" && in.Args[1] != "off") {
+			addSystemError(m, usageError(c))
+			return nil
+		}
+		for _, t := range m.Tabs {
+			t.ChatService.ProposeMemories = in.Args[1] == "on"
+		}
+		if in.Args[1] == "on" {
+			addSystemMessage(m, "The model may now suggest memories, each kept only if you say yes")
+		} else {
+			addSystemMessage(m, "The model no longer suggests memories")
+		}
+	default:
+		addSystemError(m, usageError(c))
+	}
+	return nil
+}
+
+func formatMemories(m *ChatModel, store *memory.Store) string {
+	if len(store.Memories) == 0 {
+		return "Nothing remembered yet. Add facts with `/remember`."
+	}
+	var sb strings.Builder
+	sb.WriteString("| # | Memory | Added |\n|---|---|---|\n")
+	for i, mem := range store.Memories {
+		added := mem.CreatedAt.Format("2006-01-02")
+		if mem.Source == memory.SourceModel {
+			added += ", suggested"
+		}
+		fmt.Fprintf(&sb, "| %d | %s | %s |\n", i+1, strings.ReplaceAll(mem.Text, "|", "\\|"), added)
+	}
+	_, n := store.Prompt(tokenizer.For(m.ChatService.ModelName), memory.Budget())
+	if n < len(store.Memories) {
+		fmt.Fprintf(&sb, "\nOnly the newest %d fit the memory budget of %d tokens.\n", n, memory.Budget())
+	}
+	sb.WriteString("\n`/memories edit` opens them in your editor, `/memories forget N` drops one.")
+	if m.ChatService.ProposeMemories {
+		sb.WriteString(" The model suggests new ones; `/memories propose off` stops that.")
+	}
+	sb.WriteString("\n")
+	return sb.String()
+}
+
+// handleMemoriesEdited replaces the memories with what was left in the editor.
+func (m ChatModel) handleMemoriesEdited(msg components.EditorClosedMsg) (tea.Model, tea.Cmd) {
+	m.editingMemories = false
+	if msg.Err != nil {
+		m.Logger.Error("editor exited with an error", "error", msg.Err)
+		addSystemError(&m, fmt.Errorf("editor: %w", msg.Err))
+		return m, nil
+	}
+	if err := m.ChatService.Memory.Replace(msg.Content); err != nil {
+		m.Logger.Error("failed to save memories", "error", err)
+		addSystemError(&m, err)
+		return m, nil
+	}
+	addSystemMessage(&m, fmt.Sprintf("Saved %d memories", len(m.ChatService.Memory.Memories)))
+	return m, nil
+}
+
+// proposeMemories asks about each memory the model suggested in turn. If the UI is busy
+// with something else they are only listed, to be kept with /remember.
+func proposeMemories(m *ChatModel, store *memory.Store, proposed []string) {
+	if len(proposed) == 0 {
+		return
+	}
+	if m.Mode != ChatMode {
+		for _, text := range proposed {
+			addSystemMessage(m, "Suggested memory: "+text+" (keep it with /remember)")
+		}
+		return
+	}
+	next := func(m *ChatModel) { proposeMemories(m, store, proposed[1:]) }
+	askConfirmOr(m, fmt.Sprintf("Remember %q?", proposed[0]), func(m *ChatModel) {
+		if _, err := store.Add(proposed[0], memory.SourceModel); err != nil {
+			m.Logger.Error("failed to save memory", "error", err)
+			addSystemError(m, err)
+		} else {
+			addSystemMessage(m, "Remembered: "+proposed[0])
+		}
+		next(m)
+	}, next)
+}
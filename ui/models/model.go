@@ -2,39 +2,92 @@ package models
 
 import (
 	"fmt"
+	"strings"
+	"time"
 
 	"github.com/charmbracelet/bubbles/textarea"
 	tea "github.com/charmbracelet/bubbletea"
 	"github.com/charmbracelet/glamour"
 	"github.com/charmbracelet/lipgloss"
+	"github.com/falbanese9484/terminal-chat/attachments"
 	"github.com/falbanese9484/terminal-chat/logger"
+	"github.com/falbanese9484/terminal-chat/memory"
+	"github.com/falbanese9484/terminal-chat/personas"
+	"github.com/falbanese9484/terminal-chat/search"
+	"github.com/falbanese9484/terminal-chat/tokenizer"
 	"github.com/falbanese9484/terminal-chat/types"
+	"github.com/falbanese9484/terminal-chat/ui/commands"
 	"github.com/falbanese9484/terminal-chat/ui/components"
 	"github.com/falbanese9484/terminal-chat/ui/services"
 	"github.com/falbanese9484/terminal-chat/ui/styles"
 )
 
 type (
-	chatResponsemsg *types.ChatResponse
-	errMsg          error
-	UIMode          int
+	chatResponsemsg  *types.ChatResponse
+	commandOutputMsg *types.Attachment
+	frameMsg         struct{}
+	errMsg           error
+	UIMode           int
+)
+
+const (
+	gap          = "\n\n"
+	previewLines = 20
+	// maxSearchResults is how many matches /search lists.
+	maxSearchResults = 10
+	// frameInterval caps how often a streaming response is repainted.
+	frameInterval = time.Second / 30
 )
 
 const (
-	gap             = "\n\n"
 	ChatMode UIMode = iota
 	ModelSelectMode
+	SelectMode
+	ConfirmMode
+	SidebarMode
+	CompareMode
+	TemplateMode
 )
 
 type ChatModel struct {
 	InputArea     *components.InputArea
 	ChatView      *components.ChatView
 	ModelSelector *components.ModelSelector
+	CommandPopup  *components.CommandPopup
 	ChatService   *services.ChatService
-	Logger        *logger.Logger
-	Renderer      *glamour.TermRenderer
-	Err           error
-	Mode          UIMode
+	// Tabs are the open conversations. ChatService and ChatView above belong to the
+	// active one; the others keep streaming in the background.
+	Tabs      []*Tab
+	ActiveTab int
+	Sidebar   *components.Sidebar
+	Logger    *logger.Logger
+	Renderer  *glamour.TermRenderer
+	Err       error
+	Mode      UIMode
+	// Height is the terminal height from the last resize, kept so the chat can give up
+	// rows as the input grows. Width is what the chat and input get with no sidebar.
+	Height int
+	Width  int
+	// framePending is set while a repaint tick is scheduled.
+	framePending bool
+	// editingID is the user message being rewritten; sending replaces it and what follows.
+	editingID string
+	// confirm is the question being asked in ConfirmMode.
+	confirm *confirmation
+	// searchResults are the matches from the last /search, for /open N.
+	searchResults []search.Result
+	// compareModels are the models each prompt goes to in compare mode, and compare
+	// is the comparison on screen.
+	compareModels []string
+	compare       *comparison
+	// arenaModels are the candidates for blind arena rounds, when the arena is on.
+	arenaModels []string
+	// editingMemories is set while /memories edit has the editor open.
+	editingMemories bool
+	// template is the template being picked or filled in, in TemplateMode.
+	template *templateFill
+	// saved caches the stored sessions listed in the sidebar.
+	saved []components.SidebarEntry
 }
 
 func (m ChatModel) Init() tea.Cmd {
@@ -46,17 +99,64 @@ func (m ChatModel) handleChatResponse(msg chatResponsemsg) (tea.Model, tea.Cmd)
 		m.Logger.Debug("UI:channel closed without a final message")
 		return m, nil
 	}
+	if r := m.compare.run(msg.RequestID); r != nil {
+		return m.handleCompareResponse(r, msg)
+	}
+	t := m.tabFor(msg.SessionID)
+	if t == nil || t.ChatService.Stream == nil || t.ChatService.Stream.ID != msg.RequestID {
+		// The tab was closed or the request replaced, so the response is stale.
+		m.Logger.Debug("UI:dropping stale response", "request", msg.RequestID, "event", msg.Event)
+		if !msg.Done {
+			return m, waitForChatResponse(m.ChatService.ByteReader)
+		}
+		return m, nil
+	}
+	if t.summary != nil {
+		return m.handleSummaryResponse(t, msg)
+	}
+	cs := t.ChatService
+	if msg.Usage != nil {
+		cs.CurrentUsage = msg.Usage
+	}
 	if msg.Response != "" {
-		setAIResponse(&m, msg)
+		cs.CurrentAIResponse += msg.Response
+		t.ChatView.UpdateStream(cs.CurrentAIResponse)
 	}
 	if !msg.Done {
-		return m, waitForChatResponse(m.ChatService.ByteReader)
-	} else {
-		renderedText, _ := m.Renderer.Render(m.ChatService.CurrentAIResponse)
-		m.ChatView.Messages = append(m.ChatView.Messages, formatMessage(m.ChatService.ModelName, renderedText, styles.AiStyle))
-		m.ChatService.CurrentAIResponse = ""
-		m.ChatView.Set()
+		cmd := waitForChatResponse(cs.ByteReader)
+		if t == m.tab() && !m.framePending {
+			m.framePending = true
+			cmd = tea.Batch(cmd, frameTick())
+		}
+		return m, cmd
+	}
+	t.ChatView.FinishStream()
+	cs.Stream = nil
+	// Memories the model suggests are taken out of the answer and asked about.
+	var proposed []string
+	if cs.ProposeMemories && cs.Memory != nil {
+		cs.CurrentAIResponse, proposed = memory.Proposals(cs.CurrentAIResponse)
 	}
+	// A failed or cancelled response keeps whatever arrived before it stopped.
+	if cs.CurrentAIResponse != "" || msg.Event == types.EventDone {
+		appendConversationMessage(t, cs.AddAssistantMessage(cs.CurrentAIResponse))
+	}
+	cs.CurrentAIResponse = ""
+	switch msg.Event {
+	case types.EventError:
+		t.ChatView.Append(formatMessage("System", styles.ErrorStyle.Render(msg.Error), styles.AiStyle))
+	case types.EventCancelled:
+		t.ChatView.Append(formatMessage("System", styles.HintStyle.Render("Response cancelled"), styles.AiStyle))
+	}
+	if len(cs.Retrieved) > 0 {
+		t.ChatView.Append(formatSources(cs.Retrieved))
+		cs.Retrieved = nil
+	}
+	if t != m.tab() {
+		t.Unread = true
+	}
+	proposeMemories(&m, cs.Memory, proposed)
+	refreshSidebar(&m, false)
 	return m, nil
 }
 
@@ -64,16 +164,107 @@ func (m ChatModel) handleResize(msg tea.WindowSizeMsg) (tea.Model, tea.Cmd) {
 	debugWidth := msg.Width / 3
 	mainWidth := msg.Width - debugWidth - 4
 
-	m.ChatView.Viewport.Width = mainWidth
-	m.InputArea.Textarea.SetWidth(mainWidth)
-	m.ChatView.Viewport.Height = msg.Height - m.InputArea.Textarea.Height() - lipgloss.Height(gap)
+	m.Height = msg.Height
+	m.Width = mainWidth
+	m.layout()
 
+	// Messages keep their raw markdown, so Set reflows them for the new width.
 	if len(m.ChatView.Messages) > 0 {
 		m.ChatView.Set()
 	}
 	return m, nil
 }
 
+// layout hands whatever height the input area and header don't use to the chat view,
+// and narrows the chat while the sidebar is showing.
+func (m ChatModel) layout() {
+	if m.Height == 0 {
+		return
+	}
+	width := m.Width
+	if m.Sidebar.Visible {
+		width = max(20, width-components.SidebarWidth)
+	}
+	m.ChatView.Viewport.Width = width
+	m.InputArea.Textarea.SetWidth(width)
+	m.CommandPopup.Width = width
+	height := m.Height - m.InputArea.Textarea.Height() - lipgloss.Height(gap)
+	if header(&m) != "" {
+		height--
+	}
+	m.ChatView.Viewport.Height = max(1, height)
+	m.Sidebar.Height = m.Height
+}
+
+func (m ChatModel) handleSubmit() (tea.Model, tea.Cmd) {
+	prompt := m.InputArea.Textarea.Value()
+	trimmed := strings.TrimSpace(prompt)
+	if err := m.InputArea.Remember(prompt); err != nil {
+		m.Logger.Warn("failed to save prompt history", "error", err)
+	}
+	if attachments.IsCommand(prompt) {
+		if err := checkTool(&m, personas.ToolShell); err != nil {
+			addSystemError(&m, err)
+			return m, nil
+		}
+		m.InputArea.Textarea.Reset()
+		m.InputArea.Hint = "running " + trimmed + "..."
+		return m, runShellCommand(prompt)
+	}
+	if commands.IsCommand(prompt) {
+		if sel, ok := m.CommandPopup.Current(); ok && !strings.Contains(trimmed, " ") {
+			prompt = "/" + sel.Name
+		}
+		m.InputArea.Textarea.Reset()
+		m.CommandPopup.Hide()
+		return m, runCommand(&m, prompt)
+	}
+	prompt = commands.Unescape(prompt)
+	if trimmed == "" && len(m.InputArea.Pending) == 0 {
+		return m, nil
+	}
+
+	atts, errs := attachments.Resolve(prompt)
+	if len(atts) > 0 {
+		// Only mentions of files that exist count, so an @name in prose still sends.
+		if err := checkTool(&m, personas.ToolFiles); err != nil {
+			addSystemError(&m, err)
+			return m, nil
+		}
+	}
+	atts = append(append([]*types.Attachment{}, m.InputArea.Pending...), atts...)
+	if len(attachments.Images(atts)) > 0 {
+		if err := checkModality(&m, types.ModalityImage); err != nil {
+			addSystemError(&m, err)
+			return m, nil
+		}
+	}
+	m.InputArea.TakePending()
+	if m.editingID != "" {
+		m.ChatService.TruncateFrom(m.editingID)
+		m.editingID = ""
+		renderSession(&m)
+	}
+	question := m.ChatService.AddUserMessage(prompt, atts)
+	appendConversationMessage(m.tab(), question)
+	for _, err := range errs {
+		m.Logger.Error("failed to attach file", "error", err)
+		addSystemError(&m, err)
+	}
+	m.InputArea.Textarea.Reset()
+	m.InputArea.Hint = ""
+	if len(m.arenaModels) > 0 {
+		return m, startArena(&m)
+	}
+	if len(m.compareModels) > 0 {
+		return m, startComparison(&m, m.compareModels, false)
+	}
+	if m.ChatService.Index != nil {
+		return m, retrieve(&m, question)
+	}
+	return m, startGeneration(&m)
+}
+
 func (m ChatModel) handleKeyMsg(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
 	switch msg.Type {
 	case tea.KeyCtrlC, tea.KeyEscape:
@@ -82,28 +273,85 @@ func (m ChatModel) handleKeyMsg(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
 			m.Mode = ChatMode
 			return m, nil
 		}
+		if msg.Type == tea.KeyEscape && m.editingID != "" {
+			m.editingID = ""
+			m.InputArea.TakePending()
+			m.InputArea.Textarea.Reset()
+			m.InputArea.Hint = ""
+			return m, nil
+		}
+		if msg.Type == tea.KeyEscape && m.ChatService.Stream != nil {
+			m.ChatService.Cancel()
+			return m, nil
+		}
+		if msg.Type == tea.KeyEscape && len(m.InputArea.Pending) > 0 {
+			m.InputArea.TakePending()
+			return m, nil
+		}
 		fmt.Println(m.InputArea.Textarea.Value())
 		return m, tea.Quit
 	case tea.KeyEnter:
-		prompt := m.InputArea.Textarea.Value()
-		m.ChatView.Messages = append(m.ChatView.Messages, styles.UserStyle.Render("You: ")+prompt)
-		m.ChatView.Set()
-		m.InputArea.Textarea.Reset()
-		request := m.ChatService.ModelProvider.GenerateRequest(prompt)
-		go m.ChatService.Bus.RunChat(request)
-		return m, waitForChatResponse(m.ChatService.ByteReader)
+		if msg.Alt {
+			// Alt+Enter already inserted a newline in the textarea.
+			return m, nil
+		}
+		return m.handleSubmit()
+	case tea.KeyCtrlE:
+		return m, components.OpenEditor(m.InputArea.Textarea.Value())
+	case tea.KeyTab:
+		if sel, ok := m.CommandPopup.Current(); ok {
+			m.InputArea.Textarea.SetValue("/" + sel.Name + " ")
+			return m, nil
+		}
+		m.InputArea.CompleteMention()
+	case tea.KeyUp:
+		if !m.InputArea.HistoryPrev() {
+			m.CommandPopup.Prev()
+		}
+	case tea.KeyDown:
+		if !m.InputArea.HistoryNext() {
+			m.CommandPopup.Next()
+		}
+	case tea.KeyCtrlR:
+		m.InputArea.StartSearch()
 	case tea.KeyCtrlF:
-		m.Mode = ModelSelectMode
-		models, err := m.ChatService.ModelProvider.RetrieveModels()
-		if err != nil {
-			m.Logger.Error("failed to load models..", "error", err)
+		openModelSelector(&m)
+	case tea.KeyCtrlS:
+		enterSelectMode(&m)
+	case tea.KeyCtrlB:
+		if m.Sidebar.Visible && !m.Sidebar.Focused {
+			focusSidebar(&m)
+		} else {
+			toggleSidebar(&m)
 		}
-		m.ModelSelector.SetModels(models)
-		m.ModelSelector.Toggle()
 	}
 	return m, nil
 }
 
+// resizeInput grows or shrinks the input to fit what's been typed.
+func (m ChatModel) resizeInput() {
+	if m.InputArea.Grow() {
+		m.layout()
+		m.ChatView.Viewport.GotoBottom()
+	}
+}
+
+// refreshCommandPopup keeps the autocomplete popup in sync with the input. It only
+// shows while the command name is still being typed.
+func (m ChatModel) refreshCommandPopup() {
+	value := m.InputArea.Textarea.Value()
+	if m.template != nil && m.template.template == nil {
+		// Picking a template: the input filters the templates instead.
+		m.CommandPopup.Filter(m.template.suggestions(), value)
+		return
+	}
+	if !commands.IsCommand(value) || strings.ContainsAny(value, " \n") {
+		m.CommandPopup.Hide()
+		return
+	}
+	m.CommandPopup.Filter(commandRegistry.Suggestions(), strings.TrimPrefix(value, "/"))
+}
+
 func (m ChatModel) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 	if m.Mode == ModelSelectMode {
 		if _, ok := msg.(tea.KeyMsg); ok {
@@ -111,10 +359,44 @@ func (m ChatModel) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 			return m, cmd
 		}
 	}
+	if keyMsg, ok := msg.(tea.KeyMsg); ok && m.Mode == SelectMode {
+		return m.handleSelectKey(keyMsg)
+	}
+	if keyMsg, ok := msg.(tea.KeyMsg); ok && m.Mode == ConfirmMode {
+		return m.handleConfirmKey(keyMsg)
+	}
+	if keyMsg, ok := msg.(tea.KeyMsg); ok && m.Mode == CompareMode {
+		return m.handleCompareKey(keyMsg)
+	}
+	if keyMsg, ok := msg.(tea.KeyMsg); ok && m.Mode == SidebarMode {
+		return m.handleSidebarKey(keyMsg)
+	}
+	if keyMsg, ok := msg.(tea.KeyMsg); ok && m.Mode == TemplateMode {
+		return m.handleTemplateKey(keyMsg)
+	}
+	if keyMsg, ok := msg.(tea.KeyMsg); ok {
+		// Alt+1..9 jump to a tab; the textarea would otherwise type the digit.
+		if i, ok := tabKey(keyMsg); ok {
+			switchTab(&m, i)
+			return m, nil
+		}
+	}
 	var (
 		tiCmd tea.Cmd
 		vpCmd tea.Cmd
 	)
+	if keyMsg, ok := msg.(tea.KeyMsg); ok && m.InputArea.Searching {
+		m.InputArea.SearchKey(keyMsg)
+		m.refreshCommandPopup()
+		m.resizeInput()
+		return m, nil
+	}
+	if keyMsg, ok := msg.(tea.KeyMsg); ok && keyMsg.Paste {
+		// The textarea would turn each half of a CRLF into its own newline.
+		pasted := strings.ReplaceAll(string(keyMsg.Runes), "\r\n", "\n")
+		keyMsg.Runes = []rune(strings.ReplaceAll(pasted, "\r", "\n"))
+		msg = keyMsg
+	}
 
 	m.InputArea.Textarea, tiCmd = m.InputArea.Textarea.Update(msg)
 	m.ChatView.Viewport, vpCmd = m.ChatView.Viewport.Update(msg)
@@ -123,8 +405,48 @@ func (m ChatModel) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 		return m.handleResize(msg)
 	case chatResponsemsg:
 		return m.handleChatResponse(msg)
+	case templateMsg:
+		return m.handleTemplate(msg)
+	case indexProgressMsg:
+		return m.handleIndexProgress(msg)
+	case indexDoneMsg:
+		return m.handleIndexDone(msg)
+	case retrievalMsg:
+		return m.handleRetrieval(msg)
 	case tea.KeyMsg:
-		return m.handleKeyMsg(msg)
+		if msg.Paste {
+			// Pasted newlines were inserted by the textarea and must not send.
+			m.resizeInput()
+			return m, tiCmd
+		}
+		next, cmd := m.handleKeyMsg(msg)
+		// A command may have opened the template picker, which m doesn't know about.
+		next.(ChatModel).refreshCommandPopup()
+		m.resizeInput()
+		return next, cmd
+	case components.EditorClosedMsg:
+		if m.editingMemories {
+			return m.handleMemoriesEdited(msg)
+		}
+		if msg.Err != nil {
+			m.Logger.Error("editor exited with an error", "error", msg.Err)
+			addSystemError(&m, fmt.Errorf("editor: %w", msg.Err))
+			return m, nil
+		}
+		m.InputArea.Textarea.SetValue(msg.Content)
+		m.resizeInput()
+		return m, nil
+	case commandOutputMsg:
+		m.InputArea.Hint = ""
+		m.InputArea.Pending = append(m.InputArea.Pending, msg)
+		addSystemMarkdown(&m, formatCommandPreview(msg))
+		return m, nil
+	case frameMsg:
+		m.framePending = false
+		if m.ChatView.StreamDirty() {
+			m.ChatView.Set()
+		}
+		return m, nil
 	case errMsg:
 		m.Err = msg
 		m.Logger.Debug("UI:frontend error", "error", m.Err)
@@ -132,9 +454,7 @@ func (m ChatModel) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 	case components.ModelSelectedMsg:
 		m.ChatService.ModelName = msg.Name
 		m.ChatService.ModelProvider.SetModel(msg.Name)
-		confirmationMsg := fmt.Sprintf("Switched to Model: %s", msg.Name)
-		m.ChatView.Messages = append(m.ChatView.Messages, formatMessage("System", confirmationMsg, styles.AiStyle))
-		m.ChatView.Set()
+		addSystemMessage(&m, fmt.Sprintf("Switched to Model: %s", msg.Name))
 
 		m.Mode = ChatMode
 		return m, nil
@@ -150,11 +470,47 @@ func (m ChatModel) View() string {
 	if m.Mode == ModelSelectMode {
 		return m.ModelSelector.View()
 	}
+	separator := gap
+	status := []string{}
+	if len(m.InputArea.Pending) > 0 {
+		status = append(status, formatChips(m.InputArea.Pending))
+	}
+	if m.InputArea.Hint != "" {
+		status = append(status, styles.HintStyle.Render(m.InputArea.Hint))
+	}
+	if m.InputArea.Textarea.Value() != "" || len(m.InputArea.Pending) > 0 {
+		tok := tokenizer.For(m.ChatService.ModelName)
+		status = append(status, styles.HintStyle.Render(formatTokenCount(m.InputArea.TokenCount(tok), tok)))
+	}
+	if c := m.ChatService.Compaction; c != nil && c.Compacted() {
+		status = append(status, styles.HintStyle.Render(formatCompaction(c)))
+	}
+	if len(status) > 0 {
+		separator = "\n" + lipgloss.NewStyle().MaxWidth(m.ChatView.Viewport.Width).Render(strings.Join(status, "  ")) + "\n"
+	}
+	chatContent := m.ChatView.Viewport.View()
+	if m.compare != nil {
+		m.compare.view.Width = m.ChatView.Viewport.Width
+		m.compare.view.Height = m.ChatView.Viewport.Height
+		chatContent = m.compare.view.View()
+	}
+	if m.CommandPopup.Visible() {
+		// The popup borrows the bottom lines of the chat so the input doesn't jump.
+		lines := strings.Split(chatContent, "\n")
+		keep := max(0, len(lines)-m.CommandPopup.Height())
+		chatContent = strings.Join(append(lines[:keep], m.CommandPopup.View()), "\n")
+	}
+	if bar := header(&m); bar != "" {
+		chatContent = bar + "\n" + chatContent
+	}
 	mainContent := fmt.Sprintf(
 		"%s%s%s",
-		m.ChatView.Viewport.View(),
-		gap,
+		chatContent,
+		separator,
 		m.InputArea.Textarea.View(),
 	)
+	if m.Sidebar.Visible {
+		return lipgloss.JoinHorizontal(lipgloss.Top, m.Sidebar.View(), mainContent)
+	}
 	return mainContent
 }
@@ -0,0 +1,70 @@
+package models
+
+import (
+	"fmt"
+	"strings"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/falbanese9484/terminal-chat/personas"
+	"github.com/falbanese9484/terminal-chat/ui/commands"
+)
+
+func runPersona(m *ChatModel, in *commands.Input) tea.Cmd {
+	library, err := personas.NewDefaultLibrary()
+	if err != nil {
+		addSystemError(m, err)
+		return nil
+	}
+	switch {
+	case len(in.Args) == 0:
+		list, err := library.List()
+		if err != nil {
+			addSystemError(m, fmt.Errorf("failed to list personas: %w", err))
+			return nil
+		}
+		addSystemMarkdown(m, formatPersonas(list, m.ChatService.Persona, library.Dir))
+		return nil
+	case in.Args[0] == "off":
+		m.ChatService.SetPersona(nil)
+		addSystemMessage(m, "Persona cleared, back to no system prompt and default options")
+	default:
+		p, err := library.Get(in.Args[0])
+		if err != nil {
+			addSystemError(m, err)
+			return nil
+		}
+		if err := m.ChatService.SetPersona(p); err != nil {
+			addSystemError(m, err)
+			return nil
+		}
+		addSystemMessage(m, fmt.Sprintf("Switched to persona %s on %s", p.Name, m.ChatService.ModelName))
+	}
+	m.layout()
+	m.ChatView.Set()
+	return nil
+}
+
+func formatPersonas(list []*personas.Persona, current *personas.Persona, dir string) string {
+	var sb strings.Builder
+	sb.WriteString("| Persona | Model | Tools | Description |\n|---|---|---|---|\n")
+	for _, p := range list {
+		name := p.Name
+		if current != nil && current.Name == p.Name {
+			name = "**" + name + "** (active)"
+		}
+		model := p.Model
+		if model == "" {
+			model = "current"
+		}
+		tools := "all"
+		if p.Tools != nil {
+			tools = strings.Join(p.Tools, ", ")
+			if tools == "" {
+				tools = "none"
+			}
+		}
+		fmt.Fprintf(&sb, "| %s | %s | %s | %s |\n", name, model, tools, p.Description)
+	}
+	fmt.Fprintf(&sb, "\nAdd your own as JSON files in `%s`.\n", dir)
+	return sb.String()
+}
@@ -0,0 +1,186 @@
+package models
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/falbanese9484/terminal-chat/attachments"
+	"github.com/falbanese9484/terminal-chat/personas"
+	"github.com/falbanese9484/terminal-chat/rag"
+	"github.com/falbanese9484/terminal-chat/types"
+	"github.com/falbanese9484/terminal-chat/ui/commands"
+	"github.com/falbanese9484/terminal-chat/ui/styles"
+)
+
+// defaultTopK is how many excerpts go with each question unless BUTLER_RAG_K says.
+const defaultTopK = 4
+
+type (
+	indexProgressMsg struct {
+		progress rag.Progress
+		updates  chan tea.Msg
+	}
+	indexDoneMsg struct {
+		sessionID string
+		index     *rag.Index
+		err       error
+	}
+	retrievalMsg struct {
+		index     *rag.Index
+		sessionID string
+		messageID string
+		hits      []rag.Hit
+		err       error
+	}
+)
+
+func topK() int {
+	if k, err := strconv.Atoi(os.Getenv("BUTLER_RAG_K")); err == nil && k > 0 {
+		return k
+	}
+	return defaultTopK
+}
+
+func runIndex(m *ChatModel, in *commands.Input) tea.Cmd {
+	cs := m.ChatService
+	switch {
+	case len(in.Args) == 0:
+		if cs.Index == nil {
+			addSystemMessage(m, "No index in use, build one with /index <dir>")
+			return nil
+		}
+		ix := cs.Index
+		addSystemMessage(m, fmt.Sprintf("Using the index of %s: %d files in %d chunks, embedded with %s, updated %s",
+			ix.DisplayPath("."), len(ix.Files), len(ix.Chunks), ix.Model, ix.Updated.Format(time.DateTime)))
+		return nil
+	case in.Args[0] == "off":
+		cs.Index = nil
+		addSystemMessage(m, "Questions no longer draw on an index")
+		return nil
+	}
+	if err := checkTool(m, personas.ToolFiles); err != nil {
+		addSystemError(m, err)
+		return nil
+	}
+	embedder, err := rag.DefaultEmbedder(cs.Providers)
+	if err != nil {
+		addSystemError(m, err)
+		return nil
+	}
+	ix, err := rag.Open(attachments.ExpandHome(in.Args[0]), embedder)
+	if err != nil {
+		addSystemError(m, fmt.Errorf("/index %s: %w", in.Args[0], err))
+		return nil
+	}
+	m.InputArea.Hint = "indexing " + in.Args[0] + "..."
+	updates := make(chan tea.Msg, 1)
+	sessionID := cs.Session.ID
+	go func() {
+		err := ix.Build(context.Background(), func(p rag.Progress) {
+			// Progress is only for show, so a tick the UI hasn't taken yet is skipped.
+			select {
+			case updates <- indexProgressMsg{progress: p, updates: updates}:
+			default:
+			}
+		})
+		updates <- indexDoneMsg{sessionID: sessionID, index: ix, err: err}
+	}()
+	return waitForIndex(updates)
+}
+
+func waitForIndex(updates chan tea.Msg) tea.Cmd {
+	return func() tea.Msg {
+		return <-updates
+	}
+}
+
+func (m ChatModel) handleIndexProgress(msg indexProgressMsg) (tea.Model, tea.Cmd) {
+	p := msg.progress
+	m.InputArea.Hint = fmt.Sprintf("indexing: %d new or changed files, %d/%d chunks embedded", p.Files, p.Embedded, p.Pending)
+	return m, waitForIndex(msg.updates)
+}
+
+func (m ChatModel) handleIndexDone(msg indexDoneMsg) (tea.Model, tea.Cmd) {
+	m.InputArea.Hint = ""
+	if msg.err != nil {
+		m.Logger.Error("failed to build index", "error", msg.err)
+		addSystemError(&m, fmt.Errorf("failed to index: %w", msg.err))
+		return m, nil
+	}
+	ix := msg.index
+	if t := m.tabFor(msg.sessionID); t != nil {
+		t.ChatService.Index = ix
+	}
+	addSystemMessage(&m, fmt.Sprintf("Indexed %s: %d files in %d chunks. Questions now come with the %d closest excerpts; /index off stops that.",
+		ix.DisplayPath("."), len(ix.Files), len(ix.Chunks), topK()))
+	if skipped := ix.Skipped(); len(skipped) > 0 {
+		for _, err := range skipped {
+			m.Logger.Warn("skipped unreadable path while indexing", "error", err)
+		}
+		addSystemMessage(&m, fmt.Sprintf("%d files or directories couldn't be read and were left out, starting with: %v", len(skipped), skipped[0]))
+	}
+	return m, nil
+}
+
+// retrieve searches the index for the question before the response is generated.
+func retrieve(m *ChatModel, question types.Message) tea.Cmd {
+	cs := m.ChatService
+	ix, sessionID := cs.Index, cs.Session.ID
+	m.InputArea.Hint = "searching " + ix.DisplayPath(".") + "..."
+	return func() tea.Msg {
+		ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+		defer cancel()
+		hits, err := ix.Search(ctx, question.Content, topK())
+		return retrievalMsg{index: ix, sessionID: sessionID, messageID: question.ID, hits: hits, err: err}
+	}
+}
+
+// handleRetrieval attaches the excerpts found to the question and starts the response.
+// A failed search still answers, just without excerpts.
+func (m ChatModel) handleRetrieval(msg retrievalMsg) (tea.Model, tea.Cmd) {
+	m.InputArea.Hint = ""
+	t := m.tabFor(msg.sessionID)
+	if t == nil {
+		return m, nil
+	}
+	cs := t.ChatService
+	if msg.err != nil {
+		m.Logger.Error("failed to search index", "error", msg.err)
+		t.ChatView.Append(formatMessage("System", styles.ErrorStyle.Render("index search failed: "+msg.err.Error()), styles.AiStyle))
+	}
+	if i := cs.Session.Index(msg.messageID); i >= 0 && len(msg.hits) > 0 {
+		atts := slices.Clone(cs.Session.Messages[i].Attachments)
+		cs.Retrieved = nil
+		for _, hit := range msg.hits {
+			atts = append(atts, excerpt(msg.index, hit))
+			cs.Retrieved = append(cs.Retrieved, msg.index.Cite(hit))
+		}
+		cs.Session.SetAttachments(msg.messageID, atts)
+	}
+	return m, generate(&m, t, true)
+}
+
+func excerpt(ix *rag.Index, hit rag.Hit) *types.Attachment {
+	path := ix.DisplayPath(hit.Path)
+	return &types.Attachment{
+		Kind:      types.AttachmentExcerpt,
+		Name:      ix.Cite(hit),
+		Path:      path,
+		Language:  attachments.Language(path),
+		Content:   hit.Text,
+		Size:      int64(len(hit.Text)),
+		StartLine: hit.StartLine,
+		EndLine:   hit.EndLine,
+	}
+}
+
+// formatSources lists the excerpts a response was given, under it.
+func formatSources(sources []string) string {
+	return styles.HintStyle.Render("Sources: " + strings.Join(sources, ", "))
+}
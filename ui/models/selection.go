@@ -0,0 +1,245 @@
+package models
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/x/ansi"
+	"github.com/falbanese9484/terminal-chat/attachments"
+	"github.com/falbanese9484/terminal-chat/clipboard"
+	"github.com/falbanese9484/terminal-chat/codeblocks"
+	"github.com/falbanese9484/terminal-chat/types"
+	"github.com/falbanese9484/terminal-chat/ui/styles"
+)
+
+const selectHint = "↑/↓ move · ←/→ branch · c copy · 1-9 copy code block · e edit · d delete · r regenerate · esc done"
+
+// enterSelectMode puts a cursor on the last message so the transcript can be acted on.
+func enterSelectMode(m *ChatModel) {
+	if m.ChatView.Streaming() {
+		addSystemError(m, errors.New("wait for the response to finish before selecting messages"))
+		return
+	}
+	if len(m.ChatView.Messages) == 0 {
+		return
+	}
+	m.Mode = SelectMode
+	m.InputArea.Textarea.Blur()
+	m.InputArea.Hint = selectHint
+	m.ChatView.Select(len(m.ChatView.Messages) - 1)
+}
+
+func exitSelectMode(m *ChatModel) {
+	m.Mode = ChatMode
+	m.InputArea.Textarea.Focus()
+	m.InputArea.Hint = ""
+	m.ChatView.Select(-1)
+}
+
+func (m ChatModel) handleSelectKey(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
+	switch key := msg.String(); key {
+	case "esc", "q", "ctrl+s":
+		exitSelectMode(&m)
+	case "ctrl+c":
+		return m, tea.Quit
+	case "up", "k":
+		m.ChatView.MoveSelection(-1)
+	case "down", "j":
+		m.ChatView.MoveSelection(1)
+	case "home", "g":
+		m.ChatView.Select(0)
+	case "end", "G":
+		m.ChatView.Select(len(m.ChatView.Messages) - 1)
+	case "left", "h":
+		switchBranch(&m, -1)
+	case "right", "l":
+		switchBranch(&m, 1)
+	case "c":
+		copySelected(&m, 0)
+	case "1", "2", "3", "4", "5", "6", "7", "8", "9":
+		copySelected(&m, int(key[0]-'0'))
+	case "e":
+		return m, editSelected(&m)
+	case "d":
+		deleteSelected(&m)
+	case "r":
+		return m, regenerate(&m)
+	}
+	return m, nil
+}
+
+// selectedContent returns the raw text of the message under the cursor. Conversation
+// messages come from the session, so markdown is copied as the model wrote it.
+func selectedContent(m *ChatModel) (string, bool) {
+	vm, ok := m.ChatView.SelectedMessage()
+	if !ok {
+		return "", false
+	}
+	if msg, _, ok := m.ChatService.Message(vm.ID); ok {
+		return msg.Content, true
+	}
+	if vm.Markdown {
+		return vm.Body, true
+	}
+	return ansi.Strip(vm.Header + vm.Body), true
+}
+
+// copySelected copies the selected message, or its nth code block when n is above zero.
+func copySelected(m *ChatModel, n int) {
+	content, ok := selectedContent(m)
+	if !ok {
+		return
+	}
+	what := "message"
+	if n > 0 {
+		blocks := codeblocks.Extract(content)
+		if n > len(blocks) {
+			m.InputArea.Hint = fmt.Sprintf("message has %d code blocks", len(blocks))
+			return
+		}
+		content = blocks[n-1].Code
+		what = fmt.Sprintf("code block %d", n)
+	}
+	if err := clipboard.Copy(content); err != nil {
+		m.Logger.Error("failed to copy to clipboard", "error", err)
+		m.InputArea.Hint = styles.ErrorStyle.Render("copy failed: " + err.Error())
+		return
+	}
+	m.InputArea.Hint = "copied " + what + " · " + selectHint
+}
+
+// editSelected loads a user message back into the input. When it is sent, the message
+// and everything after it are replaced.
+func editSelected(m *ChatModel) tea.Cmd {
+	vm, ok := m.ChatView.SelectedMessage()
+	if !ok {
+		return nil
+	}
+	msg, _, ok := m.ChatService.Message(vm.ID)
+	if !ok || msg.Role != types.RoleUser {
+		m.InputArea.Hint = "only your own messages can be edited · " + selectHint
+		return nil
+	}
+	exitSelectMode(m)
+	m.editingID = msg.ID
+	m.InputArea.Textarea.SetValue(msg.Content)
+	// @mentions are read again on send, everything else was attached by hand.
+	mentions := attachments.Mentions(msg.Content)
+	m.InputArea.Pending = nil
+	for _, att := range msg.Attachments {
+		if !slices.Contains(mentions, att.Path) {
+			m.InputArea.Pending = append(m.InputArea.Pending, att)
+		}
+	}
+	m.InputArea.Hint = "editing message, Enter resends from here"
+	m.resizeInput()
+	return nil
+}
+
+func deleteSelected(m *ChatModel) {
+	vm, ok := m.ChatView.SelectedMessage()
+	if !ok {
+		return
+	}
+	selected := m.ChatView.Selected
+	if !m.ChatService.DeleteMessage(vm.ID) {
+		// UI-only messages aren't part of the context, they just leave the view.
+		m.ChatView.Messages = slices.Delete(m.ChatView.Messages, selected, selected+1)
+	} else {
+		renderSession(m)
+	}
+	if len(m.ChatView.Messages) == 0 {
+		exitSelectMode(m)
+		return
+	}
+	m.ChatView.Select(selected)
+}
+
+// switchBranch shows another version of the selected message, along with the replies
+// that were made to it.
+func switchBranch(m *ChatModel, delta int) {
+	vm, ok := m.ChatView.SelectedMessage()
+	if !ok {
+		return
+	}
+	if !m.ChatService.SwitchBranch(vm.ID, delta) {
+		m.InputArea.Hint = "no other versions of this message · " + selectHint
+		return
+	}
+	selected := m.ChatView.Selected
+	renderSession(m)
+	m.ChatView.Select(selected)
+}
+
+// regenerate drops the last response and asks the model again, keeping the old one as
+// a branch. If the conversation already ends with a user message, say after a failed
+// request, it is simply resent.
+func regenerate(m *ChatModel) tea.Cmd {
+	n := len(m.ChatService.Session.Messages)
+	if n == 0 {
+		return nil
+	}
+	if !m.ChatService.PopAssistantMessage() && m.ChatService.Session.Messages[n-1].Role != types.RoleUser {
+		return nil
+	}
+	exitSelectMode(m)
+	renderSession(m)
+	return startGeneration(m)
+}
+
+// renderSession redraws the transcript from the conversation. System notes shown in
+// the view are not part of it and are dropped.
+func renderSession(m *ChatModel) {
+	m.ChatView.Clear()
+	for _, msg := range m.ChatService.Session.Messages {
+		appendConversationMessage(m.tab(), msg)
+	}
+}
+
+// appendConversationMessage adds a message to the view, marked with "< 2/3 >" when it
+// has alternatives. It takes the tab since responses also finish in background tabs.
+func appendConversationMessage(t *Tab, msg types.Message) {
+	branch := formatBranch(t.ChatService.Session.Branch(msg.ID))
+	switch msg.Role {
+	case types.RoleUser:
+		text := formatUserMessage(msg.Content, msg.Attachments)
+		if branch != "" {
+			text = branch + " " + text
+		}
+		t.ChatView.Append(text).ID = msg.ID
+	case types.RoleAssistant:
+		sender := msg.Model
+		if sender == "" {
+			sender = t.ChatService.ModelName
+		}
+		body := codeblocks.Number(msg.Content)
+		t.ChatView.AppendMarkdown(formatMessageAt(sender, branch, styles.AiStyle, msg.CreatedAt), body).ID = msg.ID
+	}
+}
+
+// startGeneration streams a response to the conversation as it stands. Responses come
+// back through the shared reader tagged with their request, and every stream ends with
+// one final event, so one wait is outstanding per stream whichever tab is showing.
+func startGeneration(m *ChatModel) tea.Cmd {
+	return generate(m, m.tab(), true)
+}
+
+// generate streams a response in the tab. With summarize set, history that no longer
+// fits is summarized first when the context strategy asks for that.
+func generate(m *ChatModel, t *Tab, summarize bool) tea.Cmd {
+	cs := t.ChatService
+	request := cs.NewRequest()
+	if dropped, ok := cs.PendingSummary(); ok && summarize {
+		return summarizeHistory(m, t, dropped)
+	}
+	t.ChatView.StartStream(formatMessage(cs.ModelName, "", styles.AiStyle))
+	t.ChatView.Set()
+	// Whatever an earlier request still sends is dropped as stale.
+	cs.Cancel()
+	cs.CurrentAIResponse = ""
+	cs.Stream = cs.Bus.RunChatOn(cs.ModelProvider, cs.Session.ID, request)
+	refreshSidebar(m, false)
+	return waitForChatResponse(cs.ByteReader)
+}
@@ -0,0 +1,272 @@
+package models
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
+	"github.com/falbanese9484/terminal-chat/ui/components"
+	"github.com/falbanese9484/terminal-chat/ui/services"
+	"github.com/falbanese9484/terminal-chat/ui/styles"
+)
+
+type Tab struct {
+	// An open conversation. The active tab's service and view are also what
+	// ChatModel.ChatService and ChatModel.ChatView point at.
+	ChatService *services.ChatService
+	ChatView    *components.ChatView
+	// Unread is set when a response finishes while the tab is in the background.
+	Unread bool
+	// summary collects the summary of older messages streamed before a response.
+	summary *pendingSummary
+}
+
+func (m *ChatModel) tab() *Tab {
+	return m.Tabs[m.ActiveTab]
+}
+
+// tabFor finds the open tab holding a session, so a response can be routed to it.
+func (m *ChatModel) tabFor(sessionID string) *Tab {
+	for _, t := range m.Tabs {
+		if t.ChatService.Session.ID == sessionID {
+			return t
+		}
+	}
+	return nil
+}
+
+func switchTab(m *ChatModel, i int) {
+	if i < 0 || i >= len(m.Tabs) {
+		return
+	}
+	if m.Mode == SelectMode {
+		exitSelectMode(m)
+	}
+	if m.editingID != "" {
+		m.editingID = ""
+		m.InputArea.TakePending()
+		m.InputArea.Hint = ""
+	}
+	width, height := m.ChatView.Viewport.Width, m.ChatView.Viewport.Height
+	m.ActiveTab = i
+	t := m.Tabs[i]
+	t.Unread = false
+	m.ChatService, m.ChatView = t.ChatService, t.ChatView
+	m.ChatView.Viewport.Width, m.ChatView.Viewport.Height = width, height
+	m.layout()
+	m.ChatView.Set()
+	refreshSidebar(m, false)
+}
+
+// newTab opens an empty conversation with the current model and switches to it.
+func newTab(m *ChatModel) {
+	view := components.NewChatView(m.ChatView.Viewport.Width, m.ChatView.Viewport.Height, m.ChatView.Style())
+	m.Tabs = append(m.Tabs, &Tab{ChatService: m.ChatService.NewConversation(), ChatView: view})
+	switchTab(m, len(m.Tabs)-1)
+}
+
+// closeTab drops an open conversation, cancelling any response still streaming into it.
+func closeTab(m *ChatModel, i int) error {
+	if i < 0 || i >= len(m.Tabs) {
+		return fmt.Errorf("no tab %d", i+1)
+	}
+	if len(m.Tabs) == 1 {
+		return errors.New("can't close the last tab, use /clear to start over")
+	}
+	m.Tabs[i].ChatService.Cancel()
+	m.Tabs = append(m.Tabs[:i], m.Tabs[i+1:]...)
+	active := m.ActiveTab
+	if i < active || active >= len(m.Tabs) {
+		active--
+	}
+	// Point at the new active tab before switching so switchTab keeps the geometry.
+	m.ActiveTab = -1
+	switchTab(m, max(active, 0))
+	return nil
+}
+
+// openInTab shows a stored session, switching to it if it is already open. It goes
+// into the current tab if that is still empty and into a new tab otherwise.
+func openInTab(m *ChatModel, id, messageID string) error {
+	for i, t := range m.Tabs {
+		if t.ChatService.Session.ID == id {
+			switchTab(m, i)
+			return nil
+		}
+	}
+	if len(m.ChatService.Session.Messages) > 0 || m.ChatView.Streaming() {
+		newTab(m)
+	}
+	return m.Open(id, messageID)
+}
+
+func tabTitle(t *Tab) string {
+	s := t.ChatService.Session
+	switch {
+	case s.Title != "":
+		return s.Title
+	case len(s.Messages) > 0:
+		return s.DefaultTitle()
+	default:
+		return "New conversation"
+	}
+}
+
+// refreshSidebar lists the open tabs followed by the saved sessions. The store is only
+// read again when reload is set, since that means loading every session.
+func refreshSidebar(m *ChatModel, reload bool) {
+	if m.Sidebar == nil {
+		return
+	}
+	entries := []components.SidebarEntry{}
+	open := map[string]bool{}
+	for i, t := range m.Tabs {
+		open[t.ChatService.Session.ID] = true
+		entries = append(entries, components.SidebarEntry{
+			ID:        t.ChatService.Session.ID,
+			Title:     tabTitle(t),
+			Tab:       i,
+			Active:    i == m.ActiveTab,
+			Streaming: t.ChatView.Streaming(),
+			Unread:    t.Unread,
+		})
+	}
+	if reload || m.saved == nil {
+		m.saved = []components.SidebarEntry{}
+		list, errs := m.ChatService.Store.List()
+		for _, err := range errs {
+			m.Logger.Warn("failed to load session", "error", err)
+		}
+		for _, s := range list {
+			m.saved = append(m.saved, components.SidebarEntry{ID: s.ID, Title: s.Title, Tab: -1})
+		}
+	}
+	for _, e := range m.saved {
+		if !open[e.ID] {
+			entries = append(entries, e)
+		}
+	}
+	m.Sidebar.SetEntries(entries)
+}
+
+func toggleSidebar(m *ChatModel) {
+	m.Sidebar.Visible = !m.Sidebar.Visible
+	if m.Sidebar.Visible {
+		refreshSidebar(m, true)
+		focusSidebar(m)
+	} else {
+		unfocusSidebar(m)
+	}
+	m.layout()
+	m.ChatView.Set()
+}
+
+func focusSidebar(m *ChatModel) {
+	m.Sidebar.Focused = true
+	m.Sidebar.Selected = m.ActiveTab
+	m.Mode = SidebarMode
+	m.InputArea.Textarea.Blur()
+}
+
+func unfocusSidebar(m *ChatModel) {
+	m.Sidebar.Focused = false
+	if m.Mode == SidebarMode {
+		m.Mode = ChatMode
+		m.InputArea.Textarea.Focus()
+	}
+}
+
+// tabBar shows the open tabs above the chat once there is more than one.
+func tabBar(m *ChatModel) string {
+	if len(m.Tabs) < 2 {
+		return ""
+	}
+	activeStyle := lipgloss.NewStyle().Bold(true).Foreground(lipgloss.Color("75")).Background(lipgloss.Color("238"))
+	tabs := make([]string, 0, len(m.Tabs))
+	for i, t := range m.Tabs {
+		title := tabTitle(t)
+		if r := []rune(title); len(r) > 18 {
+			title = string(r[:17]) + "…"
+		}
+		label := fmt.Sprintf(" %d %s", i+1, title)
+		switch {
+		case t.ChatView.Streaming():
+			label += " ●"
+		case t.Unread:
+			label += " *"
+		}
+		label += " "
+		if i == m.ActiveTab {
+			label = activeStyle.Render(label)
+		} else {
+			label = styles.HintStyle.Render(label)
+		}
+		tabs = append(tabs, label)
+	}
+	return lipgloss.NewStyle().MaxWidth(m.ChatView.Viewport.Width).Render(strings.Join(tabs, "│"))
+}
+
+// header is the line above the chat: the active persona, if any, and the tab bar.
+func header(m *ChatModel) string {
+	parts := []string{}
+	if p := m.ChatService.Persona; p != nil {
+		parts = append(parts, styles.PersonaStyle.Render(p.Name)+" "+styles.HintStyle.Render(m.ChatService.ModelName))
+	}
+	if bar := tabBar(m); bar != "" {
+		parts = append(parts, bar)
+	}
+	if len(parts) == 0 {
+		return ""
+	}
+	return lipgloss.NewStyle().MaxWidth(m.ChatView.Viewport.Width).Render(strings.Join(parts, "  "))
+}
+
+// tabKey reports which tab Alt+1..9 picks.
+func tabKey(msg tea.KeyMsg) (int, bool) {
+	if !msg.Alt || msg.Type != tea.KeyRunes || len(msg.Runes) != 1 {
+		return 0, false
+	}
+	r := msg.Runes[0]
+	if r < '1' || r > '9' {
+		return 0, false
+	}
+	return int(r - '1'), true
+}
+
+func (m ChatModel) handleSidebarKey(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
+	switch msg.String() {
+	case "up", "k":
+		m.Sidebar.Prev()
+	case "down", "j":
+		m.Sidebar.Next()
+	case "enter":
+		e, ok := m.Sidebar.Current()
+		if !ok {
+			break
+		}
+		unfocusSidebar(&m)
+		if e.Tab >= 0 {
+			switchTab(&m, e.Tab)
+		} else if err := openInTab(&m, e.ID, ""); err != nil {
+			addSystemError(&m, err)
+		}
+	case "x":
+		if e, ok := m.Sidebar.Current(); ok && e.Tab >= 0 {
+			if err := closeTab(&m, e.Tab); err != nil {
+				addSystemError(&m, err)
+			}
+		}
+	case "n":
+		unfocusSidebar(&m)
+		newTab(&m)
+	case "esc", "tab":
+		unfocusSidebar(&m)
+	case "ctrl+b":
+		toggleSidebar(&m)
+	case "ctrl+c":
+		return m, tea.Quit
+	}
+	return m, nil
+}
@@ -0,0 +1,263 @@
+package models
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/falbanese9484/terminal-chat/personas"
+	"github.com/falbanese9484/terminal-chat/templates"
+	"github.com/falbanese9484/terminal-chat/ui/commands"
+	"github.com/falbanese9484/terminal-chat/ui/components"
+)
+
+type templateFill struct {
+	// A template being picked, while template is nil, or filled in. asks are the
+	// variables to prompt for and answers what was typed for them so far. rendering is
+	// set while sourced values are fetched.
+	list      []*templates.Template
+	template  *templates.Template
+	asks      []templates.Variable
+	answers   map[string]string
+	rendering bool
+}
+
+type templateMsg struct {
+	fill *templateFill
+	text string
+	err  error
+}
+
+func runTemplate(m *ChatModel, in *commands.Input) tea.Cmd {
+	library, err := templates.NewDefaultLibrary()
+	if err != nil {
+		addSystemError(m, err)
+		return nil
+	}
+	if len(in.Args) == 1 {
+		t, err := library.Get(in.Args[0])
+		if err != nil {
+			addSystemError(m, err)
+			return nil
+		}
+		return beginTemplate(m, t)
+	}
+	list, err := library.List()
+	if err != nil {
+		addSystemError(m, fmt.Errorf("failed to list templates: %w", err))
+		return nil
+	}
+	if len(list) == 0 {
+		addSystemMarkdown(m, fmt.Sprintf("No templates yet. Add Markdown files with `{{variable}}` placeholders to `%s`.", library.Dir))
+		return nil
+	}
+	m.template = &templateFill{list: list}
+	m.Mode = TemplateMode
+	m.CommandPopup.Prefix = ""
+	m.InputArea.Hint = "type to filter templates, enter to insert, esc to cancel"
+	m.refreshCommandPopup()
+	return nil
+}
+
+// suggestions lists the templates for the picker, described by their first line.
+func (f *templateFill) suggestions() []components.CommandSuggestion {
+	suggestions := make([]components.CommandSuggestion, 0, len(f.list))
+	for _, t := range f.list {
+		suggestions = append(suggestions, components.CommandSuggestion{Name: t.Name, Description: t.Description()})
+	}
+	return suggestions
+}
+
+// beginTemplate prompts for the template's variables one at a time; the ones with a
+// source are fetched once all the answers are in.
+func beginTemplate(m *ChatModel, t *templates.Template) tea.Cmd {
+	fill := &templateFill{template: t, answers: map[string]string{}}
+	for _, v := range t.Variables() {
+		if v.Source == templates.SourceAsk {
+			fill.asks = append(fill.asks, v)
+		}
+	}
+	m.template = fill
+	m.Mode = TemplateMode
+	m.CommandPopup.Prefix = "/"
+	m.CommandPopup.Hide()
+	return nextTemplateVariable(m)
+}
+
+func nextTemplateVariable(m *ChatModel) tea.Cmd {
+	fill := m.template
+	if i := len(fill.answers); i < len(fill.asks) {
+		m.InputArea.Hint = fmt.Sprintf("%s (%d/%d): type a value, or sh:command, file:path or clipboard; esc cancels",
+			fill.asks[i].Name, i+1, len(fill.asks))
+		return nil
+	}
+	for _, tool := range fill.tools() {
+		if err := checkTool(m, tool); err != nil {
+			endTemplate(m)
+			addSystemError(m, err)
+			return nil
+		}
+	}
+	commands := fill.commands()
+	if len(commands) == 0 {
+		return startTemplateRender(m)
+	}
+	// Templates can come from anywhere, so their commands are shown before any of
+	// them run.
+	addSystemMarkdown(m, fmt.Sprintf("Filling in %s runs:\n\n```sh\n%s\n```",
+		fill.template.Name, strings.Join(commands, "\n")))
+	askConfirmCmd(m, fmt.Sprintf("Run %d command(s) for %s?", len(commands), fill.template.Name),
+		func(m *ChatModel) tea.Cmd {
+			m.Mode = TemplateMode
+			return startTemplateRender(m)
+		},
+		func(m *ChatModel) {
+			endTemplate(m)
+			addSystemMessage(m, "Template cancelled, no commands were run")
+		})
+	return nil
+}
+
+func startTemplateRender(m *ChatModel) tea.Cmd {
+	m.template.rendering = true
+	m.InputArea.Hint = "filling in " + m.template.template.Name + "..."
+	return renderTemplate(m.template)
+}
+
+// sources gives each variable in the template with the source its value comes from:
+// its placeholder's, or the one its answer names.
+func (f *templateFill) sources() []templates.Variable {
+	vars := f.template.Variables()
+	for i, v := range vars {
+		if v.Source != templates.SourceAsk {
+			continue
+		}
+		if named, ok := templates.AnswerSource(v.Name, f.answers[v.Name]); ok {
+			vars[i] = named
+		}
+	}
+	return vars
+}
+
+// commands lists the shell commands filling in the template runs.
+func (f *templateFill) commands() []string {
+	commands := []string{}
+	for _, v := range f.sources() {
+		if v.Source == templates.SourceShell {
+			commands = append(commands, v.Arg)
+		}
+	}
+	return commands
+}
+
+// tools lists the tools filling in the template takes.
+func (f *templateFill) tools() []string {
+	tools := []string{}
+	for _, v := range f.sources() {
+		switch v.Source {
+		case templates.SourceShell:
+			tools = append(tools, personas.ToolShell)
+		case templates.SourceFile:
+			tools = append(tools, personas.ToolFiles)
+		}
+	}
+	return tools
+}
+
+func renderTemplate(fill *templateFill) tea.Cmd {
+	return func() tea.Msg {
+		ctx := context.Background()
+		values := map[string]string{}
+		for _, v := range fill.sources() {
+			var (
+				value string
+				err   error
+			)
+			if v.Source == templates.SourceAsk {
+				value = fill.answers[v.Name]
+			} else {
+				value, err = templates.Resolve(ctx, v)
+			}
+			if err != nil {
+				return templateMsg{fill: fill, err: fmt.Errorf("%s: %w", v.Name, err)}
+			}
+			values[v.Name] = value
+		}
+		return templateMsg{fill: fill, text: fill.template.Render(values)}
+	}
+}
+
+func endTemplate(m *ChatModel) {
+	m.template = nil
+	m.Mode = ChatMode
+	m.CommandPopup.Prefix = "/"
+	m.CommandPopup.Hide()
+	m.InputArea.Hint = ""
+}
+
+func (m ChatModel) handleTemplateKey(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
+	fill := m.template
+	switch msg.String() {
+	case "ctrl+c":
+		return m, tea.Quit
+	case "esc":
+		endTemplate(&m)
+		m.InputArea.Textarea.Reset()
+		m.resizeInput()
+		return m, nil
+	}
+	if fill.rendering {
+		return m, nil
+	}
+	if fill.template == nil {
+		switch msg.String() {
+		case "enter", "tab":
+			sel, ok := m.CommandPopup.Current()
+			if !ok {
+				return m, nil
+			}
+			m.InputArea.Textarea.Reset()
+			for _, t := range fill.list {
+				if t.Name == sel.Name {
+					return m, beginTemplate(&m, t)
+				}
+			}
+			return m, nil
+		case "up":
+			m.CommandPopup.Prev()
+			return m, nil
+		case "down":
+			m.CommandPopup.Next()
+			return m, nil
+		}
+	} else if msg.Type == tea.KeyEnter && !msg.Alt {
+		fill.answers[fill.asks[len(fill.answers)].Name] = m.InputArea.Textarea.Value()
+		m.InputArea.Textarea.Reset()
+		m.resizeInput()
+		return m, nextTemplateVariable(&m)
+	}
+	var cmd tea.Cmd
+	m.InputArea.Textarea, cmd = m.InputArea.Textarea.Update(msg)
+	m.refreshCommandPopup()
+	m.resizeInput()
+	return m, cmd
+}
+
+// handleTemplate puts the filled-in template in the input to be edited and sent.
+func (m ChatModel) handleTemplate(msg templateMsg) (tea.Model, tea.Cmd) {
+	if msg.fill != m.template {
+		// Cancelled while the values were being fetched.
+		return m, nil
+	}
+	endTemplate(&m)
+	if msg.err != nil {
+		m.Logger.Error("failed to fill in template", "error", msg.err)
+		addSystemError(&m, msg.err)
+		return m, nil
+	}
+	m.InputArea.Textarea.SetValue(msg.text)
+	m.InputArea.Hint = "inserted " + msg.fill.template.Name + ", enter sends"
+	m.resizeInput()
+	return m, nil
+}
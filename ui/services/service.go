@@ -1,8 +1,20 @@
 package services
 
 import (
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/falbanese9484/terminal-chat/attachments"
 	"github.com/falbanese9484/terminal-chat/chat"
+	"github.com/falbanese9484/terminal-chat/compaction"
 	"github.com/falbanese9484/terminal-chat/logger"
+	"github.com/falbanese9484/terminal-chat/memory"
+	"github.com/falbanese9484/terminal-chat/personas"
+	"github.com/falbanese9484/terminal-chat/rag"
+	"github.com/falbanese9484/terminal-chat/sessions"
+	"github.com/falbanese9484/terminal-chat/tokenizer"
 	"github.com/falbanese9484/terminal-chat/types"
 )
 
@@ -10,15 +22,41 @@ type ChatService struct {
 	Bus               *chat.ChatBus
 	ByteReader        chan *types.ChatResponse
 	CurrentAIResponse string
-	ModelProvider     *types.ProviderService
-	ModelName         string
-	Logger            *logger.Logger
+	// CurrentUsage is what the provider reported for the response being streamed.
+	CurrentUsage *types.Usage
+	// Stream is the request being answered, if any. Responses from any other request
+	// are stale and dropped.
+	Stream        *chat.Stream
+	ModelProvider *types.ProviderService
+	// Providers holds every configured provider, for compare mode.
+	Providers *types.ProviderRegistry
+	ModelName string
+	Logger    *logger.Logger
+	// Session holds the conversation history sent with every request.
+	Session *sessions.Session
+	Store   *sessions.Store
+	Options types.GenerationOptions
+	// Context says how the conversation is cut down to fit the model, and Compaction
+	// what the last request left out.
+	Context    compaction.Options
+	Compaction *compaction.Result
+	// Persona is the persona the conversation was set up with, if any.
+	Persona *personas.Persona
+	// Index is searched for excerpts to send with each question, when set. Retrieved
+	// cites what was found for the response being streamed, to list under it.
+	Index     *rag.Index
+	Retrieved []string
+	// Memory holds the facts kept across conversations, shared by every tab, and
+	// ProposeMemories lets the model suggest new ones.
+	Memory          *memory.Store
+	ProposeMemories bool
 }
 
 func NewChatService(buffersize int,
 	bus *chat.ChatBus,
 	mp *types.ProviderService,
 	model string, logger *logger.Logger,
+	store *sessions.Store,
 ) *ChatService {
 	return &ChatService{
 		Bus:           bus,
@@ -26,5 +64,289 @@ func NewChatService(buffersize int,
 		ModelProvider: mp,
 		ModelName:     model,
 		Logger:        logger,
+		Session:       sessions.NewSession(model),
+		Store:         store,
+		Context:       compaction.DefaultOptions(),
+	}
+}
+
+func (cs *ChatService) AddUserMessage(prompt string, atts []*types.Attachment) types.Message {
+	return cs.Session.Append(types.Message{
+		Role:        types.RoleUser,
+		Content:     prompt,
+		Attachments: atts,
+		CreatedAt:   time.Now(),
+	})
+}
+
+func (cs *ChatService) AddAssistantMessage(content string) types.Message {
+	return cs.Session.Append(types.Message{
+		Role:      types.RoleAssistant,
+		Content:   content,
+		Model:     cs.ModelName,
+		CreatedAt: time.Now(),
+		Usage:     cs.takeUsage(),
+	})
+}
+
+func (cs *ChatService) takeUsage() *types.Usage {
+	usage := cs.CurrentUsage
+	cs.CurrentUsage = nil
+	return usage
+}
+
+// Message returns the message with the given ID and its position in the conversation.
+func (cs *ChatService) Message(id string) (types.Message, int, bool) {
+	i := cs.Session.Index(id)
+	if i < 0 {
+		return types.Message{}, -1, false
+	}
+	return cs.Session.Messages[i], i, true
+}
+
+// DeleteMessage drops a message from the context sent to the provider.
+func (cs *ChatService) DeleteMessage(id string) bool {
+	return cs.Session.Remove(id)
+}
+
+// TruncateFrom drops the message with the given ID and everything after it, which is
+// how an edited message is resent from that point. The old messages are kept as a
+// branch.
+func (cs *ChatService) TruncateFrom(id string) bool {
+	return cs.Session.TruncateFrom(id)
+}
+
+// PopAssistantMessage removes the last message if it is an assistant response, so it
+// can be generated again. The response it replaces is kept as a branch.
+func (cs *ChatService) PopAssistantMessage() bool {
+	n := len(cs.Session.Messages)
+	if n == 0 || cs.Session.Messages[n-1].Role != types.RoleAssistant {
+		return false
+	}
+	return cs.Session.TruncateFrom(cs.Session.Messages[n-1].ID)
+}
+
+// SwitchBranch swaps the message for one of its alternatives, delta places away.
+func (cs *ChatService) SwitchBranch(id string, delta int) bool {
+	_, ok := cs.Session.SwitchBranch(id, delta)
+	return ok
+}
+
+// NewRequest builds a request carrying the whole conversation, with attachments folded
+// into each message the way the provider expects them.
+func (cs *ChatService) NewRequest() *types.ChatRequest {
+	return cs.newRequest(cs.ModelProvider, cs.ModelName, cs.ProposeMemories)
+}
+
+// NewRequestFor builds the same request for another provider and model, as compare
+// mode and the arena send. Those answers aren't checked for proposed memories, so the
+// model isn't asked for any.
+func (cs *ChatService) NewRequestFor(provider *types.ProviderService, model string) *types.ChatRequest {
+	return cs.newRequest(provider, model, false)
+}
+
+func (cs *ChatService) newRequest(provider *types.ProviderService, model string, propose bool) *types.ChatRequest {
+	length := cs.ContextLength(provider, model)
+	system, result := cs.contextFor(provider, model, length, propose)
+	cs.Compaction = &result
+	request := provider.GenerateRequest(result.Messages)
+	// Each conversation can be on its own model.
+	request.Model = model
+	request.System = system
+	request.Options = cs.Options
+	// The window the history was fitted to is the one the model is asked to serve.
+	window := compaction.Window(length)
+	request.Options.ContextLength = &window
+	return request
+}
+
+// citePrompt asks for citations of the excerpts an index adds to questions.
+const citePrompt = `Questions may come with excerpts from the user's files, each headed with its path and line range.
+Answer from them where they are relevant and cite each one you use inline as [path:start-end].`
+
+// ContextFor works out the system prompt and messages a request to model would carry,
+// cut down to fit the model's context window.
+func (cs *ChatService) ContextFor(provider *types.ProviderService, model string) (string, compaction.Result) {
+	return cs.contextFor(provider, model, cs.ContextLength(provider, model), cs.ProposeMemories)
+}
+
+func (cs *ChatService) contextFor(provider *types.ProviderService, model string, length int, propose bool) (string, compaction.Result) {
+	messages := make([]types.Message, 0, len(cs.Session.Messages))
+	for _, m := range cs.Session.Messages {
+		messages = append(messages, types.Message{
+			ID:      m.ID,
+			Role:    m.Role,
+			Content: attachments.Compose(m.Content, m.Attachments),
+			Images:  attachments.Images(m.Attachments),
+		})
+	}
+	system, summarized := cs.Session.SystemPrompt, 0
+	if cs.Memory != nil {
+		if block, n := cs.Memory.Prompt(tokenizer.For(model), memory.Budget()); n > 0 {
+			system = strings.TrimSpace(system + "\n\n" + block)
+		}
+	}
+	if propose {
+		system = strings.TrimSpace(system + "\n\n" + memory.ProposePrompt)
+	}
+	if summary := cs.Session.Summary; summary != nil && cs.Context.Strategy == compaction.Summarize {
+		if i := cs.Session.Index(summary.UpTo); i >= 0 && i < len(messages)-1 {
+			messages = messages[i+1:]
+			summarized = i + 1
+			system = strings.TrimSpace(system + "\n\nSummary of the earlier conversation:\n" + summary.Text)
+		}
+	}
+	if cs.Index != nil {
+		system = strings.TrimSpace(system + "\n\n" + citePrompt)
 	}
+	result := compaction.Fit(tokenizer.For(model), system, messages, compaction.Budget(length), cs.Context)
+	result.Summarized = summarized
+	return system, result
+}
+
+// ContextLength is the context window of the model, 0 if it isn't known.
+func (cs *ChatService) ContextLength(provider *types.ProviderService, model string) int {
+	if cs.Context.ContextLength > 0 {
+		return cs.Context.ContextLength
+	}
+	return lookupContextLength(provider, model)
+}
+
+type contextLengthKey struct {
+	provider *types.ProviderService
+	model    string
+}
+
+type contextLength struct {
+	length int
+	failed time.Time
+}
+
+// contextLengthRetry is how long a failed lookup is remembered before it is tried again.
+const contextLengthRetry = time.Minute
+
+var (
+	contextLengthsMu sync.Mutex
+	contextLengths   = map[contextLengthKey]contextLength{}
+)
+
+// lookupContextLength asks the provider for the model's window once and remembers the
+// answer, since finding the model can mean listing every model over the network and
+// requests are built on the UI goroutine. Failures are retried after a while.
+func lookupContextLength(provider *types.ProviderService, model string) int {
+	key := contextLengthKey{provider, model}
+	contextLengthsMu.Lock()
+	cached, ok := contextLengths[key]
+	contextLengthsMu.Unlock()
+	if ok && (cached.failed.IsZero() || time.Since(cached.failed) < contextLengthRetry) {
+		return cached.length
+	}
+	info, _, err := provider.FindModel(model)
+	cached = contextLength{length: info.ContextLength}
+	if err != nil {
+		cached.failed = time.Now()
+	}
+	contextLengthsMu.Lock()
+	contextLengths[key] = cached
+	contextLengthsMu.Unlock()
+	return cached.length
+}
+
+// PendingSummary returns the messages the last request dropped when they should be
+// summarized rather than lost.
+func (cs *ChatService) PendingSummary() ([]types.Message, bool) {
+	if cs.Context.Strategy != compaction.Summarize || cs.Compaction == nil || len(cs.Compaction.Dropped) == 0 {
+		return nil, false
+	}
+	return cs.Compaction.Dropped, true
+}
+
+// Clear starts a new conversation, keeping the system prompt.
+func (cs *ChatService) Clear() {
+	cs.Cancel()
+	cs.Stream = nil
+	system := cs.Session.SystemPrompt
+	cs.Session = sessions.NewSession(cs.ModelName)
+	cs.Session.SystemPrompt = system
+	cs.CurrentAIResponse = ""
+	cs.CurrentUsage = nil
+	cs.Compaction = nil
+	cs.Retrieved = nil
+}
+
+// Cancel stops the response being streamed, if any. The stream still ends with a
+// cancelled event.
+func (cs *ChatService) Cancel() {
+	if cs.Stream != nil {
+		cs.Stream.Cancel()
+	}
+}
+
+// NewConversation returns a service for another conversation that shares this one's
+// bus, provider and store, and its persona if it has one.
+func (cs *ChatService) NewConversation() *ChatService {
+	next := &ChatService{
+		Bus:             cs.Bus,
+		ByteReader:      cs.ByteReader,
+		ModelProvider:   cs.ModelProvider,
+		Providers:       cs.Providers,
+		ModelName:       cs.ModelName,
+		Logger:          cs.Logger,
+		Session:         sessions.NewSession(cs.ModelName),
+		Store:           cs.Store,
+		Options:         cs.Options,
+		Context:         cs.Context,
+		Persona:         cs.Persona,
+		Index:           cs.Index,
+		Memory:          cs.Memory,
+		ProposeMemories: cs.ProposeMemories,
+	}
+	if cs.Persona != nil {
+		next.Session.SystemPrompt = cs.Persona.System
+	}
+	return next
+}
+
+// SetPersona sets the conversation up as the persona says: its system prompt,
+// generation options and, if it names one, its model. A nil persona clears the system
+// prompt and options again and leaves the model as it is.
+func (cs *ChatService) SetPersona(p *personas.Persona) error {
+	if p == nil {
+		cs.Persona = nil
+		cs.Session.SystemPrompt = ""
+		cs.Options = types.GenerationOptions{}
+		return nil
+	}
+	if p.Model != "" {
+		provider, model, err := cs.Providers.Resolve(p.Model)
+		if err != nil {
+			return fmt.Errorf("persona %s: %w", p.Name, err)
+		}
+		cs.ModelProvider = provider
+		cs.ModelName = model
+		provider.SetModel(model)
+	}
+	cs.Persona = p
+	cs.Session.SystemPrompt = p.System
+	cs.Options = types.GenerationOptions{Temperature: p.Temperature, TopP: p.TopP, MaxTokens: p.MaxTokens}
+	return nil
+}
+
+// Open continues a stored session, with the current model.
+func (cs *ChatService) Open(id string) error {
+	session, err := cs.Store.Load(id)
+	if err != nil {
+		return err
+	}
+	cs.Session = session
+	cs.CurrentAIResponse = ""
+	cs.CurrentUsage = nil
+	cs.Compaction = nil
+	return nil
+}
+
+// Save writes the conversation to the session store.
+func (cs *ChatService) Save() error {
+	cs.Session.Model = cs.ModelName
+	return cs.Store.Save(cs.Session)
 }
@@ -0,0 +1,66 @@
+package services
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/falbanese9484/terminal-chat/types"
+)
+
+type countingProvider struct {
+	// Counts the model lists asked for, failing them while err is set.
+	calls int
+	err   error
+}
+
+func (p *countingProvider) Chat(c *types.BusConnector) {}
+func (p *countingProvider) GenerateRequest(messages []types.Message) *types.ChatRequest {
+	return &types.ChatRequest{Messages: messages}
+}
+func (p *countingProvider) SetModel(model string) {}
+func (p *countingProvider) RetrieveModels() ([]types.Model, error) {
+	p.calls++
+	if p.err != nil {
+		return nil, p.err
+	}
+	return []types.Model{{Name: "llama3.2:latest", ContextLength: 8192}}, nil
+}
+
+func TestNewRequestLooksUpContextLengthOnce(t *testing.T) {
+	stub := &countingProvider{}
+	cs := NewChatService(1, nil, types.NewProviderService(stub), "llama3.2:latest", nil, nil)
+	for range 3 {
+		request := cs.NewRequest()
+		if request.Options.ContextLength == nil || *request.Options.ContextLength != 8192 {
+			t.Fatalf("num_ctx = %v, want 8192", request.Options.ContextLength)
+		}
+	}
+	if cs.ContextLength(cs.ModelProvider, "llama3.2:latest") != 8192 {
+		t.Error("ContextLength doesn't match the request")
+	}
+	if stub.calls != 1 {
+		t.Errorf("listed models %d times, want once", stub.calls)
+	}
+}
+
+func TestContextLengthRetriesFailures(t *testing.T) {
+	stub := &countingProvider{err: errors.New("offline")}
+	provider := types.NewProviderService(stub)
+	if n := lookupContextLength(provider, "llama3.2:latest"); n != 0 {
+		t.Errorf("failed lookup = %d, want 0", n)
+	}
+	lookupContextLength(provider, "llama3.2:latest")
+	if stub.calls != 1 {
+		t.Errorf("a failed lookup was retried at once")
+	}
+	stub.err = nil
+	key := contextLengthKey{provider, "llama3.2:latest"}
+	contextLengthsMu.Lock()
+	entry := contextLengths[key]
+	entry.failed = entry.failed.Add(-contextLengthRetry)
+	contextLengths[key] = entry
+	contextLengthsMu.Unlock()
+	if n := lookupContextLength(provider, "llama3.2:latest"); n != 8192 || stub.calls != 2 {
+		t.Errorf("retried lookup = %d after %d calls", n, stub.calls)
+	}
+}
@@ -1,6 +1,12 @@
 package styles
 
-import "github.com/charmbracelet/lipgloss"
+import (
+	"os"
+
+	"github.com/charmbracelet/glamour/styles"
+	"github.com/charmbracelet/lipgloss"
+	"github.com/charmbracelet/x/term"
+)
 
 var (
 	UserStyle = lipgloss.NewStyle().Foreground(lipgloss.Color("10")).Bold(true)
@@ -21,4 +27,31 @@ var (
 	TitleStyle = lipgloss.NewStyle().
 			Bold(true).
 			Padding(2)
+	AttachmentStyle = lipgloss.NewStyle().
+			Foreground(lipgloss.Color("252")).
+			Background(lipgloss.Color("238")).
+			Padding(0, 1)
+	HintStyle = lipgloss.NewStyle().
+			Foreground(lipgloss.Color("241")) // Gray, matches ConnectedToStyle
+	ErrorStyle = lipgloss.NewStyle().
+			Foreground(lipgloss.Color("9")). // Bright red
+			Bold(true)
+	PersonaStyle = lipgloss.NewStyle().
+			Foreground(lipgloss.Color("232")).
+			Background(lipgloss.Color("75")). // Matches the AI model name
+			Bold(true).
+			Padding(0, 1)
 )
+
+// MarkdownStyle picks the glamour style the way glamour.WithAutoStyle does. It is called
+// once at startup, before the UI owns the terminal, since detecting the background color
+// means querying the terminal.
+func MarkdownStyle() string {
+	if !term.IsTerminal(os.Stdout.Fd()) {
+		return styles.NoTTYStyle
+	}
+	if lipgloss.HasDarkBackground() {
+		return styles.DarkStyle
+	}
+	return styles.LightStyle
+}